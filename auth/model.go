@@ -74,14 +74,6 @@ func createUser(firstName, lastName, email, passwordHash string) error {
 	return err
 }
 
-func createSession(userID int, token string, expiresAt time.Time) error {
-	_, err := db.Exec(`
-        INSERT INTO sessions (user_id, jwt_token, expires_at)
-        VALUES ($1, $2, $3)
-    `, userID, token, expiresAt)
-	return err
-}
-
 func getUserByID(id int) (*User, error) {
 	row := db.QueryRow(`
 		SELECT u.id, u.email, u.password_hash, u.first_name, u.last_name,
@@ -177,27 +169,28 @@ func getUsersByOrganizationID(orgID int) ([]User, error) {
 	return users, nil
 }
 
-func updateUserRole(userID int, roleName string) error {
-	var roleID int
-	err := db.QueryRow("SELECT id FROM roles WHERE name = $1", roleName).Scan(&roleID)
-	if err != nil {
-		return fmt.Errorf("роль не найдена: %v", err)
-	}
-
-	_, err = db.Exec("UPDATE users SET role_id = $1 WHERE id = $2", roleID, userID)
+// updateUserRole меняет роль пользователя организации (sql.ErrNoRows, если его там нет)
+func updateUserRole(orgID, userID, roleID int) error {
+	res, err := db.Exec("UPDATE users SET role_id = $1 WHERE id = $2 AND organization_id = $3", roleID, userID, orgID)
 	if err != nil {
 		return fmt.Errorf("ошибка при обновлении роли: %v", err)
 	}
-
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
 	return nil
 }
 
-func getAllUsers() ([]User, error) {
+// getAllUsers возвращает пользователей организации
+func getAllUsers(orgID int) ([]User, error) {
 	rows, err := db.Query(`
 		SELECT u.id, u.first_name, u.last_name, u.role_id, r.name
 		FROM users u
 		LEFT JOIN roles r ON u.role_id = r.id
-	`)
+		WHERE u.organization_id = $1
+	`, orgID)
 	if err != nil {
 		return nil, err
 	}
@@ -225,7 +218,22 @@ type RenameRoleRequest struct {
 	NewName string `json:"new_name"` // новое имя роли
 }
 
-func setUserBlockedStatus(userID int, blocked bool) error {
-	_, err := db.Exec(`UPDATE users SET is_blocked = $1 WHERE id = $2`, blocked, userID)
-	return err
+// setUserBlockedStatus блокирует или разблокирует пользователя организации
+// (sql.ErrNoRows, если его там нет)
+func setUserBlockedStatus(orgID, userID int, blocked bool) error {
+	res, err := db.Exec(`UPDATE users SET is_blocked = $1 WHERE id = $2 AND organization_id = $3`, blocked, userID, orgID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetUserByID возвращает пользователя с ролью — для проверки прав в других пакетах
+func GetUserByID(id int) (*User, error) {
+	return getUserByID(id)
 }
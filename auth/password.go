@@ -0,0 +1,216 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// passwordResetTTL — сколько действует ссылка сброса пароля
+const passwordResetTTL = time.Hour
+
+// minPasswordLength — минимальная длина пароля
+const minPasswordLength = 8
+
+var ErrResetTokenInvalid = errors.New("Недействительный или просроченный токен")
+
+// validatePassword проверяет пароль по политике: не короче minPasswordLength
+// символов, есть хотя бы одна буква и одна цифра
+func validatePassword(password string) error {
+	if utf8.RuneCountInString(password) < minPasswordLength {
+		return fmt.Errorf("Пароль должен быть не короче %d символов", minPasswordLength)
+	}
+	var letter, digit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			letter = true
+		case unicode.IsDigit(r):
+			digit = true
+		}
+	}
+	if !letter || !digit {
+		return errors.New("Пароль должен содержать буквы и цифры")
+	}
+	return nil
+}
+
+// hashToken — в базе хранится только хеш токена (сброса пароля, refresh),
+// сам токен есть лишь у пользователя
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// createPasswordReset выдаёт пользователю новый токен сброса; прежние
+// неиспользованные токены перестают действовать
+func createPasswordReset(userID int) (string, error) {
+	token := generateToken()
+	if token == "" {
+		return "", errors.New("не удалось сгенерировать токен")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		UPDATE password_resets SET used_at = NOW() WHERE user_id = $1 AND used_at IS NULL
+	`, userID); err != nil {
+		return "", err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO password_resets (user_id, token_hash, expires_at) VALUES ($1, $2, $3)
+	`, userID, hashToken(token), time.Now().Add(passwordResetTTL)); err != nil {
+		return "", err
+	}
+	return token, tx.Commit()
+}
+
+// resetPassword гасит токен, меняет пароль и отзывает все сессии пользователя.
+// Возвращает ID пользователя.
+func resetPassword(token, passwordHash string) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var resetID, userID int
+	err = tx.QueryRow(`
+		SELECT id, user_id FROM password_resets
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		FOR UPDATE
+	`, hashToken(token)).Scan(&resetID, &userID)
+	if err == sql.ErrNoRows {
+		return 0, ErrResetTokenInvalid
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(`UPDATE password_resets SET used_at = NOW() WHERE id = $1`, resetID); err != nil {
+		return 0, err
+	}
+	// ссылка из письма подтверждает и email
+	if _, err := tx.Exec(`
+		UPDATE users SET password_hash = $1, email_verified = TRUE WHERE id = $2
+	`, passwordHash, userID); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`UPDATE sessions SET revoked = TRUE WHERE user_id = $1`, userID); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`UPDATE refresh_tokens SET revoked = TRUE WHERE user_id = $1`, userID); err != nil {
+		return 0, err
+	}
+	return userID, tx.Commit()
+}
+
+func sendPasswordResetEmail(toEmail string, token string) error {
+	resetLink := os.Getenv("FRONTEND_URL") + "/reset-password?token=" + token
+
+	subject := "Восстановление пароля"
+	body := "Здравствуйте!\n\nПерейдите по ссылке, чтобы задать новый пароль:\n" + resetLink +
+		"\n\nСсылка действует 1 час. Если вы не запрашивали восстановление — проигнорируйте это письмо."
+
+	return sendMail(toEmail, subject, body)
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+// forgotPasswordHandler отправляет ссылку для сброса пароля. Ответ одинаковый,
+// есть такой пользователь или нет, чтобы по нему нельзя было проверять email.
+func forgotPasswordHandler(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Email) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Не указан email"})
+		return
+	}
+
+	const message = "Если пользователь с таким email существует, письмо для восстановления пароля отправлено"
+
+	user, err := getUserByEmail(strings.TrimSpace(req.Email))
+	if err != nil || user == nil {
+		log.Printf("%s ⚠️ Запрошено восстановление пароля для неизвестного email\n", time.Now().Format("2006/01/02 15:04:05"))
+		c.JSON(http.StatusOK, gin.H{"message": message})
+		return
+	}
+	if user.IsBlocked {
+		log.Printf("%s ⚠️ Запрошено восстановление пароля заблокированного пользователя ID=%d\n", time.Now().Format("2006/01/02 15:04:05"), user.ID)
+		c.JSON(http.StatusOK, gin.H{"message": message})
+		return
+	}
+
+	// сбой создания токена или отправки письма только логируется: другой ответ
+	// выдал бы, что пользователь с таким email существует
+	token, err := createPasswordReset(user.ID)
+	if err != nil {
+		log.Printf("%s ❌ Ошибка создания токена сброса пароля для пользователя ID=%d: %v\n", time.Now().Format("2006/01/02 15:04:05"), user.ID, err)
+		c.JSON(http.StatusOK, gin.H{"message": message})
+		return
+	}
+
+	if err := sendPasswordResetEmail(user.Email, token); err != nil {
+		log.Printf("%s ❌ Ошибка при отправке письма восстановления пользователю ID=%d: %v\n", time.Now().Format("2006/01/02 15:04:05"), user.ID, err)
+		c.JSON(http.StatusOK, gin.H{"message": message})
+		return
+	}
+
+	log.Printf("%s ✉️ Письмо восстановления пароля отправлено пользователю ID=%d\n", time.Now().Format("2006/01/02 15:04:05"), user.ID)
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// resetPasswordHandler задаёт новый пароль по токену из письма. Все сессии
+// пользователя отзываются — войти нужно заново.
+func resetPasswordHandler(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат запроса"})
+		return
+	}
+	if err := validatePassword(req.Password); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	passwordHash, err := hashPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при хешировании пароля"})
+		return
+	}
+
+	userID, err := resetPassword(req.Token, passwordHash)
+	if err == ErrResetTokenInvalid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("%s ❌ Ошибка сброса пароля: %v\n", time.Now().Format("2006/01/02 15:04:05"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось сменить пароль"})
+		return
+	}
+
+	log.Printf("%s ✅ Пароль пользователя ID=%d сброшен, сессии отозваны\n", time.Now().Format("2006/01/02 15:04:05"), userID)
+	c.JSON(http.StatusOK, gin.H{"message": "Пароль изменён. Войдите с новым паролем."})
+}
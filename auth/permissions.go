@@ -0,0 +1,342 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// Права. Роли организации получают набор прав в role_permissions.
+const (
+	PermUsersInvite      = "users.invite"      // приглашать пользователей
+	PermUsersManage      = "users.manage"      // блокировать и удалять пользователей
+	PermRolesManage      = "roles.manage"      // назначать роли и менять их права
+	PermTemplatesEdit    = "templates.edit"    // менять любые шаблоны (свои автор меняет всегда)
+	PermTagsEdit         = "tags.edit"         // менять общие теги организации
+	PermDocumentsRead    = "documents.read"    // читать любые документы организации
+	PermDocumentsEdit    = "documents.edit"    // менять любые документы организации
+	PermDocumentsApprove = "documents.approve" // согласовывать, подписывать и возвращать документы
+	PermDocumentsArchive = "documents.archive" // переносить документы в архив
+)
+
+// Встроенные роли
+const (
+	RoleOwner    = "Владелец"
+	RoleAdmin    = "Администратор"
+	RoleManager  = "Менеджер"
+	RoleEmployee = "Сотрудник"
+)
+
+// Permission — право и его описание для интерфейса
+type Permission struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Permissions — все права в порядке вывода
+var Permissions = []Permission{
+	{PermUsersInvite, "Приглашение пользователей"},
+	{PermUsersManage, "Блокировка и удаление пользователей"},
+	{PermRolesManage, "Назначение ролей и настройка прав"},
+	{PermTemplatesEdit, "Изменение любых шаблонов"},
+	{PermTagsEdit, "Изменение тегов"},
+	{PermDocumentsRead, "Просмотр любых документов"},
+	{PermDocumentsEdit, "Изменение любых документов"},
+	{PermDocumentsApprove, "Согласование и подписание документов"},
+	{PermDocumentsArchive, "Архивирование документов"},
+}
+
+// DefaultRolePermissions — права встроенных ролей в новой организации
+// (те же, что заданы миграцией 011_role_permissions.sql)
+var DefaultRolePermissions = map[string][]string{
+	RoleOwner:    allPermissions(),
+	RoleAdmin:    allPermissions(),
+	RoleManager:  {PermUsersInvite, PermDocumentsArchive},
+	RoleEmployee: {},
+}
+
+var (
+	ErrUnknownPermission = errors.New("неизвестное право")
+	ErrOwnerPermissions  = errors.New("права роли «Владелец» изменить нельзя")
+)
+
+func allPermissions() []string {
+	names := make([]string, len(Permissions))
+	for i, p := range Permissions {
+		names[i] = p.Name
+	}
+	return names
+}
+
+func isPermission(name string) bool {
+	return slices.Contains(allPermissions(), name)
+}
+
+// HasPermission проверяет, есть ли право у роли пользователя в его организации
+func HasPermission(userID int, permission string) (bool, error) {
+	var ok bool
+	err := db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM users u
+			JOIN role_permissions rp ON rp.role_id = u.role_id AND rp.organization_id = u.organization_id
+			WHERE u.id = $1 AND rp.permission = $2
+		)
+	`, userID, permission).Scan(&ok)
+	return ok, err
+}
+
+// UserPermissions возвращает права роли пользователя в его организации
+func UserPermissions(userID int) ([]string, error) {
+	rows, err := db.Query(`
+		SELECT rp.permission FROM users u
+		JOIN role_permissions rp ON rp.role_id = u.role_id AND rp.organization_id = u.organization_id
+		WHERE u.id = $1
+		ORDER BY rp.permission
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	perms := []string{}
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			return nil, err
+		}
+		perms = append(perms, p)
+	}
+	return perms, rows.Err()
+}
+
+// RequirePermission пропускает запрос, только если у пользователя есть все
+// перечисленные права. Подключается после AuthMiddleware.
+func RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("user_id")
+		for _, p := range permissions {
+			ok, err := HasPermission(userID, p)
+			if err != nil {
+				log.Printf("❌ Ошибка проверки права %s у пользователя ID=%d: %v\n", p, userID, err)
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Ошибка проверки прав"})
+				return
+			}
+			if !ok {
+				log.Printf("⛔ Недостаточно прав: user_id=%d, нет права %s\n", userID, p)
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Недостаточно прав", "permission": p})
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// RolePermissions — права роли в организации
+type RolePermissions struct {
+	RoleID      int      `json:"role_id"`
+	Role        string   `json:"role"`
+	Permissions []string `json:"permissions"`
+}
+
+// getRolePermissionMatrix возвращает права всех ролей в организации
+func getRolePermissionMatrix(orgID int) ([]RolePermissions, error) {
+	rows, err := db.Query(`
+		SELECT r.id, r.name, COALESCE(array_agg(rp.permission ORDER BY rp.permission)
+		       FILTER (WHERE rp.permission IS NOT NULL), '{}')
+		FROM roles r
+		LEFT JOIN role_permissions rp ON rp.role_id = r.id AND rp.organization_id = $1
+		WHERE r.organization_id = $1
+		GROUP BY r.id, r.name
+		ORDER BY r.id
+	`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	matrix := []RolePermissions{}
+	for rows.Next() {
+		var rp RolePermissions
+		if err := rows.Scan(&rp.RoleID, &rp.Role, pq.Array(&rp.Permissions)); err != nil {
+			return nil, err
+		}
+		matrix = append(matrix, rp)
+	}
+	return matrix, rows.Err()
+}
+
+// getRolePermissions возвращает права одной роли в организации
+func getRolePermissions(orgID, roleID int) (*RolePermissions, error) {
+	matrix, err := getRolePermissionMatrix(orgID)
+	if err != nil {
+		return nil, err
+	}
+	for _, rp := range matrix {
+		if rp.RoleID == roleID {
+			return &rp, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// setRolePermissions заменяет права роли в организации
+func setRolePermissions(orgID, roleID int, permissions []string) error {
+	for _, p := range permissions {
+		if !isPermission(p) {
+			return ErrUnknownPermission
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		DELETE FROM role_permissions WHERE organization_id = $1 AND role_id = $2
+	`, orgID, roleID); err != nil {
+		return err
+	}
+	for _, p := range permissions {
+		if _, err := tx.Exec(`
+			INSERT INTO role_permissions (organization_id, role_id, permission) VALUES ($1, $2, $3)
+			ON CONFLICT DO NOTHING
+		`, orgID, roleID, p); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ----------------- обработчики -----------------
+
+func getPermissionsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, Permissions)
+}
+
+func getMyPermissionsHandler(c *gin.Context) {
+	perms, err := UserPermissions(c.GetInt("user_id"))
+	if err != nil {
+		log.Printf("❌ Ошибка получения прав пользователя ID=%d: %v\n", c.GetInt("user_id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении прав"})
+		return
+	}
+	c.JSON(http.StatusOK, perms)
+}
+
+func getRolePermissionMatrixHandler(c *gin.Context) {
+	matrix, err := getRolePermissionMatrix(c.GetInt("organization_id"))
+	if err != nil {
+		log.Printf("❌ Ошибка получения прав ролей: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении прав ролей"})
+		return
+	}
+	c.JSON(http.StatusOK, matrix)
+}
+
+func getRolePermissionsHandler(c *gin.Context) {
+	roleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID роли"})
+		return
+	}
+
+	rp, err := getRolePermissions(c.GetInt("organization_id"), roleID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Роль не найдена"})
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Ошибка получения прав роли ID=%d: %v\n", roleID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении прав роли"})
+		return
+	}
+	c.JSON(http.StatusOK, rp)
+}
+
+type SetRolePermissionsRequest struct {
+	Permissions []string `json:"permissions"`
+}
+
+// setRolePermissionsHandler заменяет набор прав роли
+func setRolePermissionsHandler(c *gin.Context) {
+	var req SetRolePermissionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат запроса"})
+		return
+	}
+	updateRolePermissions(c, func([]string) []string { return req.Permissions })
+}
+
+// grantPermissionHandler добавляет роли право :permission
+func grantPermissionHandler(c *gin.Context) {
+	p := c.Param("permission")
+	updateRolePermissions(c, func(perms []string) []string {
+		if slices.Contains(perms, p) {
+			return perms
+		}
+		return append(perms, p)
+	})
+}
+
+// revokePermissionHandler отзывает у роли право :permission
+func revokePermissionHandler(c *gin.Context) {
+	p := c.Param("permission")
+	updateRolePermissions(c, func(perms []string) []string {
+		return slices.DeleteFunc(perms, func(s string) bool { return s == p })
+	})
+}
+
+// updateRolePermissions меняет права роли :id организации пользователя и отвечает новым набором.
+// Права владельца не меняются, чтобы организация не осталась без управления.
+func updateRolePermissions(c *gin.Context, update func([]string) []string) {
+	roleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID роли"})
+		return
+	}
+	orgID := c.GetInt("organization_id")
+
+	rp, err := getRolePermissions(orgID, roleID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Роль не найдена"})
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Ошибка получения прав роли ID=%d: %v\n", roleID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении прав роли"})
+		return
+	}
+	if rp.Role == RoleOwner {
+		c.JSON(http.StatusBadRequest, gin.H{"error": ErrOwnerPermissions.Error()})
+		return
+	}
+
+	err = setRolePermissions(orgID, roleID, update(rp.Permissions))
+	if err == ErrUnknownPermission {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Ошибка сохранения прав роли ID=%d: %v\n", roleID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при сохранении прав роли"})
+		return
+	}
+
+	rp, err = getRolePermissions(orgID, roleID)
+	if err != nil {
+		log.Printf("❌ Ошибка получения прав роли ID=%d: %v\n", roleID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении прав роли"})
+		return
+	}
+	logRoleChange(c, RoleActionPermissions, rp.Role, strings.Join(rp.Permissions, ", "))
+	c.JSON(http.StatusOK, rp)
+}
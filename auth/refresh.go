@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Вход выдаёт access-токен с сессией в sessions и refresh-токен в HttpOnly cookie.
+// Refresh-токен одноразовый: /refresh гасит его и выдаёт следующий той же цепочки
+// вместе с новым access-токеном и его сессией. Если использованный токен приходит
+// повторно, значит он утёк — отзываются вся цепочка и все её сессии.
+
+const (
+	sessionTTL      = 15 * time.Minute
+	refreshTokenTTL = 7 * 24 * time.Hour
+)
+
+var (
+	ErrRefreshInvalid = errors.New("Недействительный или просроченный refresh токен")
+	ErrRefreshReused  = errors.New("Refresh токен уже использован, сессии отозваны. Войдите заново.")
+)
+
+// generateAccessToken подписывает access-токен; jti делает токены уникальными,
+// даже если выданы в одну секунду
+func generateAccessToken(userID int) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": userID,
+		"jti":     generateToken(),
+		"exp":     time.Now().Add(sessionTTL).Unix(),
+	})
+	return token.SignedString(jwtSecret)
+}
+
+// issueTokens создаёт access-токен с сессией и refresh-токен цепочки familyID
+func issueTokens(tx *sql.Tx, userID int, familyID string) (string, string, error) {
+	accessToken, err := generateAccessToken(userID)
+	if err != nil {
+		return "", "", err
+	}
+	refreshToken := generateToken()
+	if refreshToken == "" {
+		return "", "", errors.New("не удалось сгенерировать refresh токен")
+	}
+
+	var sessionID int
+	err = tx.QueryRow(`
+		INSERT INTO sessions (user_id, jwt_token, expires_at) VALUES ($1, $2, $3) RETURNING id
+	`, userID, accessToken, time.Now().Add(sessionTTL)).Scan(&sessionID)
+	if err != nil {
+		return "", "", err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, session_id, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, userID, familyID, hashToken(refreshToken), sessionID, time.Now().Add(refreshTokenTTL)); err != nil {
+		return "", "", err
+	}
+	return accessToken, refreshToken, nil
+}
+
+// startSession выдаёт токены при входе, начиная новую цепочку refresh-токенов
+func startSession(userID int) (string, string, error) {
+	familyID := generateToken()
+	if familyID == "" {
+		return "", "", errors.New("не удалось сгенерировать идентификатор цепочки")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return "", "", err
+	}
+	defer tx.Rollback()
+
+	accessToken, refreshToken, err := issueTokens(tx, userID, familyID)
+	if err != nil {
+		return "", "", err
+	}
+	return accessToken, refreshToken, tx.Commit()
+}
+
+// revokeTokenFamily отзывает все refresh-токены цепочки и выданные по ним сессии
+func revokeTokenFamily(tx *sql.Tx, familyID string) error {
+	if _, err := tx.Exec(`
+		UPDATE sessions SET revoked = TRUE
+		WHERE id IN (SELECT session_id FROM refresh_tokens WHERE family_id = $1 AND session_id IS NOT NULL)
+	`, familyID); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`UPDATE refresh_tokens SET revoked = TRUE WHERE family_id = $1`, familyID)
+	return err
+}
+
+// rotateRefreshToken гасит refresh-токен и выдаёт новую пару токенов той же цепочки.
+// Возвращает ID пользователя, access- и refresh-токены.
+func rotateRefreshToken(token string) (int, string, string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, "", "", err
+	}
+	defer tx.Rollback()
+
+	var (
+		id, userID int
+		familyID   string
+		expiresAt  time.Time
+		usedAt     sql.NullTime
+		revoked    bool
+	)
+	err = tx.QueryRow(`
+		SELECT id, user_id, family_id, expires_at, used_at, revoked
+		FROM refresh_tokens
+		WHERE token_hash = $1
+		FOR UPDATE
+	`, hashToken(token)).Scan(&id, &userID, &familyID, &expiresAt, &usedAt, &revoked)
+	if err == sql.ErrNoRows {
+		return 0, "", "", ErrRefreshInvalid
+	}
+	if err != nil {
+		return 0, "", "", err
+	}
+
+	if usedAt.Valid {
+		log.Printf("%s 🚨 Повторное использование refresh токена пользователя ID=%d, цепочка отозвана\n", time.Now().Format("2006/01/02 15:04:05"), userID)
+		if err := revokeTokenFamily(tx, familyID); err != nil {
+			return 0, "", "", err
+		}
+		if err := tx.Commit(); err != nil {
+			return 0, "", "", err
+		}
+		return userID, "", "", ErrRefreshReused
+	}
+	if revoked || expiresAt.Before(time.Now()) {
+		return userID, "", "", ErrRefreshInvalid
+	}
+
+	user, err := getUserByID(userID)
+	if err != nil {
+		return userID, "", "", err
+	}
+	if user.IsBlocked {
+		return userID, "", "", ErrUserBlocked
+	}
+
+	if _, err := tx.Exec(`UPDATE refresh_tokens SET used_at = NOW() WHERE id = $1`, id); err != nil {
+		return userID, "", "", err
+	}
+	accessToken, refreshToken, err := issueTokens(tx, userID, familyID)
+	if err != nil {
+		return userID, "", "", err
+	}
+	return userID, accessToken, refreshToken, tx.Commit()
+}
+
+func setRefreshCookie(c *gin.Context, token string) {
+	c.SetCookie("refresh_token", token, int(refreshTokenTTL.Seconds()), "/", "localhost", false, true)
+}
+
+func clearRefreshCookie(c *gin.Context) {
+	c.SetCookie("refresh_token", "", -1, "/", "localhost", false, true)
+}
+
+// refreshHandler обменивает refresh-токен из cookie на новый access-токен
+// и новый refresh-токен
+func refreshHandler(c *gin.Context) {
+	refreshTokenStr, err := c.Cookie("refresh_token")
+	if err != nil || refreshTokenStr == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh токен не найден"})
+		return
+	}
+
+	userID, accessToken, refreshToken, err := rotateRefreshToken(refreshTokenStr)
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrRefreshInvalid), errors.Is(err, ErrRefreshReused):
+		clearRefreshCookie(c)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, ErrUserBlocked):
+		clearRefreshCookie(c)
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	default:
+		log.Printf("%s ❌ Ошибка обновления токенов пользователя ID=%d: %v\n", time.Now().Format("2006/01/02 15:04:05"), userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании access токена"})
+		return
+	}
+
+	setRefreshCookie(c, refreshToken)
+	c.JSON(http.StatusOK, gin.H{
+		"access_token": accessToken,
+	})
+}
@@ -0,0 +1,223 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Роли принадлежат организации. Встроенные роли (builtin) создаются вместе
+// с организацией; их нельзя переименовать или удалить — код опирается на их имена.
+
+var (
+	ErrRoleExists  = errors.New("роль с таким именем уже есть")
+	ErrRoleBuiltin = errors.New("встроенную роль нельзя переименовать или удалить")
+	ErrRoleInUse   = errors.New("роль назначена пользователям")
+)
+
+// Действия журнала ролей
+const (
+	RoleActionCreate      = "create"
+	RoleActionRename      = "rename"
+	RoleActionDelete      = "delete"
+	RoleActionPermissions = "permissions"
+	RoleActionAssign      = "assign"
+	RoleActionTransfer    = "transfer"
+)
+
+// orgRole — роль организации
+type orgRole struct {
+	ID      int
+	Name    string
+	Builtin bool
+}
+
+// getRoleID возвращает ID роли организации по имени (sql.ErrNoRows, если такой роли нет)
+func getRoleID(orgID int, roleName string) (int, error) {
+	var id int
+	err := db.QueryRow(`SELECT id FROM roles WHERE organization_id = $1 AND name = $2`, orgID, roleName).Scan(&id)
+	return id, err
+}
+
+// getRoleByName возвращает роль организации по имени
+func getRoleByName(orgID int, roleName string) (*orgRole, error) {
+	var r orgRole
+	err := db.QueryRow(`
+		SELECT id, name, builtin FROM roles WHERE organization_id = $1 AND name = $2
+	`, orgID, roleName).Scan(&r.ID, &r.Name, &r.Builtin)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// seedRoles создаёт встроенные роли новой организации с правами по умолчанию
+func seedRoles(orgID int) error {
+	for _, name := range []string{RoleOwner, RoleAdmin, RoleManager, RoleEmployee} {
+		var roleID int
+		err := db.QueryRow(`
+			INSERT INTO roles (organization_id, name, builtin) VALUES ($1, $2, TRUE)
+			ON CONFLICT (organization_id, name) DO UPDATE SET builtin = TRUE
+			RETURNING id
+		`, orgID, name).Scan(&roleID)
+		if err != nil {
+			return err
+		}
+		if err := setRolePermissions(orgID, roleID, DefaultRolePermissions[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createRole создаёт роль организации
+func createRole(orgID int, name string) (int, error) {
+	if _, err := getRoleID(orgID, name); err == nil {
+		return 0, ErrRoleExists
+	}
+	var id int
+	err := db.QueryRow(`
+		INSERT INTO roles (organization_id, name) VALUES ($1, $2) RETURNING id
+	`, orgID, name).Scan(&id)
+	return id, err
+}
+
+// renameRole переименовывает пользовательскую роль организации
+func renameRole(orgID int, oldName, newName string) error {
+	r, err := getRoleByName(orgID, oldName)
+	if err != nil {
+		return err
+	}
+	if r.Builtin {
+		return ErrRoleBuiltin
+	}
+	if oldName == newName {
+		return nil
+	}
+	if _, err := getRoleID(orgID, newName); err == nil {
+		return ErrRoleExists
+	}
+	_, err = db.Exec(`UPDATE roles SET name = $1 WHERE id = $2`, newName, r.ID)
+	return err
+}
+
+// deleteRole удаляет пользовательскую роль организации, если она никому не назначена
+func deleteRole(orgID int, name string) error {
+	r, err := getRoleByName(orgID, name)
+	if err != nil {
+		return err
+	}
+	if r.Builtin {
+		return ErrRoleBuiltin
+	}
+
+	var userCount int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM users WHERE role_id = $1`, r.ID).Scan(&userCount); err != nil {
+		return err
+	}
+	if userCount > 0 {
+		return ErrRoleInUse
+	}
+
+	_, err = db.Exec(`DELETE FROM roles WHERE id = $1`, r.ID)
+	return err
+}
+
+// logRoleChange пишет изменение роли в лог и в журнал role_changes организации
+func logRoleChange(c *gin.Context, action, roleName, details string) {
+	orgID, userID := c.GetInt("organization_id"), c.GetInt("user_id")
+	log.Printf("📝 Роли: %s '%s' (%s), организация ID=%d, пользователь ID=%d\n", action, roleName, details, orgID, userID)
+
+	_, err := db.Exec(`
+		INSERT INTO role_changes (organization_id, user_id, action, role_name, details)
+		VALUES ($1, $2, $3, $4, $5)
+	`, orgID, userID, action, roleName, details)
+	if err != nil {
+		log.Printf("⚠️ Не удалось записать изменение роли в журнал: %v\n", err)
+	}
+}
+
+// RoleChange — запись журнала изменений ролей
+type RoleChange struct {
+	ID        int       `json:"id"`
+	UserID    *int      `json:"user_id"`
+	UserName  string    `json:"user_name"`
+	Action    string    `json:"action"`
+	RoleName  string    `json:"role_name"`
+	Details   string    `json:"details"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// getRoleChanges возвращает журнал изменений ролей организации, новые записи первыми
+func getRoleChanges(orgID, limit int) ([]RoleChange, error) {
+	rows, err := db.Query(`
+		SELECT rc.id, rc.user_id, COALESCE(u.first_name || ' ' || u.last_name, ''),
+		       rc.action, rc.role_name, rc.details, rc.created_at
+		FROM role_changes rc
+		LEFT JOIN users u ON u.id = rc.user_id
+		WHERE rc.organization_id = $1
+		ORDER BY rc.created_at DESC, rc.id DESC
+		LIMIT $2
+	`, orgID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []RoleChange{}
+	for rows.Next() {
+		var ch RoleChange
+		if err := rows.Scan(&ch.ID, &ch.UserID, &ch.UserName, &ch.Action, &ch.RoleName, &ch.Details, &ch.CreatedAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, ch)
+	}
+	return changes, rows.Err()
+}
+
+func getRoleChangesHandler(c *gin.Context) {
+	changes, err := getRoleChanges(c.GetInt("organization_id"), 200)
+	if err != nil {
+		log.Printf("❌ Ошибка получения журнала ролей: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении журнала ролей"})
+		return
+	}
+	c.JSON(http.StatusOK, changes)
+}
+
+// transferOwnership передаёт роль «Владелец» пользователю toID; прежний владелец
+// fromID становится администратором. sql.ErrNoRows — нового владельца нет в организации.
+func transferOwnership(orgID, fromID, toID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		UPDATE users SET is_owner = TRUE,
+		       role_id = (SELECT id FROM roles WHERE organization_id = $2 AND name = $3)
+		WHERE id = $1 AND organization_id = $2
+	`, toID, orgID, RoleOwner)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE users SET is_owner = FALSE,
+		       role_id = (SELECT id FROM roles WHERE organization_id = $2 AND name = $3)
+		WHERE id = $1 AND organization_id = $2
+	`, fromID, orgID, RoleAdmin); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
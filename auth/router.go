@@ -2,9 +2,11 @@ package auth
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -27,11 +29,11 @@ func RegisterRoutes(r *gin.Engine) {
 	r.POST("/refresh", refreshHandler)
 	r.POST("/verify", verifyHandler)
 	r.GET("/verify", verifyEmailHandler)
-	r.POST("/invite", AuthMiddleware(), inviteHandler)
+	r.POST("/invite", AuthMiddleware(), RequirePermission(PermUsersInvite), inviteHandler)
 	r.POST("/set-password", setPasswordHandler)
+	r.POST("/password/forgot", forgotPasswordHandler)
+	r.POST("/password/reset", resetPasswordHandler)
 	r.GET("/api/users", AuthMiddleware(), getAllUsersHandler)
-	r.POST("/roles/rename", renameRoleHandler)
-	r.POST("/roles/delete", deleteRoleHandler)
 
 	// ✅ защищённые маршруты через группу
 	authGroup := r.Group("/")
@@ -39,11 +41,24 @@ func RegisterRoutes(r *gin.Engine) {
 	authGroup.GET("/me", MeHandler)
 	authGroup.GET("/auth/check", checkAuthHandler)
 	authGroup.GET("/users/invited", getInvitedUsersHandler)
-	authGroup.POST("/users/assign-role", assignRoleHandler)
+	authGroup.POST("/users/assign-role", RequirePermission(PermRolesManage), assignRoleHandler)
 	r.GET("/api/roles", AuthMiddleware(), getAllRolesHandler)
-	authGroup.POST("/users/:id/block", blockUserHandler)
-	authGroup.DELETE("/users/:id", deleteUserHandler)
-	authGroup.POST("/users/:id/unblock", unblockUserHandler)
+	authGroup.POST("/users/:id/block", RequirePermission(PermUsersManage), blockUserHandler)
+	authGroup.DELETE("/users/:id", RequirePermission(PermUsersManage), deleteUserHandler)
+	authGroup.POST("/users/:id/unblock", RequirePermission(PermUsersManage), unblockUserHandler)
+
+	// права ролей организации
+	authGroup.GET("/permissions", getPermissionsHandler)
+	authGroup.GET("/me/permissions", getMyPermissionsHandler)
+	authGroup.POST("/roles", RequirePermission(PermRolesManage), createRoleHandler)
+	authGroup.POST("/roles/rename", RequirePermission(PermRolesManage), renameRoleHandler)
+	authGroup.POST("/roles/delete", RequirePermission(PermRolesManage), deleteRoleHandler)
+	authGroup.GET("/roles/changes", RequirePermission(PermRolesManage), getRoleChangesHandler)
+	authGroup.GET("/roles/permissions", getRolePermissionMatrixHandler)
+	authGroup.GET("/roles/:id/permissions", getRolePermissionsHandler)
+	authGroup.PUT("/roles/:id/permissions", RequirePermission(PermRolesManage), setRolePermissionsHandler)
+	authGroup.POST("/roles/:id/permissions/:permission", RequirePermission(PermRolesManage), grantPermissionHandler)
+	authGroup.DELETE("/roles/:id/permissions/:permission", RequirePermission(PermRolesManage), revokePermissionHandler)
 
 }
 
@@ -91,24 +106,16 @@ func loginHandler(c *gin.Context) {
 
 	log.Printf("🔐 Пароль корректный. Генерация токенов для пользователя ID %d", user.ID)
 
-	accessToken, refreshToken, err := generateTokens(user.ID)
+	accessToken, refreshToken, err := startSession(user.ID)
 	if err != nil {
-		log.Println("❌ Ошибка при генерации токенов:", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании токенов"})
-		return
-	}
-
-	log.Println("💾 Сохраняем access токен в сессии...")
-
-	if err := createSession(user.ID, accessToken, time.Now().Add(15*time.Minute)); err != nil {
-		log.Println("❌ Ошибка при сохранении сессии:", err)
+		log.Println("❌ Ошибка при создании сессии:", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при сохранении сессии"})
 		return
 	}
 
 	log.Println("🍪 Устанавливаем refresh_token в HttpOnly cookie...")
 
-	c.SetCookie("refresh_token", refreshToken, 7*24*60*60, "/", "localhost", false, true)
+	setRefreshCookie(c, refreshToken)
 
 	log.Printf("✅ Вход выполнен успешно: user_id=%d", user.ID)
 
@@ -125,16 +132,6 @@ type RegisterRequest struct {
 	LastName  string `json:"last_name"`
 	Email     string `json:"email"`
 	Password  string `json:"password"`
-	Role      string `json:"role"`
-}
-
-func getOrCreateRoleID(roleName string) (int, error) {
-	var id int
-	err := db.QueryRow(`SELECT id FROM roles WHERE name = $1`, roleName).Scan(&id)
-	if err == sql.ErrNoRows {
-		err = db.QueryRow(`INSERT INTO roles (name) VALUES ($1) RETURNING id`, roleName).Scan(&id)
-	}
-	return id, err
 }
 
 func registerHandler(c *gin.Context) {
@@ -153,6 +150,11 @@ func registerHandler(c *gin.Context) {
 		return
 	}
 
+	if err := validatePassword(req.Password); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
 		log.Printf("%s ❌ Ошибка при хешировании пароля: %v\n", time.Now().Format("2006/01/02 15:04:05"), err)
@@ -180,19 +182,21 @@ func registerHandler(c *gin.Context) {
 	}
 	isOwner := userCount == 0
 
-	// 🧠 Устанавливаем роль по умолчанию
-	if req.Role == "" {
-		if isOwner {
-			req.Role = "Владелец"
-		} else {
-			req.Role = "Сотрудник"
-		}
+	// 🧠 Роль по умолчанию: первый пользователь организации — владелец
+	role := RoleEmployee
+	if isOwner {
+		role = RoleOwner
+	}
+
+	if err := seedRoles(orgID); err != nil {
+		log.Printf("%s ❌ Ошибка при создании ролей организации ID=%d: %v\n", time.Now().Format("2006/01/02 15:04:05"), orgID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании организации"})
+		return
 	}
 
-	// 👉 Получаем или создаём ID роли
-	roleID, err := getOrCreateRoleID(req.Role)
+	roleID, err := getRoleID(orgID, role)
 	if err != nil {
-		log.Printf("%s ❌ Ошибка при получении ID роли %s: %v\n", time.Now().Format("2006/01/02 15:04:05"), req.Role, err)
+		log.Printf("%s ❌ Ошибка при получении ID роли %s: %v\n", time.Now().Format("2006/01/02 15:04:05"), role, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при обработке роли"})
 		return
 	}
@@ -235,31 +239,34 @@ func generateToken() string {
 		log.Printf("%s ❌ Ошибка при генерации токена: %v\n", time.Now().Format("2006/01/02 15:04:05"), err)
 		return ""
 	}
-	token := hex.EncodeToString(b)
-	log.Printf("%s 🔑 Сгенерирован новый токен подтверждения: %s\n", time.Now().Format("2006/01/02 15:04:05"), token)
-	return token
+	// сам токен в лог не пишем: он даёт доступ к учётной записи
+	log.Printf("%s 🔑 Сгенерирован новый токен\n", time.Now().Format("2006/01/02 15:04:05"))
+	return hex.EncodeToString(b)
 }
 
-func sendVerificationEmail(toEmail string, token string) error {
+// sendMail отправляет текстовое письмо через SMTP из переменных окружения
+func sendMail(toEmail, subject, body string) error {
 	smtpHost := os.Getenv("SMTP_HOST")
 	smtpPort := os.Getenv("SMTP_PORT")
 	smtpUser := os.Getenv("SMTP_USER")
 	smtpPass := os.Getenv("SMTP_PASS")
-	frontendURL := os.Getenv("FRONTEND_URL")
-
-	verifyLink := frontendURL + "/verify?token=" + token
-
-	subject := "Подтверждение регистрации"
-	body := "Здравствуйте!\n\nПерейдите по ссылке для подтверждения регистрации:\n" + verifyLink + "\n\nЕсли вы не регистрировались — проигнорируйте это письмо."
 
 	msg := "From: " + smtpUser + "\n" +
 		"To: " + toEmail + "\n" +
 		"Subject: " + subject + "\n\n" + body
 
 	auth := smtp.PlainAuth("", smtpUser, smtpPass, smtpHost)
+	return smtp.SendMail(smtpHost+":"+smtpPort, auth, smtpUser, []string{toEmail}, []byte(msg))
+}
+
+func sendVerificationEmail(toEmail string, token string) error {
+	verifyLink := os.Getenv("FRONTEND_URL") + "/verify?token=" + token
+
+	subject := "Подтверждение регистрации"
+	body := "Здравствуйте!\n\nПерейдите по ссылке для подтверждения регистрации:\n" + verifyLink + "\n\nЕсли вы не регистрировались — проигнорируйте это письмо."
 
 	log.Printf("%s ✉️ Отправка письма подтверждения на %s\n", time.Now().Format("2006/01/02 15:04:05"), toEmail)
-	err := smtp.SendMail(smtpHost+":"+smtpPort, auth, smtpUser, []string{toEmail}, []byte(msg))
+	err := sendMail(toEmail, subject, body)
 	if err != nil {
 		log.Printf("%s ❌ Ошибка при отправке письма на %s: %v\n", time.Now().Format("2006/01/02 15:04:05"), toEmail, err)
 		return err
@@ -386,64 +393,75 @@ func AuthMiddleware() gin.HandlerFunc {
 		tokenStr := authHeader[len("Bearer "):]
 		log.Printf("%s 🔐 Получен токен: %s\n", time.Now().Format("2006/01/02 15:04:05"), tokenStr)
 
-		token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-			return jwtSecret, nil
-		})
-		if err != nil || !token.Valid {
-			log.Printf("%s ❌ Неверный токен: %v\n", time.Now().Format("2006/01/02 15:04:05"), err)
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Неверный токен"})
+		user, err := AuthenticateToken(tokenStr)
+		if err != nil {
+			status := http.StatusUnauthorized
+			if errors.Is(err, ErrUserBlocked) {
+				status = http.StatusForbidden
+			}
+			c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
 			return
 		}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			log.Printf("%s ❌ Ошибка при чтении claims из токена\n", time.Now().Format("2006/01/02 15:04:05"))
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Ошибка при чтении токена"})
-			return
-		}
+		log.Printf("%s ✅ Успешная аутентификация пользователя с ID %d\n", time.Now().Format("2006/01/02 15:04:05"), user.ID)
+		c.Set("user_id", user.ID)
+		c.Set("organization_id", user.OrganizationID)
+		c.Next()
+	}
+}
 
-		userIDFloat, ok := claims["user_id"].(float64)
-		if !ok {
-			log.Printf("%s ❌ Неверный формат user_id в токене\n", time.Now().Format("2006/01/02 15:04:05"))
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Неверный формат user_id"})
-			return
-		}
-		userID := int(userIDFloat)
+// ErrUserBlocked — токен действителен, но пользователь заблокирован
+var ErrUserBlocked = errors.New("Пользователь заблокирован")
 
-		session, err := getSessionByToken(tokenStr)
-		if err != nil {
-			log.Printf("%s ❌ Ошибка получения сессии по токену: %v\n", time.Now().Format("2006/01/02 15:04:05"), err)
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Сессия недействительна"})
-			return
-		}
-		if session.Revoked {
-			log.Printf("%s ❌ Сессия с токеном %s была отозвана\n", time.Now().Format("2006/01/02 15:04:05"), tokenStr)
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Сессия недействительна"})
-			return
-		}
-		if session.ExpiresAt.Before(time.Now()) {
-			log.Printf("%s ❌ Сессия с токеном %s истекла в %v\n", time.Now().Format("2006/01/02 15:04:05"), tokenStr, session.ExpiresAt)
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Сессия истекла"})
-			return
-		}
+// AuthenticateToken проверяет access-токен и его сессию и возвращает пользователя.
+// Используется AuthMiddleware и там, где токен приходит не в заголовке (WebSocket).
+func AuthenticateToken(tokenStr string) (*User, error) {
+	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
+		return jwtSecret, nil
+	})
+	if err != nil || !token.Valid {
+		log.Printf("%s ❌ Неверный токен: %v\n", time.Now().Format("2006/01/02 15:04:05"), err)
+		return nil, errors.New("Неверный токен")
+	}
 
-		// 🔒 Проверка блокировки пользователя
-		user, err := getUserByID(userID)
-		if err != nil {
-			log.Printf("%s ❌ Ошибка при получении пользователя ID=%d: %v\n", time.Now().Format("2006/01/02 15:04:05"), userID, err)
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не найден"})
-			return
-		}
-		if user.IsBlocked {
-			log.Printf("%s 🚫 Пользователь ID=%d заблокирован\n", time.Now().Format("2006/01/02 15:04:05"), userID)
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Пользователь заблокирован"})
-			return
-		}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		log.Printf("%s ❌ Ошибка при чтении claims из токена\n", time.Now().Format("2006/01/02 15:04:05"))
+		return nil, errors.New("Ошибка при чтении токена")
+	}
 
-		log.Printf("%s ✅ Успешная аутентификация пользователя с ID %d\n", time.Now().Format("2006/01/02 15:04:05"), userID)
-		c.Set("user_id", userID)
-		c.Next()
+	userIDFloat, ok := claims["user_id"].(float64)
+	if !ok {
+		log.Printf("%s ❌ Неверный формат user_id в токене\n", time.Now().Format("2006/01/02 15:04:05"))
+		return nil, errors.New("Неверный формат user_id")
+	}
+	userID := int(userIDFloat)
+
+	session, err := getSessionByToken(tokenStr)
+	if err != nil {
+		log.Printf("%s ❌ Ошибка получения сессии по токену: %v\n", time.Now().Format("2006/01/02 15:04:05"), err)
+		return nil, errors.New("Сессия недействительна")
 	}
+	if session.Revoked {
+		log.Printf("%s ❌ Сессия с токеном %s была отозвана\n", time.Now().Format("2006/01/02 15:04:05"), tokenStr)
+		return nil, errors.New("Сессия недействительна")
+	}
+	if session.ExpiresAt.Before(time.Now()) {
+		log.Printf("%s ❌ Сессия с токеном %s истекла в %v\n", time.Now().Format("2006/01/02 15:04:05"), tokenStr, session.ExpiresAt)
+		return nil, errors.New("Сессия истекла")
+	}
+
+	// 🔒 Проверка блокировки пользователя
+	user, err := getUserByID(userID)
+	if err != nil {
+		log.Printf("%s ❌ Ошибка при получении пользователя ID=%d: %v\n", time.Now().Format("2006/01/02 15:04:05"), userID, err)
+		return nil, errors.New("Пользователь не найден")
+	}
+	if user.IsBlocked {
+		log.Printf("%s 🚫 Пользователь ID=%d заблокирован\n", time.Now().Format("2006/01/02 15:04:05"), userID)
+		return nil, ErrUserBlocked
+	}
+	return user, nil
 }
 
 func MeHandler(c *gin.Context) {
@@ -518,67 +536,6 @@ func checkAuthHandler(c *gin.Context) {
 	})
 }
 
-func generateTokens(userID int) (string, string, error) {
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": userID,
-		"exp":     time.Now().Add(2 * time.Hour).Unix(),
-	})
-	accessTokenStr, err := accessToken.SignedString(jwtSecret)
-	if err != nil {
-		return "", "", err
-	}
-
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": userID,
-		"exp":     time.Now().Add(7 * 24 * time.Hour).Unix(), // живёт дольше
-	})
-	refreshTokenStr, err := refreshToken.SignedString(jwtSecret)
-	if err != nil {
-		return "", "", err
-	}
-
-	return accessTokenStr, refreshTokenStr, nil
-}
-func refreshHandler(c *gin.Context) {
-	refreshTokenStr, err := c.Cookie("refresh_token")
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh токен не найден"})
-		return
-	}
-
-	token, err := jwt.Parse(refreshTokenStr, func(token *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
-	})
-	if err != nil || !token.Valid {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Неверный refresh токен"})
-		return
-	}
-
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Ошибка при чтении токена"})
-		return
-	}
-
-	userIDFloat, ok := claims["user_id"].(float64)
-	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Неверный user_id"})
-		return
-	}
-
-	userID := int(userIDFloat)
-	// Генерируем новый access_token
-	newAccess, _, err := generateTokens(userID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании access токена"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"access_token": newAccess,
-	})
-}
-
 type InviteRequest struct {
 	FirstName      string `json:"first_name"`
 	LastName       string `json:"last_name"`
@@ -621,8 +578,12 @@ func inviteHandler(c *gin.Context) {
 		return
 	}
 
-	// 👉 Получаем или создаём роль по имени
-	roleID, err := getOrCreateRoleID(req.Role)
+	// 👉 Роль должна существовать: новые роли создаются через POST /roles
+	roleID, err := getRoleID(inviter.OrganizationID, req.Role)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Роль не найдена"})
+		return
+	}
 	if err != nil {
 		log.Printf("Ошибка при получении ID роли %s: %v\n", req.Role, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при обработке роли"})
@@ -665,24 +626,13 @@ func inviteHandler(c *gin.Context) {
 }
 
 func sendInvitationEmail(toEmail string, token string) error {
-	smtpHost := os.Getenv("SMTP_HOST")
-	smtpPort := os.Getenv("SMTP_PORT")
-	smtpUser := os.Getenv("SMTP_USER")
-	smtpPass := os.Getenv("SMTP_PASS")
-	frontendURL := os.Getenv("FRONTEND_URL")
-
 	// Обновлённая ссылка на установку пароля
-	inviteLink := frontendURL + "/set-password?token=" + token
+	inviteLink := os.Getenv("FRONTEND_URL") + "/set-password?token=" + token
 
 	subject := "Приглашение в DocBuilder"
 	body := "Здравствуйте!\n\nВы приглашены в DocBuilder.\nПерейдите по ссылке, чтобы завершить регистрацию:\n" + inviteLink
 
-	msg := "From: " + smtpUser + "\n" +
-		"To: " + toEmail + "\n" +
-		"Subject: " + subject + "\n\n" + body
-
-	auth := smtp.PlainAuth("", smtpUser, smtpPass, smtpHost)
-	return smtp.SendMail(smtpHost+":"+smtpPort, auth, smtpUser, []string{toEmail}, []byte(msg))
+	return sendMail(toEmail, subject, body)
 }
 
 type SetPasswordRequest struct {
@@ -697,6 +647,11 @@ func setPasswordHandler(c *gin.Context) {
 		return
 	}
 
+	if err := validatePassword(req.Password); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	user, err := getUserByToken(req.Token)
 	if err != nil || user == nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Недействительный или просроченный токен"})
@@ -727,22 +682,15 @@ func setPasswordHandler(c *gin.Context) {
 		return
 	}
 
-	// ✅ Генерируем токены
-	accessToken, refreshToken, err := generateTokens(user.ID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании токенов"})
-		return
-	}
-
-	// ✅ Создаём сессию
-	err = createSession(user.ID, accessToken, time.Now().Add(15*time.Minute))
+	// ✅ Создаём сессию и токены
+	accessToken, refreshToken, err := startSession(user.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при сохранении сессии"})
 		return
 	}
 
 	// ✅ Устанавливаем refresh-токен в куки
-	c.SetCookie("refresh_token", refreshToken, 7*24*60*60, "/", "localhost", false, true)
+	setRefreshCookie(c, refreshToken)
 
 	// ✅ Отправляем клиенту access-токен и user_id
 	c.JSON(http.StatusOK, gin.H{
@@ -828,8 +776,9 @@ func getInvitedUsersHandler(c *gin.Context) {
 }
 
 type AssignRoleRequest struct {
-	UserIDs []int  `json:"user_ids"` // список пользователей
-	Role    string `json:"role"`     // новая роль: "Менеджер", "Администратор", "Владелец" и т.д.
+	UserIDs           []int  `json:"user_ids"`           // список пользователей
+	Role              string `json:"role"`               // новая роль: "Менеджер", "Администратор", "Владелец" и т.д.
+	TransferOwnership bool   `json:"transfer_ownership"` // передача владения: владелец назначает нового, сам становится администратором
 }
 
 type Role struct {
@@ -837,6 +786,7 @@ type Role struct {
 	Name string `json:"name"`
 }
 
+// assignRoleHandler назначает роль пользователям организации (право roles.manage)
 func assignRoleHandler(c *gin.Context) {
 	var req AssignRoleRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -845,52 +795,63 @@ func assignRoleHandler(c *gin.Context) {
 		return
 	}
 
-	// 🔐 Авторизованный пользователь
-	adminID := c.GetInt("user_id")
-	admin, err := getUserByID(adminID)
-	if err != nil {
-		log.Printf("❌ Ошибка получения администратора ID=%d: %v\n", adminID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка авторизации"})
+	// ⚠️ Проверка: нельзя назначить пустую роль
+	if req.Role == "" || len(req.UserIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Не указана роль или список пользователей"})
 		return
 	}
 
-	// 🔒 Только владелец (1) или админ (2) имеют право менять роли
-	if admin.RoleID != 1 && admin.RoleID != 2 {
-		log.Printf("⛔ Недостаточно прав: user_id=%d, role_id=%d\n", admin.ID, admin.RoleID)
-		c.JSON(http.StatusForbidden, gin.H{"error": "Недостаточно прав для назначения ролей"})
+	orgID := c.GetInt("organization_id")
+	roleID, err := getRoleID(orgID, req.Role)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Роль не найдена"})
 		return
 	}
-
-	// ⚠️ Проверка: нельзя назначить пустую роль
-	if req.Role == "" || len(req.UserIDs) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Не указана роль или список пользователей"})
+	if err != nil {
+		log.Printf("❌ Ошибка при получении роли %s: %v\n", req.Role, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при обработке роли"})
 		return
 	}
 
-	// ✅ Создаём роль при необходимости
-	roleID, err := ensureRoleExists(req.Role)
-	if err != nil {
-		log.Printf("❌ Ошибка при проверке/создании роли %s: %v\n", req.Role, err)
+	owner, err := findUserByRole(orgID, RoleOwner)
+	if err == sql.ErrNoRows {
+		owner = nil
+	} else if err != nil {
+		log.Printf("❌ Ошибка при получении владельца организации ID=%d: %v\n", orgID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при обработке роли"})
 		return
 	}
 
-	// 🧠 Только один владелец
-	if req.Role == "Владелец" {
-		existingOwner, err := findUserByRole("Владелец")
-		if err == nil && existingOwner != nil {
-			for _, userID := range req.UserIDs {
-				if existingOwner.ID != userID {
-					c.JSON(http.StatusBadRequest, gin.H{"error": "Роль 'Владелец' уже назначена другому пользователю"})
-					return
-				}
+	if req.TransferOwnership {
+		transferOwnershipTo(c, req, owner)
+		return
+	}
+
+	// 🧠 Только один владелец в организации; роль владельца меняется только передачей владения
+	if req.Role == RoleOwner && len(req.UserIDs) > 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Роль 'Владелец' можно назначить только одному пользователю"})
+		return
+	}
+	if owner != nil {
+		for _, userID := range req.UserIDs {
+			if req.Role == RoleOwner && userID != owner.ID {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Роль 'Владелец' уже назначена другому пользователю"})
+				return
+			}
+			if req.Role != RoleOwner && userID == owner.ID {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Роль владельца меняется только передачей владения"})
+				return
 			}
 		}
 	}
 
 	// ✅ Назначаем роль всем пользователям
 	for _, userID := range req.UserIDs {
-		err := updateUserRole(userID, req.Role)
+		err := updateUserRole(orgID, userID, roleID)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Пользователь ID=%d не найден", userID)})
+			return
+		}
 		if err != nil {
 			log.Printf("❌ Ошибка при обновлении роли для пользователя ID=%d: %v\n", userID, err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось обновить роль пользователя"})
@@ -898,12 +859,44 @@ func assignRoleHandler(c *gin.Context) {
 		}
 	}
 
-	log.Printf("✅ Назначена роль '%s' (ID=%d) пользователям: %v\n", req.Role, roleID, req.UserIDs)
+	logRoleChange(c, RoleActionAssign, req.Role, fmt.Sprintf("пользователи %v", req.UserIDs))
 	c.JSON(http.StatusOK, gin.H{"message": "Роль успешно обновлена"})
 }
 
+// transferOwnershipTo передаёт владение организацией: запрос делает сам владелец,
+// новый владелец — единственный пользователь из запроса
+func transferOwnershipTo(c *gin.Context, req AssignRoleRequest, owner *User) {
+	if req.Role != RoleOwner || len(req.UserIDs) != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Для передачи владения укажите роль 'Владелец' и одного пользователя"})
+		return
+	}
+	if owner == nil || owner.ID != c.GetInt("user_id") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Передать владение может только владелец организации"})
+		return
+	}
+	newOwnerID := req.UserIDs[0]
+	if newOwnerID == owner.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Вы уже владелец организации"})
+		return
+	}
+
+	err := transferOwnership(c.GetInt("organization_id"), owner.ID, newOwnerID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Пользователь ID=%d не найден", newOwnerID)})
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Ошибка при передаче владения пользователю ID=%d: %v\n", newOwnerID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось передать владение"})
+		return
+	}
+
+	logRoleChange(c, RoleActionTransfer, RoleOwner, fmt.Sprintf("от пользователя %d пользователю %d", owner.ID, newOwnerID))
+	c.JSON(http.StatusOK, gin.H{"message": "Владение передано"})
+}
+
 func getAllUsersHandler(c *gin.Context) {
-	users, err := getAllUsers()
+	users, err := getAllUsers(c.GetInt("organization_id"))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить пользователей"})
 		return
@@ -921,14 +914,14 @@ func getAllUsersHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, result)
 }
 
-func findUserByRole(roleName string) (*User, error) {
+func findUserByRole(orgID int, roleName string) (*User, error) {
 	row := db.QueryRow(`
 		SELECT u.id, u.email, u.first_name, u.last_name, u.role_id, r.name
 		FROM users u
 		JOIN roles r ON r.id = u.role_id
-		WHERE r.name = $1
+		WHERE r.name = $1 AND u.organization_id = $2
 		LIMIT 1
-	`, roleName)
+	`, roleName, orgID)
 
 	var user User
 	err := row.Scan(&user.ID, &user.Email, &user.FirstName, &user.LastName, &user.RoleID, &user.RoleName)
@@ -938,8 +931,9 @@ func findUserByRole(roleName string) (*User, error) {
 	return &user, nil
 }
 
+// getAllRolesHandler возвращает роли организации пользователя
 func getAllRolesHandler(c *gin.Context) {
-	rows, err := db.Query("SELECT id, name FROM roles")
+	rows, err := db.Query("SELECT id, name FROM roles WHERE organization_id = $1 ORDER BY id", c.GetInt("organization_id"))
 	if err != nil {
 		log.Printf("Ошибка при получении ролей: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении ролей"})
@@ -961,16 +955,55 @@ func getAllRolesHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, roles)
 }
 
-func ensureRoleExists(roleName string) (int, error) {
-	var roleID int
-	err := db.QueryRow("SELECT id FROM roles WHERE name = $1", roleName).Scan(&roleID)
-	if err == sql.ErrNoRows {
-		// роли нет — создаём
-		err = db.QueryRow("INSERT INTO roles (name) VALUES ($1) RETURNING id", roleName).Scan(&roleID)
+type CreateRoleRequest struct {
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+}
+
+// createRoleHandler создаёт роль организации с набором прав
+func createRoleHandler(c *gin.Context) {
+	var req CreateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Не указано имя роли"})
+		return
+	}
+	for _, p := range req.Permissions {
+		if !isPermission(p) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": ErrUnknownPermission.Error(), "permission": p})
+			return
+		}
+	}
+
+	orgID := c.GetInt("organization_id")
+	roleID, err := createRole(orgID, req.Name)
+	if err == ErrRoleExists {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Ошибка при создании роли %s: %v\n", req.Name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании роли"})
+		return
+	}
+
+	if err := setRolePermissions(orgID, roleID, req.Permissions); err != nil {
+		log.Printf("❌ Ошибка сохранения прав роли ID=%d: %v\n", roleID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при сохранении прав роли"})
+		return
 	}
-	return roleID, err
+
+	rp, err := getRolePermissions(orgID, roleID)
+	if err != nil {
+		log.Printf("❌ Ошибка получения прав роли ID=%d: %v\n", roleID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении прав роли"})
+		return
+	}
+	logRoleChange(c, RoleActionCreate, req.Name, strings.Join(rp.Permissions, ", "))
+	c.JSON(http.StatusCreated, rp)
 }
 
+// renameRoleHandler переименовывает роль организации (право roles.manage).
+// Встроенные роли не переименовываются.
 func renameRoleHandler(c *gin.Context) {
 	var req RenameRoleRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -978,31 +1011,35 @@ func renameRoleHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат запроса"})
 		return
 	}
+	req.NewName = strings.TrimSpace(req.NewName)
+	if req.OldName == "" || req.NewName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Не указано имя роли"})
+		return
+	}
 
-	// Проверка существования старой роли
-	var roleID int
-	err := db.QueryRow("SELECT id FROM roles WHERE name = $1", req.OldName).Scan(&roleID)
-	if err == sql.ErrNoRows {
+	err := renameRole(c.GetInt("organization_id"), req.OldName, req.NewName)
+	switch {
+	case err == sql.ErrNoRows:
 		c.JSON(http.StatusNotFound, gin.H{"error": "Роль не найдена"})
 		return
-	} else if err != nil {
-		log.Printf("Ошибка при поиске роли: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при проверке роли"})
+	case err == ErrRoleBuiltin:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
-	}
-
-	// Обновление имени роли
-	_, err = db.Exec("UPDATE roles SET name = $1 WHERE id = $2", req.NewName, roleID)
-	if err != nil {
+	case err == ErrRoleExists:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
 		log.Printf("Ошибка при переименовании роли: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось переименовать роль"})
 		return
 	}
 
-	log.Printf("✅ Роль '%s' переименована в '%s'\n", req.OldName, req.NewName)
+	logRoleChange(c, RoleActionRename, req.NewName, fmt.Sprintf("прежнее имя '%s'", req.OldName))
 	c.JSON(http.StatusOK, gin.H{"message": "Роль успешно переименована"})
 }
 
+// deleteRoleHandler удаляет роль организации (право roles.manage). Встроенные
+// роли и роли, назначенные пользователям, не удаляются.
 func deleteRoleHandler(c *gin.Context) {
 	var req DeleteRoleRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -1011,89 +1048,83 @@ func deleteRoleHandler(c *gin.Context) {
 		return
 	}
 
-	// Проверяем, существует ли роль
-	var roleID int
-	err := db.QueryRow("SELECT id FROM roles WHERE name = $1", req.Name).Scan(&roleID)
-	if err == sql.ErrNoRows {
+	err := deleteRole(c.GetInt("organization_id"), req.Name)
+	switch {
+	case err == sql.ErrNoRows:
 		c.JSON(http.StatusNotFound, gin.H{"error": "Роль не найдена"})
 		return
-	} else if err != nil {
-		log.Printf("Ошибка при поиске роли: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при проверке роли"})
-		return
-	}
-
-	// Проверка: никто не должен использовать эту роль
-	var userCount int
-	err = db.QueryRow("SELECT COUNT(*) FROM users WHERE role_id = $1", roleID).Scan(&userCount)
-	if err != nil {
-		log.Printf("Ошибка при проверке использования роли: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось проверить роль"})
+	case err == ErrRoleBuiltin:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
-	}
-	if userCount > 0 {
+	case err == ErrRoleInUse:
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Нельзя удалить роль, которая назначена пользователям"})
 		return
-	}
-
-	// Удаляем роль
-	_, err = db.Exec("DELETE FROM roles WHERE id = $1", roleID)
-	if err != nil {
+	case err != nil:
 		log.Printf("Ошибка при удалении роли: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось удалить роль"})
 		return
 	}
 
-	log.Printf("🗑️ Роль '%s' удалена\n", req.Name)
+	logRoleChange(c, RoleActionDelete, req.Name, "")
 	c.JSON(http.StatusOK, gin.H{"message": "Роль успешно удалена"})
 }
 
 func blockUserHandler(c *gin.Context) {
-	adminID := c.GetInt("user_id")
-	admin, err := getUserByID(adminID)
-	if err != nil {
-		log.Printf("❌ Ошибка получения администратора ID=%d: %v\n", adminID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка авторизации"})
-		return
-	}
-
-	log.Printf("👤 Попытка блокировки: AdminID=%d, RoleID=%d, RoleName=%s, IsOwner=%v\n",
-		admin.ID, admin.RoleID, admin.RoleName, admin.IsOwner)
+	setBlockedHandler(c, true)
+}
 
-	// ✅ Только Владелец (1) и Администратор (2)
-	if admin.RoleID != 1 && admin.RoleID != 2 {
-		log.Printf("⛔ Недостаточно прав: RoleID=%d\n", admin.RoleID)
-		c.JSON(http.StatusForbidden, gin.H{"error": "Недостаточно прав"})
-		return
-	}
+// unblockUserHandler разблокирует пользователя организации (право users.manage)
+func unblockUserHandler(c *gin.Context) {
+	setBlockedHandler(c, false)
+}
 
+func setBlockedHandler(c *gin.Context, blocked bool) {
+	adminID := c.GetInt("user_id")
 	userIDStr := c.Param("id")
 	userID, err := strconv.Atoi(userIDStr)
-	if err != nil {
+	if err != nil || userID == 0 {
 		log.Printf("❌ Неверный ID пользователя: %s\n", userIDStr)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID"})
 		return
 	}
+	if blocked && adminID == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Нельзя заблокировать самого себя"})
+		return
+	}
+
+	action := "разблокировки"
+	if blocked {
+		action = "блокировки"
+	}
+	log.Printf("👤 Попытка %s: AdminID=%d, UserID=%d\n", action, adminID, userID)
 
-	err = setUserBlockedStatus(userID, true)
+	err = setUserBlockedStatus(c.GetInt("organization_id"), userID, blocked)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Пользователь не найден"})
+		return
+	}
 	if err != nil {
-		log.Printf("❌ Ошибка при блокировке пользователя ID=%d: %v\n", userID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось заблокировать пользователя"})
+		log.Printf("❌ Ошибка при изменении блокировки пользователя ID=%d: %v\n", userID, err)
+		if blocked {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось заблокировать пользователя"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при разблокировке"})
+		}
 		return
 	}
 
-	log.Printf("✅ Пользователь ID=%d успешно заблокирован\n", userID)
-	c.JSON(http.StatusOK, gin.H{"message": "Пользователь заблокирован"})
+	if blocked {
+		log.Printf("✅ Пользователь ID=%d успешно заблокирован\n", userID)
+		c.JSON(http.StatusOK, gin.H{"message": "Пользователь заблокирован"})
+	} else {
+		log.Printf("✅ Пользователь ID=%d успешно разблокирован\n", userID)
+		c.JSON(http.StatusOK, gin.H{"message": "Пользователь разблокирован"})
+	}
 }
 
+// deleteUserHandler удаляет пользователя организации (право users.manage)
 func deleteUserHandler(c *gin.Context) {
 	adminID := c.GetInt("user_id")
-	admin, err := getUserByID(adminID)
-	if err != nil || (!admin.IsOwner && admin.RoleName != "Администратор") {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Недостаточно прав"})
-		return
-	}
-
 	userIDStr := c.Param("id")
 	userID, err := strconv.Atoi(userIDStr)
 	if err != nil {
@@ -1107,52 +1138,17 @@ func deleteUserHandler(c *gin.Context) {
 		return
 	}
 
-	_, err = db.Exec("DELETE FROM users WHERE id = $1", userID)
+	res, err := db.Exec("DELETE FROM users WHERE id = $1 AND organization_id = $2", userID, c.GetInt("organization_id"))
 	if err != nil {
 		log.Printf("❌ Ошибка при удалении пользователя ID=%d: %v\n", userID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось удалить пользователя"})
 		return
 	}
-
-	log.Printf("🗑️ Пользователь ID=%d успешно удалён\n", userID)
-	c.JSON(http.StatusOK, gin.H{"message": "Пользователь удалён"})
-}
-
-func unblockUserHandler(c *gin.Context) {
-	adminID := c.GetInt("user_id")
-	admin, err := getUserByID(adminID)
-	if err != nil {
-		log.Printf("❌ Ошибка получения администратора ID=%d: %v\n", adminID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка авторизации"})
-		return
-	}
-
-	// 🐞 Логируем данные о текущем пользователе
-	log.Printf("👤 Попытка разблокировки: AdminID=%d, RoleID=%d, RoleName=%s, IsOwner=%v\n",
-		admin.ID, admin.RoleID, admin.RoleName, admin.IsOwner)
-
-	// ✅ Только Владелец (1) и Администратор (2)
-	if admin.RoleID != 1 && admin.RoleID != 2 {
-		log.Printf("⛔ Недостаточно прав: RoleID=%d\n", admin.RoleID)
-		c.JSON(http.StatusForbidden, gin.H{"error": "Недостаточно прав"})
-		return
-	}
-
-	userIDParam := c.Param("id")
-	userID, err := strconv.Atoi(userIDParam)
-	if err != nil || userID == 0 {
-		log.Printf("❌ Некорректный ID пользователя: %s\n", userIDParam)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID пользователя"})
-		return
-	}
-
-	_, err = db.Exec("UPDATE users SET is_blocked = FALSE WHERE id = $1", userID)
-	if err != nil {
-		log.Printf("❌ Ошибка при разблокировке пользователя ID=%d: %v\n", userID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при разблокировке"})
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Пользователь не найден"})
 		return
 	}
 
-	log.Printf("✅ Пользователь ID=%d успешно разблокирован\n", userID)
-	c.JSON(http.StatusOK, gin.H{"message": "Пользователь разблокирован"})
+	log.Printf("🗑️ Пользователь ID=%d успешно удалён\n", userID)
+	c.JSON(http.StatusOK, gin.H{"message": "Пользователь удалён"})
 }
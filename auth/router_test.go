@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+// В организации 1 владелец — пользователь 1, администратор — пользователь 2
+const (
+	orgID   = 1
+	ownerID = 1
+	adminID = 2
+)
+
+func mockDB(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		database.Close()
+	})
+	InitAuth(database)
+	return mock
+}
+
+func expectRoleAndOwner(mock sqlmock.Sqlmock, role string) {
+	mock.ExpectQuery(`SELECT id FROM roles WHERE organization_id = \$1 AND name = \$2`).
+		WithArgs(orgID, role).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10))
+	mock.ExpectQuery(`FROM users u\s+JOIN roles r ON r.id = u.role_id\s+WHERE r.name = \$1 AND u.organization_id = \$2`).
+		WithArgs(RoleOwner, orgID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "role_id", "name"}).
+			AddRow(ownerID, "owner@example.com", "Анна", "Петрова", 10, RoleOwner))
+}
+
+func assignRole(userID int, body string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/auth/users/assign-role", strings.NewReader(body))
+	c.Set("organization_id", orgID)
+	c.Set("user_id", userID)
+	assignRoleHandler(c)
+	return w
+}
+
+// Роль владельца не меняется обычным назначением и не передаётся никем, кроме
+// самого владельца; sqlmock отклонил бы любое изменение пользователей
+func TestAssignRoleProtectsOwner(t *testing.T) {
+	tests := []struct {
+		name   string
+		caller int
+		role   string
+		body   string
+		want   int
+	}{
+		{name: "администратор понижает владельца", caller: adminID, role: RoleAdmin,
+			body: `{"user_ids":[3,1],"role":"Администратор"}`, want: http.StatusForbidden},
+		{name: "владелец понижает себя", caller: ownerID, role: RoleEmployee,
+			body: `{"user_ids":[1],"role":"Сотрудник"}`, want: http.StatusForbidden},
+		{name: "второй владелец", caller: adminID, role: RoleOwner,
+			body: `{"user_ids":[3],"role":"Владелец"}`, want: http.StatusBadRequest},
+		{name: "передача владения не владельцем", caller: adminID, role: RoleOwner,
+			body: `{"user_ids":[2],"role":"Владелец","transfer_ownership":true}`, want: http.StatusForbidden},
+		{name: "передача владения нескольким", caller: ownerID, role: RoleOwner,
+			body: `{"user_ids":[2,3],"role":"Владелец","transfer_ownership":true}`, want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := mockDB(t)
+			expectRoleAndOwner(mock, tt.role)
+
+			if w := assignRole(tt.caller, tt.body); w.Code != tt.want {
+				t.Errorf("код ответа %d, ожидалось %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestAssignRoleTransferOwnership(t *testing.T) {
+	mock := mockDB(t)
+	expectRoleAndOwner(mock, RoleOwner)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE users SET is_owner = TRUE`).
+		WithArgs(adminID, orgID, RoleOwner).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE users SET is_owner = FALSE`).
+		WithArgs(ownerID, orgID, RoleAdmin).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(`INSERT INTO role_changes`).
+		WithArgs(orgID, ownerID, RoleActionTransfer, RoleOwner, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	w := assignRole(ownerID, `{"user_ids":[2],"role":"Владелец","transfer_ownership":true}`)
+	if w.Code != http.StatusOK {
+		t.Errorf("код ответа %d, ожидалось 200: %s", w.Code, w.Body)
+	}
+}
+
+func TestAssignRoleOtherUsers(t *testing.T) {
+	mock := mockDB(t)
+	expectRoleAndOwner(mock, RoleManager)
+	mock.ExpectExec(`UPDATE users SET role_id = \$1 WHERE id = \$2 AND organization_id = \$3`).
+		WithArgs(10, 3, orgID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO role_changes`).
+		WithArgs(orgID, adminID, RoleActionAssign, RoleManager, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	w := assignRole(adminID, `{"user_ids":[3],"role":"Менеджер"}`)
+	if w.Code != http.StatusOK {
+		t.Errorf("код ответа %d, ожидалось 200: %s", w.Code, w.Body)
+	}
+}
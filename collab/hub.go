@@ -0,0 +1,322 @@
+// Package collab — канал совместного редактирования документов. Hub держит в памяти
+// процесса комнаты по документам: кто сейчас открыл документ, мягкие блокировки полей
+// и рассылку изменений. Транспорт скрыт за интерфейсом Conn, поэтому хаб проверяется
+// без сети и внешних сервисов; адаптер для WebSocket — в ws.go.
+package collab
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Типы сообщений
+const (
+	// сервер → клиент
+	TypePresence   = "presence"    // Users и Locks — кто в документе и какие поля заняты
+	TypeField      = "field"       // сохранено значение поля Field
+	TypeContent    = "content"     // изменено содержимое документа
+	TypeLocked     = "locked"      // User занял поле Field до ExpiresAt
+	TypeUnlocked   = "unlocked"    // поле Field освобождено
+	TypeLockDenied = "lock_denied" // поле Field занято пользователем User
+	TypeError      = "error"
+
+	// клиент → сервер
+	TypeLock   = "lock"   // занять поле Field (повторный lock продлевает блокировку)
+	TypeUnlock = "unlock" // освободить поле Field
+)
+
+// DefaultLockTTL — сколько держится блокировка поля без продления
+const DefaultLockTTL = 30 * time.Second
+
+// sendBuffer — очередь исходящих сообщений клиента; переполнение означает,
+// что клиент не успевает читать, и он отключается
+const sendBuffer = 64
+
+// Participant — пользователь в документе
+type Participant struct {
+	UserID int    `json:"user_id"`
+	Name   string `json:"name"`
+}
+
+// Message — сообщение канала в обе стороны
+type Message struct {
+	Type       string                 `json:"type"`
+	DocumentID int                    `json:"document_id,omitempty"`
+	User       *Participant           `json:"user,omitempty"`
+	Field      string                 `json:"field,omitempty"`
+	Value      *string                `json:"value,omitempty"`
+	Content    *string                `json:"content,omitempty"`
+	Version    int                    `json:"version,omitempty"`
+	Users      []Participant          `json:"users,omitempty"`
+	Locks      map[string]Participant `json:"locks,omitempty"`
+	ExpiresAt  *time.Time             `json:"expires_at,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+}
+
+// Conn — двунаправленное соединение с клиентом. *websocket.Conn подходит напрямую;
+// ReadJSON и WriteJSON вызываются каждый из своей горутины.
+type Conn interface {
+	ReadJSON(v interface{}) error
+	WriteJSON(v interface{}) error
+	Close() error
+}
+
+// Client — одно подключение пользователя к документу
+type Client struct {
+	hub        *Hub
+	documentID int
+	user       Participant
+	conn       Conn
+	send       chan Message
+	closed     bool // под hub.mu
+}
+
+type fieldLock struct {
+	holder  *Client
+	expires time.Time
+}
+
+type room struct {
+	clients map[*Client]bool
+	locks   map[string]fieldLock
+}
+
+// Hub — комнаты документов текущего процесса
+type Hub struct {
+	mu      sync.Mutex
+	rooms   map[int]*room
+	lockTTL time.Duration
+	now     func() time.Time
+}
+
+// NewHub создаёт хаб с временем жизни блокировок DefaultLockTTL
+func NewHub() *Hub {
+	return &Hub{rooms: make(map[int]*room), lockTTL: DefaultLockTTL, now: time.Now}
+}
+
+// Join подключает клиента к документу, запускает отправку сообщений
+// и рассылает участникам обновлённый список присутствующих
+func (h *Hub) Join(documentID int, user Participant, conn Conn) *Client {
+	c := &Client{hub: h, documentID: documentID, user: user, conn: conn, send: make(chan Message, sendBuffer)}
+	go c.writeLoop()
+
+	h.mu.Lock()
+	r := h.rooms[documentID]
+	if r == nil {
+		r = &room{clients: make(map[*Client]bool), locks: make(map[string]fieldLock)}
+		h.rooms[documentID] = r
+	}
+	r.clients[c] = true
+	h.broadcastLocked(documentID, h.presenceLocked(documentID))
+	h.mu.Unlock()
+	return c
+}
+
+// Run читает сообщения клиента до разрыва соединения, затем отключает его
+func (c *Client) Run() {
+	defer c.hub.Leave(c)
+	for {
+		var msg Message
+		if err := c.conn.ReadJSON(&msg); err != nil {
+			return
+		}
+		c.handle(msg)
+	}
+}
+
+func (c *Client) handle(msg Message) {
+	switch msg.Type {
+	case TypeLock:
+		if msg.Field == "" {
+			c.hub.sendTo(c, Message{Type: TypeError, Error: "не указано поле"})
+			return
+		}
+		c.hub.Lock(c, msg.Field)
+	case TypeUnlock:
+		c.hub.Unlock(c, msg.Field)
+	default:
+		c.hub.sendTo(c, Message{Type: TypeError, Error: "неизвестный тип сообщения: " + msg.Type})
+	}
+}
+
+func (c *Client) writeLoop() {
+	for msg := range c.send {
+		if err := c.conn.WriteJSON(msg); err != nil {
+			// чтение в Run получит ошибку и отключит клиента
+			c.conn.Close()
+		}
+	}
+}
+
+// Leave отключает клиента: освобождает его блокировки и обновляет присутствие
+func (h *Hub) Leave(c *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.removeLocked(c)
+}
+
+// removeLocked вызывается под h.mu
+func (h *Hub) removeLocked(c *Client) {
+	if c.closed {
+		return
+	}
+	c.closed = true
+	close(c.send)
+	c.conn.Close()
+
+	r := h.rooms[c.documentID]
+	if r == nil {
+		return
+	}
+	delete(r.clients, c)
+	for field, l := range r.locks {
+		if l.holder == c {
+			delete(r.locks, field)
+			h.broadcastLocked(c.documentID, Message{Type: TypeUnlocked, DocumentID: c.documentID, Field: field})
+		}
+	}
+	if len(r.clients) == 0 {
+		delete(h.rooms, c.documentID)
+		return
+	}
+	h.broadcastLocked(c.documentID, h.presenceLocked(c.documentID))
+}
+
+// Lock занимает поле за пользователем клиента. Другие подключения того же
+// пользователя блокировке не мешают. Возвращает false и владельца, если поле занято.
+func (h *Hub) Lock(c *Client, field string) (Participant, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r := h.rooms[c.documentID]
+	if r == nil || c.closed {
+		return Participant{}, false
+	}
+	now := h.now()
+	if l, ok := r.locks[field]; ok && l.expires.After(now) && l.holder.user.UserID != c.user.UserID {
+		holder := l.holder.user
+		h.sendLocked(c, Message{Type: TypeLockDenied, DocumentID: c.documentID, Field: field, User: &holder, ExpiresAt: &l.expires})
+		return holder, false
+	}
+
+	expires := now.Add(h.lockTTL)
+	r.locks[field] = fieldLock{holder: c, expires: expires}
+	user := c.user
+	h.broadcastLocked(c.documentID, Message{Type: TypeLocked, DocumentID: c.documentID, Field: field, User: &user, ExpiresAt: &expires})
+	return user, true
+}
+
+// Unlock освобождает поле, если его занимает пользователь клиента
+func (h *Hub) Unlock(c *Client, field string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r := h.rooms[c.documentID]
+	if r == nil {
+		return
+	}
+	if l, ok := r.locks[field]; ok && l.holder.user.UserID == c.user.UserID {
+		delete(r.locks, field)
+		h.broadcastLocked(c.documentID, Message{Type: TypeUnlocked, DocumentID: c.documentID, Field: field})
+	}
+}
+
+// LockHolder возвращает пользователя, занявшего поле документа
+func (h *Hub) LockHolder(documentID int, field string) (Participant, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r := h.rooms[documentID]
+	if r == nil {
+		return Participant{}, false
+	}
+	l, ok := r.locks[field]
+	if !ok || !l.expires.After(h.now()) {
+		return Participant{}, false
+	}
+	return l.holder.user, true
+}
+
+// Presence возвращает пользователей, открывших документ, по одному на пользователя
+func (h *Hub) Presence(documentID int) []Participant {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.presenceLocked(documentID).Users
+}
+
+// presenceLocked собирает сообщение presence; вызывается под h.mu.
+// Просроченные блокировки при этом удаляются.
+func (h *Hub) presenceLocked(documentID int) Message {
+	msg := Message{Type: TypePresence, DocumentID: documentID, Users: []Participant{}, Locks: map[string]Participant{}}
+	r := h.rooms[documentID]
+	if r == nil {
+		return msg
+	}
+
+	seen := make(map[int]bool)
+	for c := range r.clients {
+		if !seen[c.user.UserID] {
+			seen[c.user.UserID] = true
+			msg.Users = append(msg.Users, c.user)
+		}
+	}
+	sort.Slice(msg.Users, func(i, j int) bool { return msg.Users[i].UserID < msg.Users[j].UserID })
+
+	now := h.now()
+	for field, l := range r.locks {
+		if !l.expires.After(now) {
+			delete(r.locks, field)
+			continue
+		}
+		msg.Locks[field] = l.holder.user
+	}
+	return msg
+}
+
+// Broadcast рассылает сообщение всем, кто открыл документ
+func (h *Hub) Broadcast(documentID int, msg Message) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	msg.DocumentID = documentID
+	h.broadcastLocked(documentID, msg)
+}
+
+// BroadcastField сообщает о сохранённом значении поля
+func (h *Hub) BroadcastField(documentID int, user Participant, field, value string) {
+	h.Broadcast(documentID, Message{Type: TypeField, User: &user, Field: field, Value: &value})
+}
+
+// BroadcastContent сообщает о новом содержимом документа и его версии
+func (h *Hub) BroadcastContent(documentID int, user Participant, content string, version int) {
+	h.Broadcast(documentID, Message{Type: TypeContent, User: &user, Content: &content, Version: version})
+}
+
+func (h *Hub) sendTo(c *Client, msg Message) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sendLocked(c, msg)
+}
+
+// broadcastLocked и sendLocked вызываются под h.mu
+func (h *Hub) broadcastLocked(documentID int, msg Message) {
+	r := h.rooms[documentID]
+	if r == nil {
+		return
+	}
+	for c := range r.clients {
+		h.sendLocked(c, msg)
+	}
+}
+
+func (h *Hub) sendLocked(c *Client, msg Message) {
+	if c.closed {
+		return
+	}
+	select {
+	case c.send <- msg:
+	default:
+		// клиент не успевает читать — отключаем, чтобы не задерживать остальных
+		h.removeLocked(c)
+	}
+}
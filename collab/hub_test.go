@@ -0,0 +1,221 @@
+package collab
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeConn — соединение в памяти: ReadJSON ждёт закрытия, WriteJSON складывает
+// отправленные сообщения в канал
+type fakeConn struct {
+	out       chan Message
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newFakeConn() *fakeConn {
+	return &fakeConn{out: make(chan Message, sendBuffer), done: make(chan struct{})}
+}
+
+func (f *fakeConn) ReadJSON(v interface{}) error {
+	<-f.done
+	return errors.New("соединение закрыто")
+}
+
+func (f *fakeConn) WriteJSON(v interface{}) error {
+	f.out <- v.(Message)
+	return nil
+}
+
+func (f *fakeConn) Close() error {
+	f.closeOnce.Do(func() { close(f.done) })
+	return nil
+}
+
+// next возвращает следующее сообщение клиенту, пропуская другие типы
+func (f *fakeConn) next(t *testing.T, msgType string) Message {
+	t.Helper()
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case msg := <-f.out:
+			if msg.Type == msgType {
+				return msg
+			}
+		case <-timeout:
+			t.Fatalf("не получено сообщение %s", msgType)
+		}
+	}
+}
+
+// drain ждёт, пока клиент получит все уже отправленные ему сообщения, и отбрасывает их
+func (f *fakeConn) drain() {
+	for {
+		select {
+		case <-f.out:
+		case <-time.After(50 * time.Millisecond):
+			return
+		}
+	}
+}
+
+var (
+	alice = Participant{UserID: 1, Name: "Алиса"}
+	bob   = Participant{UserID: 2, Name: "Боб"}
+)
+
+func join(h *Hub, documentID int, user Participant) (*Client, *fakeConn) {
+	conn := newFakeConn()
+	return h.Join(documentID, user, conn), conn
+}
+
+func TestBroadcastReachesOnlyDocumentRoom(t *testing.T) {
+	h := NewHub()
+	_, a := join(h, 1, alice)
+	_, b := join(h, 1, bob)
+	_, other := join(h, 2, bob)
+	a.drain()
+	b.drain()
+	other.drain()
+
+	h.BroadcastField(1, alice, "client_name", "ООО Ромашка")
+
+	for _, conn := range []*fakeConn{a, b} {
+		msg := conn.next(t, TypeField)
+		if msg.DocumentID != 1 || msg.Field != "client_name" || msg.Value == nil || *msg.Value != "ООО Ромашка" {
+			t.Errorf("неверное сообщение field: %+v", msg)
+		}
+	}
+	select {
+	case msg := <-other.out:
+		t.Errorf("сообщение документа 1 ушло в документ 2: %+v", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestPresenceListsEachUserOnce(t *testing.T) {
+	h := NewHub()
+	_, a := join(h, 1, alice)
+	join(h, 1, alice) // вторая вкладка того же пользователя
+	join(h, 1, bob)
+
+	users := h.Presence(1)
+	if len(users) != 2 || users[0] != alice || users[1] != bob {
+		t.Errorf("Presence = %v, ожидались Алиса и Боб", users)
+	}
+
+	var last Message
+	for i := 0; i < 3; i++ {
+		last = a.next(t, TypePresence)
+	}
+	if len(last.Users) != 2 {
+		t.Errorf("presence после подключения Боба: %v", last.Users)
+	}
+}
+
+func TestLockAcquireDenyRelease(t *testing.T) {
+	h := NewHub()
+	ca, a := join(h, 1, alice)
+	cb, b := join(h, 1, bob)
+	a.drain()
+	b.drain()
+
+	if _, ok := h.Lock(ca, "amount"); !ok {
+		t.Fatal("Алиса не смогла занять свободное поле")
+	}
+	if msg := b.next(t, TypeLocked); msg.Field != "amount" || msg.User == nil || *msg.User != alice {
+		t.Errorf("неверное сообщение locked: %+v", msg)
+	}
+	if holder, ok := h.LockHolder(1, "amount"); !ok || holder != alice {
+		t.Errorf("LockHolder = %v, %v; ожидалась Алиса", holder, ok)
+	}
+
+	holder, ok := h.Lock(cb, "amount")
+	if ok || holder != alice {
+		t.Fatalf("Боб занял поле Алисы: %v, %v", holder, ok)
+	}
+	if msg := b.next(t, TypeLockDenied); msg.User == nil || *msg.User != alice {
+		t.Errorf("неверное сообщение lock_denied: %+v", msg)
+	}
+
+	h.Unlock(cb, "amount") // чужую блокировку снять нельзя
+	if _, ok := h.LockHolder(1, "amount"); !ok {
+		t.Fatal("Боб снял блокировку Алисы")
+	}
+
+	h.Unlock(ca, "amount")
+	if msg := b.next(t, TypeUnlocked); msg.Field != "amount" {
+		t.Errorf("неверное сообщение unlocked: %+v", msg)
+	}
+	if _, ok := h.Lock(cb, "amount"); !ok {
+		t.Error("Боб не смог занять освобождённое поле")
+	}
+}
+
+func TestLockSameUserOtherConnection(t *testing.T) {
+	h := NewHub()
+	first, _ := join(h, 1, alice)
+	second, _ := join(h, 1, alice)
+
+	h.Lock(first, "amount")
+	if _, ok := h.Lock(second, "amount"); !ok {
+		t.Error("вторая вкладка того же пользователя не смогла продлить блокировку")
+	}
+}
+
+func TestLockExpires(t *testing.T) {
+	h := NewHub()
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	h.now = func() time.Time { return now }
+
+	ca, _ := join(h, 1, alice)
+	cb, _ := join(h, 1, bob)
+	h.Lock(ca, "amount")
+
+	now = now.Add(DefaultLockTTL)
+	if _, ok := h.LockHolder(1, "amount"); ok {
+		t.Error("блокировка не истекла через DefaultLockTTL")
+	}
+	if _, ok := h.Lock(cb, "amount"); !ok {
+		t.Error("Боб не смог занять поле с истёкшей блокировкой")
+	}
+}
+
+func TestLeaveReleasesLocks(t *testing.T) {
+	h := NewHub()
+	ca, a := join(h, 1, alice)
+	_, b := join(h, 1, bob)
+	h.Lock(ca, "amount")
+	b.drain()
+
+	a.Close() // разрыв соединения: Run получает ошибку чтения и отключает клиента
+	ca.Run()
+
+	if msg := b.next(t, TypeUnlocked); msg.Field != "amount" {
+		t.Errorf("неверное сообщение unlocked: %+v", msg)
+	}
+	if msg := b.next(t, TypePresence); len(msg.Users) != 1 || msg.Users[0] != bob {
+		t.Errorf("presence после отключения Алисы: %v", msg.Users)
+	}
+	if _, ok := h.LockHolder(1, "amount"); ok {
+		t.Error("блокировка отключившегося клиента осталась")
+	}
+	if _, ok := h.Lock(ca, "amount"); ok {
+		t.Error("отключённый клиент смог занять поле")
+	}
+	h.Leave(ca) // повторное отключение безопасно
+}
+
+func TestLeaveRemovesEmptyRoom(t *testing.T) {
+	h := NewHub()
+	c, _ := join(h, 1, alice)
+	h.Leave(c)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.rooms[1]; ok {
+		t.Error("пустая комната документа не удалена")
+	}
+}
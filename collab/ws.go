@@ -0,0 +1,103 @@
+package collab
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"doc-generation/config"
+)
+
+const (
+	writeWait  = 10 * time.Second
+	pongWait   = 60 * time.Second
+	pingPeriod = pongWait * 9 / 10
+	maxMessage = 4096
+)
+
+// AuthSubprotocol — подпротокол, во втором элементе которого клиент передаёт токен:
+// new WebSocket(url, ["bearer", token]). Заголовки запросов в лог не пишутся,
+// в отличие от адреса с параметрами.
+const AuthSubprotocol = "bearer"
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	Subprotocols:    []string{AuthSubprotocol},
+	CheckOrigin:     CheckOrigin,
+}
+
+// CheckOrigin разрешает подключения только с фронтенда, как и CORS. Браузер
+// присылает Origin всегда, поэтому запрос без него отклоняется.
+func CheckOrigin(r *http.Request) bool {
+	return r.Header.Get("Origin") == config.GetEnv("FRONTEND_URL", "http://localhost:3000")
+}
+
+// Token возвращает токен из заголовка Sec-WebSocket-Protocol: "bearer, <токен>"
+func Token(r *http.Request) string {
+	protocols := websocket.Subprotocols(r)
+	if len(protocols) != 2 || protocols[0] != AuthSubprotocol {
+		return ""
+	}
+	return strings.TrimSpace(protocols[1])
+}
+
+// wsConn добавляет к соединению таймауты записи; gorilla разрешает
+// только одного писателя, поэтому запись и ping идут под мьютексом
+type wsConn struct {
+	*websocket.Conn
+	mu sync.Mutex
+}
+
+func (c *wsConn) WriteJSON(v interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.SetWriteDeadline(time.Now().Add(writeWait))
+	return c.Conn.WriteJSON(v)
+}
+
+func (c *wsConn) ping() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait))
+}
+
+// Serve переводит HTTP-запрос в WebSocket и держит соединение пользователя
+// с документом до его закрытия. Права на документ проверяет вызывающий.
+func Serve(h *Hub, w http.ResponseWriter, r *http.Request, documentID int, user Participant) error {
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return err
+	}
+
+	conn := &wsConn{Conn: ws}
+	ws.SetReadLimit(maxMessage)
+	ws.SetReadDeadline(time.Now().Add(pongWait))
+	ws.SetPongHandler(func(string) error {
+		return ws.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(pingPeriod)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := conn.ping(); err != nil {
+					ws.Close()
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	h.Join(documentID, user, conn).Run()
+	close(done)
+	return nil
+}
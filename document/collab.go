@@ -0,0 +1,96 @@
+package document
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"doc-generation/auth"
+	"doc-generation/collab"
+)
+
+// hub — участники открытых документов этого экземпляра сервера
+var hub = collab.NewHub()
+
+// participant возвращает пользователя в виде участника совместного редактирования
+func participant(userID int) (collab.Participant, error) {
+	u, err := auth.GetUserByID(userID)
+	if err != nil {
+		return collab.Participant{}, err
+	}
+	name := strings.TrimSpace(u.FirstName + " " + u.LastName)
+	return collab.Participant{UserID: u.ID, Name: name}, nil
+}
+
+// notifyContent рассылает открывшим документ его новое содержимое и версию
+func notifyContent(documentID, userID int) {
+	user, err := participant(userID)
+	if err != nil {
+		log.Printf("⚠️ Не удалось получить пользователя ID=%d для рассылки: %v", userID, err)
+		return
+	}
+
+	var version int
+	var rendered sql.NullString
+	err = db.QueryRow(`SELECT version, rendered_content FROM documents WHERE id = $1`, documentID).Scan(&version, &rendered)
+	if err != nil {
+		log.Printf("⚠️ Не удалось получить содержимое документа ID=%d для рассылки: %v", documentID, err)
+		return
+	}
+	hub.BroadcastContent(documentID, user, rendered.String, version)
+}
+
+// DocumentWSHandler открывает канал совместного редактирования документа:
+// присутствие, мягкие блокировки полей и рассылка сохранённых изменений.
+// Токен передаётся в Sec-WebSocket-Protocol (см. collab.AuthSubprotocol).
+func DocumentWSHandler(c *gin.Context) {
+	documentID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID документа"})
+		return
+	}
+
+	if !collab.CheckOrigin(c.Request) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Недопустимый источник запроса"})
+		return
+	}
+	token := collab.Token(c.Request)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Токен не найден"})
+		return
+	}
+	u, err := auth.AuthenticateToken(token)
+	if err == auth.ErrUserBlocked {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	access, err := GetDocumentAccess(u.OrganizationID, u.ID, documentID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Документ не найден"})
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Ошибка проверки доступа к документу ID=%d: %v", documentID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при проверке доступа к документу"})
+		return
+	}
+	if !access.Read {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Нет доступа к документу"})
+		return
+	}
+
+	user := collab.Participant{UserID: u.ID, Name: strings.TrimSpace(u.FirstName + " " + u.LastName)}
+	if err := collab.Serve(hub, c.Writer, c.Request, documentID, user); err != nil {
+		// Upgrade уже ответил клиенту ошибкой
+		log.Printf("⚠️ Не удалось открыть WebSocket документа ID=%d: %v", documentID, err)
+	}
+}
@@ -0,0 +1,488 @@
+package document
+
+import (
+	"database/sql"
+	"errors"
+	"html"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// Статусы обсуждений
+const (
+	CommentOpen     = "open"
+	CommentResolved = "resolved"
+)
+
+var (
+	ErrCommentNotFound = errors.New("комментарий не найден")
+	ErrInvalidAnchor   = errors.New("некорректная привязка комментария")
+	ErrNoRevision      = errors.New("у документа нет сохранённых версий")
+	ErrForeignMention  = errors.New("упомянуть можно только пользователей, у которых есть доступ к документу")
+)
+
+// CommentAnchor — место в документе, к которому относится обсуждение: фрагмент
+// версии с позиции Start до End (в символах HTML-содержимого) или элемент с data-style-id
+type CommentAnchor struct {
+	RevisionID *int   `json:"revision_id"`
+	Start      *int   `json:"start,omitempty"`
+	End        *int   `json:"end,omitempty"`
+	StyleID    string `json:"style_id,omitempty"`
+	Quote      string `json:"quote,omitempty"`
+}
+
+// Comment — комментарий; у корневого комментария есть привязка, статус и ответы
+type Comment struct {
+	ID         int            `json:"id"`
+	DocumentID int            `json:"document_id"`
+	ParentID   *int           `json:"parent_id,omitempty"`
+	Anchor     *CommentAnchor `json:"anchor,omitempty"`
+	AuthorID   *int           `json:"author_id"`
+	AuthorName string         `json:"author_name"`
+	Body       string         `json:"body"`
+	Status     string         `json:"status,omitempty"`
+	ResolvedBy *int           `json:"resolved_by,omitempty"`
+	ResolvedAt *time.Time     `json:"resolved_at,omitempty"`
+	Mentions   []int          `json:"mentions"`
+	CreatedAt  time.Time      `json:"created_at"`
+	Replies    []Comment      `json:"replies,omitempty"`
+}
+
+// NewComment — данные нового комментария. Для ответа указывается ParentID,
+// привязка ответа не задаётся и берётся из обсуждения.
+type NewComment struct {
+	ParentID   *int   `json:"parent_id"`
+	RevisionID *int   `json:"revision_id"`
+	Start      *int   `json:"start"`
+	End        *int   `json:"end"`
+	StyleID    string `json:"style_id"`
+	Body       string `json:"body"`
+	Mentions   []int  `json:"mentions"`
+}
+
+const commentSelect = `
+	SELECT c.id, c.document_id, c.parent_id, c.revision_id, c.anchor_start, c.anchor_end,
+	       COALESCE(c.anchor_style_id, ''), c.quote, c.author_id,
+	       COALESCE(u.first_name || ' ' || u.last_name, ''), c.body, c.status,
+	       c.resolved_by, c.resolved_at, c.created_at,
+	       ARRAY(SELECT m.user_id FROM document_comment_mentions m WHERE m.comment_id = c.id ORDER BY m.user_id)
+	FROM document_comments c
+	LEFT JOIN users u ON u.id = c.author_id`
+
+func scanComment(row rowScanner) (*Comment, error) {
+	var cm Comment
+	var parentID, revisionID, start, end, authorID, resolvedBy sql.NullInt64
+	var resolvedAt sql.NullTime
+	var styleID, quote string
+	var mentions pq.Int64Array
+	err := row.Scan(&cm.ID, &cm.DocumentID, &parentID, &revisionID, &start, &end, &styleID, &quote,
+		&authorID, &cm.AuthorName, &cm.Body, &cm.Status, &resolvedBy, &resolvedAt, &cm.CreatedAt, &mentions)
+	if err != nil {
+		return nil, err
+	}
+
+	cm.ParentID = nullIntPtr(parentID)
+	cm.AuthorID = nullIntPtr(authorID)
+	cm.ResolvedBy = nullIntPtr(resolvedBy)
+	if resolvedAt.Valid {
+		cm.ResolvedAt = &resolvedAt.Time
+	}
+	cm.Mentions = make([]int, len(mentions))
+	for i, id := range mentions {
+		cm.Mentions[i] = int(id)
+	}
+
+	if cm.ParentID == nil {
+		cm.Anchor = &CommentAnchor{
+			RevisionID: nullIntPtr(revisionID),
+			Start:      nullIntPtr(start),
+			End:        nullIntPtr(end),
+			StyleID:    styleID,
+			Quote:      quote,
+		}
+	} else {
+		// статус ведётся по обсуждению целиком
+		cm.Status = ""
+	}
+	return &cm, nil
+}
+
+func nullIntPtr(v sql.NullInt64) *int {
+	if !v.Valid {
+		return nil
+	}
+	i := int(v.Int64)
+	return &i
+}
+
+var tagRe = regexp.MustCompile(`<[^>]*>`)
+
+// quoteOf возвращает текст фрагмента [start, end) содержимого без разметки
+func quoteOf(content string, start, end int) (string, error) {
+	runes := []rune(content)
+	if start < 0 || end <= start || end > len(runes) {
+		return "", ErrInvalidAnchor
+	}
+	return strings.TrimSpace(html.UnescapeString(tagRe.ReplaceAllString(string(runes[start:end]), ""))), nil
+}
+
+// CreateComment добавляет комментарий от имени authorID. Корневой комментарий
+// привязывается к указанной или последней версии документа.
+func CreateComment(documentID, authorID int, nc NewComment) (*Comment, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM documents WHERE id = $1)`, documentID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, sql.ErrNoRows
+	}
+
+	var parentID, revisionID, start, end *int
+	var styleID, quote string
+	if nc.ParentID != nil {
+		// ответ на ответ попадает в то же обсуждение
+		var rootID int
+		err := tx.QueryRow(`
+			SELECT COALESCE(parent_id, id) FROM document_comments WHERE id = $1 AND document_id = $2
+		`, *nc.ParentID, documentID).Scan(&rootID)
+		if err == sql.ErrNoRows {
+			return nil, ErrCommentNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		parentID = &rootID
+	} else {
+		if revisionID, err = commentRevision(tx, documentID, nc.RevisionID); err != nil {
+			return nil, err
+		}
+		hasRange := nc.Start != nil || nc.End != nil
+		if hasRange || nc.StyleID != "" {
+			if revisionID == nil {
+				return nil, ErrNoRevision
+			}
+			rev, err := scanRevision(tx.QueryRow(revisionSelect+` WHERE r.id = $1`, *revisionID))
+			if err != nil {
+				return nil, err
+			}
+			if hasRange {
+				if nc.Start == nil || nc.End == nil {
+					return nil, ErrInvalidAnchor
+				}
+				if quote, err = quoteOf(rev.Content, *nc.Start, *nc.End); err != nil {
+					return nil, err
+				}
+				start, end = nc.Start, nc.End
+			}
+			if nc.StyleID != "" {
+				if !strings.Contains(rev.Content, `data-style-id="`+nc.StyleID+`"`) {
+					return nil, ErrInvalidAnchor
+				}
+				styleID = nc.StyleID
+			}
+		}
+	}
+
+	mentions := uniqueInts(nc.Mentions)
+	if len(mentions) > 0 {
+		var found int
+		// упоминание не открывает доступ: упомянуть можно только тех, кто уже видит документ
+		err := tx.QueryRow(`
+			SELECT COUNT(*) FROM users u JOIN documents d ON d.id = $2
+			WHERE u.id = ANY($1) AND `+documentReaders("u", 3)+`
+		`, pq.Array(mentions), documentID, readPermissions()).Scan(&found)
+		if err != nil {
+			return nil, err
+		}
+		if found != len(mentions) {
+			return nil, ErrForeignMention
+		}
+	}
+
+	var id int
+	err = tx.QueryRow(`
+		INSERT INTO document_comments
+			(document_id, parent_id, revision_id, anchor_start, anchor_end, anchor_style_id, quote, author_id, body)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, $9)
+		RETURNING id
+	`, documentID, parentID, revisionID, start, end, styleID, quote, authorID, nc.Body).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+	for _, userID := range mentions {
+		if _, err := tx.Exec(`
+			INSERT INTO document_comment_mentions (comment_id, user_id) VALUES ($1, $2)
+		`, id, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	cm, err := scanComment(tx.QueryRow(commentSelect+` WHERE c.id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+	return cm, tx.Commit()
+}
+
+// commentRevision проверяет, что версия принадлежит документу, или берёт последнюю
+func commentRevision(q queryer, documentID int, revisionID *int) (*int, error) {
+	var id int
+	var err error
+	if revisionID != nil {
+		err = q.QueryRow(`
+			SELECT id FROM document_revisions WHERE id = $1 AND document_id = $2
+		`, *revisionID, documentID).Scan(&id)
+		if err == sql.ErrNoRows {
+			return nil, ErrInvalidAnchor
+		}
+	} else {
+		err = q.QueryRow(`
+			SELECT id FROM document_revisions WHERE document_id = $1 ORDER BY id DESC LIMIT 1
+		`, documentID).Scan(&id)
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+func uniqueInts(ids []int) []int {
+	seen := make(map[int]bool, len(ids))
+	result := []int{}
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+// ListComments возвращает обсуждения документа с ответами; status — open, resolved или пусто (все)
+func ListComments(documentID int, status string) ([]Comment, error) {
+	rows, err := db.Query(commentSelect+`
+		WHERE c.document_id = $1
+		  AND ($2 = '' OR COALESCE((SELECT p.status FROM document_comments p WHERE p.id = c.parent_id), c.status) = $2)
+		ORDER BY c.created_at, c.id
+	`, documentID, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	threads := []Comment{}
+	index := make(map[int]int)
+	var replies []Comment
+	for rows.Next() {
+		cm, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		if cm.ParentID == nil {
+			index[cm.ID] = len(threads)
+			threads = append(threads, *cm)
+		} else {
+			replies = append(replies, *cm)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, r := range replies {
+		if i, ok := index[*r.ParentID]; ok {
+			threads[i].Replies = append(threads[i].Replies, r)
+		}
+	}
+	return threads, nil
+}
+
+// ListMentions возвращает комментарии, в которых упомянут пользователь, новые первыми.
+// Комментарии к документам, которые пользователь больше не может читать, не показываются.
+func ListMentions(userID int, status string) ([]Comment, error) {
+	rows, err := db.Query(commentSelect+`
+		JOIN document_comment_mentions mm ON mm.comment_id = c.id AND mm.user_id = $1
+		JOIN users mu ON mu.id = mm.user_id
+		JOIN documents d ON d.id = c.document_id
+		WHERE ($2 = '' OR COALESCE((SELECT p.status FROM document_comments p WHERE p.id = c.parent_id), c.status) = $2)
+		  AND `+documentReaders("mu", 3)+`
+		ORDER BY c.created_at DESC, c.id DESC
+	`, userID, status, readPermissions())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []Comment{}
+	for rows.Next() {
+		cm, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, *cm)
+	}
+	return comments, rows.Err()
+}
+
+// SetCommentStatus закрывает или переоткрывает обсуждение. Менять статус можно только
+// у корневого комментария.
+func SetCommentStatus(documentID, commentID, userID int, status string) (*Comment, error) {
+	res, err := db.Exec(`
+		UPDATE document_comments
+		SET status = $1,
+		    resolved_by = CASE WHEN $1 = 'resolved' THEN $2::int END,
+		    resolved_at = CASE WHEN $1 = 'resolved' THEN NOW() END
+		WHERE id = $3 AND document_id = $4 AND parent_id IS NULL
+	`, status, userID, commentID, documentID)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, ErrCommentNotFound
+	}
+	return scanComment(db.QueryRow(commentSelect+` WHERE c.id = $1`, commentID))
+}
+
+// ---------- обработчики ----------
+
+// commentStatusFilter разбирает ?status=open|resolved|all
+func commentStatusFilter(c *gin.Context) (string, bool) {
+	switch status := c.DefaultQuery("status", "all"); status {
+	case "all":
+		return "", true
+	case CommentOpen, CommentResolved:
+		return status, true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "Статус должен быть open, resolved или all"})
+	return "", false
+}
+
+// ListCommentsHandler возвращает обсуждения документа (?status=open|resolved|all)
+func ListCommentsHandler(c *gin.Context) {
+	documentID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID документа"})
+		return
+	}
+	status, ok := commentStatusFilter(c)
+	if !ok {
+		return
+	}
+
+	threads, err := ListComments(documentID, status)
+	if err != nil {
+		log.Printf("❌ Ошибка получения комментариев документа ID=%d: %v", documentID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении комментариев"})
+		return
+	}
+	c.JSON(http.StatusOK, threads)
+}
+
+// CreateCommentHandler добавляет комментарий или ответ в обсуждение
+func CreateCommentHandler(c *gin.Context) {
+	documentID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID документа"})
+		return
+	}
+
+	var req NewComment
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный JSON"})
+		return
+	}
+	req.Body = strings.TrimSpace(req.Body)
+	if req.Body == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Текст комментария не может быть пустым"})
+		return
+	}
+
+	cm, err := CreateComment(documentID, c.GetInt("user_id"), req)
+	switch {
+	case err == sql.ErrNoRows:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Документ не найден"})
+	case errors.Is(err, ErrCommentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Комментарий, на который дан ответ, не найден"})
+	case errors.Is(err, ErrInvalidAnchor):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Фрагмент или элемент не найден в версии документа"})
+	case errors.Is(err, ErrNoRevision):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Сохраните версию документа, чтобы комментировать фрагмент"})
+	case errors.Is(err, ErrForeignMention):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Упомянуть можно только пользователей, у которых есть доступ к документу"})
+	case err != nil:
+		log.Printf("❌ Ошибка создания комментария к документу ID=%d: %v", documentID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при сохранении комментария"})
+	default:
+		c.JSON(http.StatusCreated, cm)
+	}
+}
+
+// ResolveCommentHandler закрывает обсуждение
+func ResolveCommentHandler(c *gin.Context) {
+	setCommentStatusHandler(c, CommentResolved)
+}
+
+// ReopenCommentHandler переоткрывает обсуждение
+func ReopenCommentHandler(c *gin.Context) {
+	setCommentStatusHandler(c, CommentOpen)
+}
+
+func setCommentStatusHandler(c *gin.Context, status string) {
+	documentID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID документа"})
+		return
+	}
+	commentID, err := strconv.Atoi(c.Param("comment"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID комментария"})
+		return
+	}
+
+	cm, err := SetCommentStatus(documentID, commentID, c.GetInt("user_id"), status)
+	if errors.Is(err, ErrCommentNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Обсуждение не найдено"})
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Ошибка смены статуса комментария ID=%d: %v", commentID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при смене статуса обсуждения"})
+		return
+	}
+	c.JSON(http.StatusOK, cm)
+}
+
+// ListMentionsHandler возвращает комментарии, где упомянут текущий пользователь
+func ListMentionsHandler(c *gin.Context) {
+	status, ok := commentStatusFilter(c)
+	if !ok {
+		return
+	}
+
+	userID := c.GetInt("user_id")
+	comments, err := ListMentions(userID, status)
+	if err != nil {
+		log.Printf("❌ Ошибка получения упоминаний пользователя ID=%d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении упоминаний"})
+		return
+	}
+	c.JSON(http.StatusOK, comments)
+}
@@ -0,0 +1,268 @@
+package document
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"doc-generation/render"
+	"doc-generation/richtext"
+	"doc-generation/templates"
+)
+
+// Exporter — формат экспорта документа
+type Exporter interface {
+	// Format — короткое имя формата (docx, pdf…), оно же расширение файла
+	Format() string
+	// ContentType — MIME-тип результата, по нему выбирается формат из заголовка Accept
+	ContentType() string
+	// Description — описание формата для клиента
+	Description() string
+	// Export записывает документ в w
+	Export(w io.Writer, src ExportSource) error
+}
+
+// ExportSource — данные документа для экспорта
+type ExportSource struct {
+	DocumentID int
+	HTML       string               // rendered_content
+	Rules      []richtext.StyleRule // стили шаблона из template_styles
+
+	// Для шаблонов вида docx: исходный документ Word и значения полей
+	DocxSource []byte
+	Values     render.Values
+}
+
+// DefaultExportFormat используется, если формат не указан ни в запросе, ни в Accept
+const DefaultExportFormat = "docx"
+
+var exporters = make(map[string]Exporter)
+
+// RegisterExporter добавляет формат экспорта (повторная регистрация заменяет прежний)
+func RegisterExporter(e Exporter) {
+	exporters[e.Format()] = e
+}
+
+// GetExporter возвращает экспортёр по имени формата
+func GetExporter(format string) (Exporter, bool) {
+	e, ok := exporters[strings.ToLower(format)]
+	return e, ok
+}
+
+// Exporters возвращает все зарегистрированные форматы, отсортированные по имени
+func Exporters() []Exporter {
+	list := make([]Exporter, 0, len(exporters))
+	for _, e := range exporters {
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Format() < list[j].Format() })
+	return list
+}
+
+// ExportersFor возвращает форматы, в которые можно выгрузить документ. Документ
+// по шаблону вида docx заполняется в исходном .docx, а rendered_content у него
+// не обновляется — поэтому другие форматы для него недоступны.
+func ExportersFor(src ExportSource) []Exporter {
+	if src.DocxSource == nil {
+		return Exporters()
+	}
+	if e, ok := GetExporter("docx"); ok {
+		return []Exporter{e}
+	}
+	return nil
+}
+
+// findExporter ищет формат по имени среди доступных
+func findExporter(available []Exporter, format string) (Exporter, bool) {
+	for _, e := range available {
+		if strings.EqualFold(e.Format(), format) {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+// GetExportSource загружает rendered_content и стили шаблона документа.
+// Для шаблона вида docx дополнительно загружаются исходный .docx и значения полей.
+func GetExportSource(documentID int) (ExportSource, error) {
+	var orgID int
+	var rendered sql.NullString
+	var templateID sql.NullInt64
+	var kind sql.NullString
+	err := db.QueryRow(`
+		SELECT d.organization_id, d.rendered_content, d.template_id, t.kind
+		FROM documents d
+		LEFT JOIN templates t ON t.id = d.template_id
+		WHERE d.id = $1
+	`, documentID).Scan(&orgID, &rendered, &templateID, &kind)
+	if err != nil {
+		return ExportSource{}, err
+	}
+
+	src := ExportSource{DocumentID: documentID, HTML: rendered.String}
+	if kind.String == templates.TemplateKindDocx {
+		if src.DocxSource, err = templates.GetTemplateSource(orgID, int(templateID.Int64)); err != nil {
+			return ExportSource{}, fmt.Errorf("ошибка получения исходного .docx шаблона: %w", err)
+		}
+		if src.Values, err = GetRenderValues(documentID); err != nil {
+			return ExportSource{}, err
+		}
+	} else if !rendered.Valid {
+		return ExportSource{}, sql.ErrNoRows
+	}
+
+	if src.Rules, err = GetStyleRulesByDocumentID(documentID); err != nil {
+		return ExportSource{}, fmt.Errorf("ошибка получения стилей шаблона: %w", err)
+	}
+	return src, nil
+}
+
+// negotiateExporter выбирает один из доступных форматов по параметру format, затем
+// по заголовку Accept.
+// Диапазоны Accept перебираются по убыванию q, типы с q=0 не отдаются никогда.
+// */* (его шлют HTTP-клиенты по умолчанию) означает формат по умолчанию,
+// чтобы ссылки на скачивание не начали отдавать HTML или текст вместо docx, —
+// но только если более предпочтительного совпадения нет.
+// Заголовок браузера при переходе по ссылке (text/html,…,*/*;q=0.8) описывает
+// страницу, а не файл, поэтому считается отсутствием предпочтений.
+func negotiateExporter(format, accept string, available []Exporter) (Exporter, bool) {
+	if format != "" {
+		return findExporter(available, format)
+	}
+	if strings.TrimSpace(accept) == "" {
+		return findExporter(available, DefaultExportFormat)
+	}
+
+	type mediaRange struct {
+		typ string
+		q   float64
+	}
+	var ranges []mediaRange
+	var first string                 // первый тип в заголовке
+	wildcard := false                // есть ли */* с q>0
+	refused := make(map[string]bool) // типы, явно запрещённые через q=0
+	for _, part := range strings.Split(accept, ",") {
+		typ, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if first == "" {
+			first = typ
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q <= 0 {
+			refused[typ] = true
+			continue
+		}
+		ranges = append(ranges, mediaRange{typ: typ, q: q})
+		wildcard = wildcard || typ == "*/*"
+	}
+	// при равном q порядок из заголовка сохраняется
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+
+	acceptable := func(e Exporter) bool {
+		ct, _, _ := mime.ParseMediaType(e.ContentType())
+		return !refused[ct]
+	}
+	if first == "text/html" && wildcard {
+		if e, ok := findExporter(available, DefaultExportFormat); ok && acceptable(e) {
+			return e, true
+		}
+	}
+	for _, r := range ranges {
+		if r.typ == "*/*" {
+			if e, ok := findExporter(available, DefaultExportFormat); ok && acceptable(e) {
+				return e, true
+			}
+		}
+		for _, e := range available {
+			ct, _, _ := mime.ParseMediaType(e.ContentType())
+			if refused[ct] {
+				continue
+			}
+			if r.typ == "*/*" || r.typ == ct ||
+				(strings.HasSuffix(r.typ, "/*") && strings.HasPrefix(ct, strings.TrimSuffix(r.typ, "*"))) {
+				return e, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func exportFormatList(available []Exporter) []gin.H {
+	var list []gin.H
+	for _, e := range available {
+		list = append(list, gin.H{
+			"format":       e.Format(),
+			"content_type": e.ContentType(),
+			"description":  e.Description(),
+		})
+	}
+	return list
+}
+
+// ExportFormatsHandler возвращает список доступных форматов экспорта
+func ExportFormatsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"default": DefaultExportFormat, "formats": exportFormatList(Exporters())})
+}
+
+// ExportHandler отдаёт документ в формате из параметра ?format= или заголовка Accept
+func ExportHandler(c *gin.Context) {
+	exportAs(c, "")
+}
+
+// exportAs — общая часть всех маршрутов экспорта; format пустой — выбор по запросу
+func exportAs(c *gin.Context, format string) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID"})
+		return
+	}
+
+	src, err := GetExportSource(id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Документ не найден или пуст"})
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Ошибка загрузки документа %d для экспорта: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка загрузки документа"})
+		return
+	}
+
+	if format == "" {
+		format = c.Query("format")
+	}
+	available := ExportersFor(src)
+	exporter, ok := negotiateExporter(format, c.GetHeader("Accept"), available)
+	if !ok {
+		c.JSON(http.StatusNotAcceptable, gin.H{"error": "Формат экспорта не поддерживается для этого документа", "formats": exportFormatList(available)})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := exporter.Export(&buf, src); err != nil {
+		log.Printf("❌ Ошибка экспорта документа %d в %s: %v", id, exporter.Format(), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Ошибка экспорта в %s", exporter.Format())})
+		return
+	}
+
+	filename := fmt.Sprintf("document_%d.%s", id, exporter.Format())
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	c.Header("Vary", "Accept")
+	c.Data(http.StatusOK, exporter.ContentType(), buf.Bytes())
+}
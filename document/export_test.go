@@ -0,0 +1,42 @@
+package document
+
+import "testing"
+
+func TestNegotiateExporter(t *testing.T) {
+	const (
+		firefox = "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"
+		chrome  = "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7"
+	)
+	tests := []struct {
+		name   string
+		format string
+		accept string
+		want   string // пусто — формат не подобран
+	}{
+		{name: "без заголовка", want: DefaultExportFormat},
+		{name: "параметр format важнее Accept", format: "pdf", accept: "text/html", want: "pdf"},
+		{name: "неизвестный format", format: "xls", want: ""},
+		{name: "любой тип", accept: "*/*", want: DefaultExportFormat},
+		{name: "браузер Firefox", accept: firefox, want: DefaultExportFormat},
+		{name: "браузер Chrome", accept: chrome, want: DefaultExportFormat},
+		{name: "явный HTML", accept: "text/html", want: "html"},
+		{name: "HTML без */*", accept: "text/html,application/xhtml+xml", want: "html"},
+		{name: "PDF предпочтительнее */*", accept: "application/pdf,*/*;q=0.1", want: "pdf"},
+		{name: "по убыванию q", accept: "text/plain;q=0.5,text/markdown", want: "md"},
+		{name: "docx запрещён", accept: "application/vnd.openxmlformats-officedocument.wordprocessingml.document;q=0,text/html,*/*;q=0.8", want: "html"},
+		{name: "нет подходящего", accept: "image/png", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, ok := negotiateExporter(tt.format, tt.accept, Exporters())
+			got := ""
+			if ok {
+				got = e.Format()
+			}
+			if got != tt.want {
+				t.Errorf("negotiateExporter(%q, %q) = %q, ожидалось %q", tt.format, tt.accept, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package document
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"baliance.com/gooxml/document"
+
+	"doc-generation/docx"
+	"doc-generation/odt"
+	"doc-generation/pdf"
+	"doc-generation/richtext"
+)
+
+func init() {
+	RegisterExporter(docxExporter{})
+	RegisterExporter(pdfExporter{})
+	RegisterExporter(htmlExporter{})
+	RegisterExporter(txtExporter{})
+	RegisterExporter(mdExporter{})
+	RegisterExporter(odtExporter{})
+}
+
+// docxExporter — Word через gooxml. Для шаблонов вида docx значения
+// подставляются в исходный документ, HTML не используется.
+type docxExporter struct{}
+
+func (docxExporter) Format() string { return "docx" }
+func (docxExporter) ContentType() string {
+	return "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+}
+func (docxExporter) Description() string { return "Документ Word" }
+
+func (docxExporter) Export(w io.Writer, src ExportSource) error {
+	if src.DocxSource != nil {
+		doc, err := document.Read(bytes.NewReader(src.DocxSource), int64(len(src.DocxSource)))
+		if err != nil {
+			return fmt.Errorf("ошибка чтения исходного .docx: %w", err)
+		}
+		if err := docx.Fill(doc, src.Values); err != nil {
+			return err
+		}
+		return doc.Save(w)
+	}
+
+	doc := document.New()
+	if err := ConvertHTMLToWord(doc, src.HTML, src.Rules); err != nil {
+		return err
+	}
+	return doc.Save(w)
+}
+
+// pdfExporter — PDF без внешних утилит
+type pdfExporter struct{}
+
+func (pdfExporter) Format() string      { return "pdf" }
+func (pdfExporter) ContentType() string { return "application/pdf" }
+func (pdfExporter) Description() string { return "PDF" }
+
+func (pdfExporter) Export(w io.Writer, src ExportSource) error {
+	opts := pdf.DefaultOptions()
+	opts.Rules = src.Rules
+	return pdf.Convert(w, src.HTML, opts)
+}
+
+// htmlExporter — самостоятельная HTML-страница со стилями шаблона
+type htmlExporter struct{}
+
+func (htmlExporter) Format() string      { return "html" }
+func (htmlExporter) ContentType() string { return "text/html; charset=utf-8" }
+func (htmlExporter) Description() string { return "HTML-страница" }
+
+func (htmlExporter) Export(w io.Writer, src ExportSource) error {
+	_, err := fmt.Fprintf(w, `<!DOCTYPE html>
+<html lang="ru">
+<head>
+<meta charset="UTF-8">
+<title>Документ %d</title>
+<style>
+%s</style>
+</head>
+<body>
+%s
+</body>
+</html>
+`, src.DocumentID, styleSheetCSS(src.Rules), src.HTML)
+	return err
+}
+
+// styleSheetCSS собирает правила template_styles в таблицу стилей
+func styleSheetCSS(rules []richtext.StyleRule) string {
+	var sb strings.Builder
+	for _, r := range rules {
+		names := make([]string, 0, len(r.Declarations))
+		for name := range r.Declarations {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		sb.WriteString(r.Selector)
+		sb.WriteString(" {")
+		for _, name := range names {
+			fmt.Fprintf(&sb, " %s: %s;", name, r.Declarations[name])
+		}
+		sb.WriteString(" }\n")
+	}
+	// содержимое <style> не экранируется — достаточно не дать закрыть тег
+	return strings.ReplaceAll(sb.String(), "<", "")
+}
+
+// txtExporter — простой текст
+type txtExporter struct{}
+
+func (txtExporter) Format() string      { return "txt" }
+func (txtExporter) ContentType() string { return "text/plain; charset=utf-8" }
+func (txtExporter) Description() string { return "Простой текст" }
+
+func (txtExporter) Export(w io.Writer, src ExportSource) error {
+	parsed, err := richtext.Parse(src.HTML, src.Rules)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, richtext.PlainText(parsed))
+	return err
+}
+
+// mdExporter — Markdown
+type mdExporter struct{}
+
+func (mdExporter) Format() string      { return "md" }
+func (mdExporter) ContentType() string { return "text/markdown; charset=utf-8" }
+func (mdExporter) Description() string { return "Markdown" }
+
+func (mdExporter) Export(w io.Writer, src ExportSource) error {
+	parsed, err := richtext.Parse(src.HTML, src.Rules)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, richtext.Markdown(parsed))
+	return err
+}
+
+// odtExporter — OpenDocument без pandoc
+type odtExporter struct{}
+
+func (odtExporter) Format() string      { return "odt" }
+func (odtExporter) ContentType() string { return odt.MimeType }
+func (odtExporter) Description() string { return "Документ OpenDocument" }
+
+func (odtExporter) Export(w io.Writer, src ExportSource) error {
+	return odt.Convert(w, src.HTML, src.Rules)
+}
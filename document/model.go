@@ -2,7 +2,15 @@ package document
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
 	"time"
+
+	"github.com/lib/pq"
+
+	"doc-generation/auth"
+	"doc-generation/etag"
 )
 
 // db — подключение к базе (инициализируется через InitDocumentRepo)
@@ -13,51 +21,274 @@ func InitDocumentRepo(database *sql.DB) {
 	db = database
 }
 
-// UpdateRenderedContent обновляет только rendered_content
-func UpdateRenderedContent(id int, rendered string) error {
-	_, err := db.Exec(`UPDATE documents SET rendered_content = $1 WHERE id = $2`, rendered, id)
-	return err
+// ErrVersionConflict — документ успели изменить после того, как клиент получил его версию
+var ErrVersionConflict = errors.New("документ изменён другим пользователем")
+
+// UpdateRenderedContent обновляет только rendered_content, если версия документа
+// есть среди versions (etag.AnyVersion — без проверки). Возвращает новую версию.
+func UpdateRenderedContent(id int, rendered string, versions []int) (int, error) {
+	return updateVersioned(id, `
+		UPDATE documents SET rendered_content = $1, version = version + 1
+		WHERE id = $2 AND ($4::int = ANY($3::int[]) OR version = ANY($3::int[]))
+		RETURNING version
+	`, rendered, id, pq.Array(versions), etag.Any)
+}
+
+// UpdateDocumentContent сохраняет content документа вместе с собранным по нему
+// rendered_content, если версия документа есть среди versions. Возвращает новую версию.
+func UpdateDocumentContent(id int, content, rendered string, versions []int) (int, error) {
+	return updateVersioned(id, `
+		UPDATE documents SET content = $1, rendered_content = $2, version = version + 1
+		WHERE id = $3 AND ($5::int = ANY($4::int[]) OR version = ANY($4::int[]))
+		RETURNING version
+	`, content, rendered, id, pq.Array(versions), etag.Any)
+}
+
+// updateVersioned выполняет UPDATE … RETURNING version с проверкой версии и
+// различает конфликт версий и отсутствие документа
+func updateVersioned(id int, query string, args ...interface{}) (int, error) {
+	var newVersion int
+	err := db.QueryRow(query, args...).Scan(&newVersion)
+	if err == sql.ErrNoRows {
+		var exists bool
+		if err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM documents WHERE id = $1)`, id).Scan(&exists); err != nil {
+			return 0, err
+		}
+		if exists {
+			return 0, ErrVersionConflict
+		}
+	}
+	return newVersion, err
+}
+
+// DocumentAccess — права пользователя на документ своей организации
+type DocumentAccess struct {
+	Read bool `json:"read"` // автор, согласующие и право documents.read
+	Edit bool `json:"edit"` // автор и право documents.edit
+}
+
+// GetDocumentAccess возвращает права пользователя на документ. Документ чужой
+// организации считается несуществующим (sql.ErrNoRows).
+func GetDocumentAccess(orgID, userID, documentID int) (DocumentAccess, error) {
+	var author, approver bool
+	err := db.QueryRow(`
+		SELECT d.user_id = $2,
+		       EXISTS (SELECT 1 FROM document_approvers a WHERE a.document_id = d.id AND a.user_id = $2)
+		FROM documents d WHERE d.id = $1 AND d.organization_id = $3
+	`, documentID, userID, orgID).Scan(&author, &approver)
+	if err != nil {
+		return DocumentAccess{}, err
+	}
+
+	perms, err := auth.UserPermissions(userID)
+	if err != nil {
+		return DocumentAccess{}, err
+	}
+	editAll := slices.Contains(perms, auth.PermDocumentsEdit)
+	readAll := editAll || slices.Contains(perms, auth.PermDocumentsRead)
+	return DocumentAccess{Read: author || approver || readAll, Edit: author || editAll}, nil
+}
+
+// documentReaders — SQL-условие «пользователь из таблицы users с псевдонимом u может
+// читать документ d» для запросов, которые проверяют доступ сразу многих пользователей;
+// то же, что Read в GetDocumentAccess. $n — права, открывающие любые документы (readPermissions).
+func documentReaders(u string, n int) string {
+	return fmt.Sprintf(`(%[1]s.organization_id = d.organization_id AND (
+		%[1]s.id = d.user_id
+		OR EXISTS (SELECT 1 FROM document_approvers a WHERE a.document_id = d.id AND a.user_id = %[1]s.id)
+		OR EXISTS (
+			SELECT 1 FROM role_permissions rp
+			WHERE rp.role_id = %[1]s.role_id AND rp.organization_id = %[1]s.organization_id AND rp.permission = ANY($%[2]d)
+		)
+	))`, u, n)
+}
+
+// readPermissions — права, с которыми пользователь читает любой документ организации
+func readPermissions() interface{} {
+	return pq.Array([]string{auth.PermDocumentsRead, auth.PermDocumentsEdit})
+}
+
+// Document — документ организации
+type Document struct {
+	ID              int       `json:"id"`
+	OrganizationID  int       `json:"organization_id"`
+	UserID          int       `json:"user_id"`
+	TemplateID      int       `json:"template_id"`
+	Name            string    `json:"name"`
+	Content         string    `json:"content"`
+	RenderedContent string    `json:"rendered_content"`
+	Status          string    `json:"status"`
+	Version         int       `json:"version"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// GetDocumentByID возвращает документ организации. Документ чужой организации
+// считается несуществующим (sql.ErrNoRows).
+func GetDocumentByID(orgID, id int) (*Document, error) {
+	var doc Document
+	var rendered sql.NullString // NULL, пока документ не сформирован
+	err := db.QueryRow(`
+		SELECT id, organization_id, user_id, template_id, name, content, rendered_content, status, version, created_at
+		FROM documents
+		WHERE id = $1 AND organization_id = $2
+	`, id, orgID).Scan(&doc.ID, &doc.OrganizationID, &doc.UserID, &doc.TemplateID, &doc.Name, &doc.Content, &rendered, &doc.Status, &doc.Version, &doc.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if doc.OrganizationID != orgID {
+		return nil, sql.ErrNoRows
+	}
+	doc.RenderedContent = rendered.String
+	return &doc, nil
+}
+
+// documentOrganization возвращает организацию документа
+func documentOrganization(documentID int) (int, error) {
+	var orgID int
+	err := db.QueryRow(`SELECT organization_id FROM documents WHERE id = $1`, documentID).Scan(&orgID)
+	return orgID, err
+}
+
+// CreateDocument создаёт документ по шаблону организации и копирует в него маршрут согласования
+// шаблона. Автор документа из маршрута исключается: свой документ он не согласует.
+func CreateDocument(orgID, userID, templateID int) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRow(`
+		INSERT INTO documents (organization_id, user_id, template_id, name, content)
+		SELECT t.organization_id, $1, $2, t.name, t.content FROM templates t
+		WHERE t.id = $2 AND t.organization_id = $3
+		RETURNING id
+	`, userID, templateID, orgID).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO document_approvers (document_id, user_id, step)
+		SELECT $1, user_id, step FROM template_approval_steps WHERE template_id = $2 AND user_id <> $3
+	`, id, templateID, userID); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
 }
 
 // DocumentRevision представляет одну сохранённую версию документа
 type DocumentRevision struct {
 	ID         int       `json:"id"`
 	DocumentID int       `json:"document_id"`
+	AuthorID   *int      `json:"author_id"`
+	Message    string    `json:"message"`
+	Size       int       `json:"size"`
 	Content    string    `json:"content"`
 	CreatedAt  time.Time `json:"created_at"`
 }
 
-// SaveDocumentRevision сохраняет текущую версию документа в историю
-func SaveDocumentRevision(documentID int, content string) error {
-	_, err := db.Exec(
-		`INSERT INTO document_revisions (document_id, content) VALUES ($1, $2)`,
-		documentID, content,
-	)
-	return err
+// RevisionSummary — версия в списке истории, без содержимого
+type RevisionSummary struct {
+	ID         int       `json:"id"`
+	DocumentID int       `json:"document_id"`
+	AuthorID   *int      `json:"author_id"`
+	AuthorName string    `json:"author_name"`
+	Message    string    `json:"message"`
+	Size       int       `json:"size"`
+	CreatedAt  time.Time `json:"created_at"`
 }
 
-// GetDocumentRevisions возвращает список всех версий для документа
-func GetDocumentRevisions(documentID int) ([]DocumentRevision, error) {
+// SaveDocumentRevision сохраняет версию документа в историю.
+// authorID = 0 — автор неизвестен.
+func SaveDocumentRevision(documentID, authorID int, message, content string) (*DocumentRevision, error) {
+	return insertRevision(db, documentID, authorID, message, content)
+}
+
+// ListDocumentRevisions возвращает страницу истории документа (новые сначала) и общее число версий
+func ListDocumentRevisions(documentID, limit, offset int) ([]RevisionSummary, int, error) {
+	var total int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM document_revisions WHERE document_id = $1`, documentID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
 	rows, err := db.Query(`
-		SELECT id, document_id, content, created_at
-		FROM document_revisions
-		WHERE document_id = $1
-		ORDER BY created_at DESC
-	`, documentID)
+		SELECT r.id, r.document_id, r.author_id, COALESCE(u.first_name || ' ' || u.last_name, ''),
+		       r.message, r.size, r.created_at
+		FROM document_revisions r
+		LEFT JOIN users u ON u.id = r.author_id
+		WHERE r.document_id = $1
+		ORDER BY r.created_at DESC, r.id DESC
+		LIMIT $2 OFFSET $3
+	`, documentID, limit, offset)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
-	var revisions []DocumentRevision
+	revisions := []RevisionSummary{}
 	for rows.Next() {
-		var rev DocumentRevision
-		if err := rows.Scan(&rev.ID, &rev.DocumentID, &rev.Content, &rev.CreatedAt); err != nil {
-			return nil, err
+		var rev RevisionSummary
+		var authorID sql.NullInt64
+		if err := rows.Scan(&rev.ID, &rev.DocumentID, &authorID, &rev.AuthorName, &rev.Message, &rev.Size, &rev.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		if authorID.Valid {
+			id := int(authorID.Int64)
+			rev.AuthorID = &id
 		}
 		revisions = append(revisions, rev)
 	}
-	return revisions, nil
+	return revisions, total, rows.Err()
+}
+
+// GetDocumentRevision возвращает версию документа; sql.ErrNoRows, если версия принадлежит другому документу
+func GetDocumentRevision(documentID, revisionID int) (*DocumentRevision, error) {
+	return scanRevision(db.QueryRow(revisionSelect+` WHERE r.id = $1 AND r.document_id = $2`, revisionID, documentID))
+}
+
+// RestoreDocumentRevision возвращает документу содержимое версии, если версия документа
+// есть среди versions (иначе ErrVersionConflict). Текущее содержимое предварительно
+// сохраняется новой версией от имени authorID, чтобы восстановление можно было отменить.
+// Возвращает эту версию и новую версию документа.
+func RestoreDocumentRevision(documentID, revisionID, authorID int, versions []int) (*DocumentRevision, int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	var current sql.NullString
+	var version int
+	err = tx.QueryRow(`
+		SELECT rendered_content, version FROM documents WHERE id = $1 FOR UPDATE
+	`, documentID).Scan(&current, &version)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !etag.Matches(versions, version) {
+		return nil, 0, ErrVersionConflict
+	}
+
+	rev, err := scanRevision(tx.QueryRow(revisionSelect+` WHERE r.id = $1 AND r.document_id = $2`, revisionID, documentID))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	message := fmt.Sprintf("Перед восстановлением версии %d", revisionID)
+	snapshot, err := insertRevision(tx, documentID, authorID, message, current.String)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var newVersion int
+	if err := tx.QueryRow(`
+		UPDATE documents SET rendered_content = $1, version = version + 1 WHERE id = $2 RETURNING version
+	`, rev.Content, documentID).Scan(&newVersion); err != nil {
+		return nil, 0, err
+	}
+	return snapshot, newVersion, tx.Commit()
 }
 
 type DocumentField struct {
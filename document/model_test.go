@@ -0,0 +1,113 @@
+package document
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+
+	"doc-generation/auth"
+	"doc-generation/templates"
+)
+
+// Документ 5 принадлежит ownOrg; запросы в тестах приходят от пользователя otherUser из otherOrg
+const (
+	ownOrg    = 1
+	otherOrg  = 2
+	otherUser = 20
+)
+
+func mockDB(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		database.Close()
+	})
+	InitDocumentRepo(database)
+	templates.InitTemplates(database)
+	auth.InitAuth(database)
+	return mock
+}
+
+// Даже если запрос вернёт строку чужой организации, документ не отдаётся
+func TestGetDocumentByIDForeignRow(t *testing.T) {
+	mock := mockDB(t)
+	mock.ExpectQuery(`FROM documents\s+WHERE id = \$1 AND organization_id = \$2`).
+		WithArgs(5, otherOrg).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "organization_id", "user_id", "template_id", "name", "content", "rendered_content", "status", "version", "created_at"}).
+			AddRow(5, ownOrg, 10, 7, "Договор", "<p>{{client}}</p>", "<p>ООО Ромашка</p>", StatusDraft, 1, time.Now()))
+
+	doc, err := GetDocumentByID(otherOrg, 5)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("GetDocumentByID(otherOrg) = %+v, %v; ожидалось sql.ErrNoRows", doc, err)
+	}
+}
+
+// Запросы к документу чужой организации заканчиваются на проверке доступа:
+// ни чтения документа, ни записи полей не происходит (sqlmock отклонил бы
+// любой запрос сверх ожидаемых)
+func TestDocumentRoutesOtherOrganization(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("user_id", otherUser)
+		c.Set("organization_id", otherOrg)
+	})
+	d := r.Group("/documents/:id", documentAccess())
+	d.GET("", GetDocumentByIDHandler)
+	d.POST("/data", SaveDocumentFieldHandler)
+
+	requests := []struct {
+		method, path, body string
+	}{
+		{http.MethodGet, "/documents/5", ""},
+		{http.MethodPost, "/documents/5/data", `{"field_name":"client","field_value":"ООО Лютик"}`},
+	}
+	for _, tt := range requests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			mock := mockDB(t)
+			mock.ExpectQuery(`FROM documents d WHERE d.id = \$1 AND d.organization_id = \$3`).
+				WithArgs(5, otherUser, otherOrg).
+				WillReturnRows(sqlmock.NewRows([]string{"author", "participant"}))
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+			if w.Code != http.StatusNotFound {
+				t.Errorf("код ответа %d, ожидалось 404: %s", w.Code, w.Body)
+			}
+		})
+	}
+}
+
+// Упоминание в комментарии не открывает документ: пользователь без авторства,
+// согласования и прав на чтение документов доступа не получает
+func TestGetDocumentAccessIgnoresMentions(t *testing.T) {
+	mock := mockDB(t)
+	const mentioned = 30
+	mock.ExpectQuery(`FROM documents d WHERE d.id = \$1 AND d.organization_id = \$3`).
+		WithArgs(5, mentioned, ownOrg).
+		WillReturnRows(sqlmock.NewRows([]string{"author", "approver"}).AddRow(false, false))
+	mock.ExpectQuery(`FROM users u\s+JOIN role_permissions rp`).
+		WithArgs(mentioned).
+		WillReturnRows(sqlmock.NewRows([]string{"permission"}).AddRow(auth.PermDocumentsArchive))
+
+	access, err := GetDocumentAccess(ownOrg, mentioned, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if access.Read || access.Edit {
+		t.Errorf("права %+v, ожидалось отсутствие доступа", access)
+	}
+}
@@ -0,0 +1,106 @@
+package document
+
+import (
+	"errors"
+	"fmt"
+
+	"doc-generation/render"
+	"doc-generation/templates"
+)
+
+// rerenderAttempts — сколько раз пересборка повторяется, если документ меняют параллельно
+const rerenderAttempts = 3
+
+// Render подставляет значения полей документа (document_data) и значения тегов
+// по умолчанию в content документа и сохраняет результат в rendered_content, если
+// версия документа есть среди versions. Возвращает результат и новую версию.
+func Render(documentID int, versions []int) (string, int, error) {
+	var content string
+	if err := db.QueryRow(`SELECT content FROM documents WHERE id = $1`, documentID).Scan(&content); err != nil {
+		return "", 0, err
+	}
+
+	rendered, err := renderContent(documentID, content)
+	if err != nil {
+		return "", 0, err
+	}
+
+	version, err := UpdateRenderedContent(documentID, rendered, versions)
+	if err != nil {
+		return "", 0, fmt.Errorf("ошибка сохранения rendered_content: %w", err)
+	}
+	return rendered, version, nil
+}
+
+// Rerender пересобирает rendered_content после изменения полей документа. Сборка
+// сохраняется только для той версии, чей content был прочитан: если документ
+// изменили в это время, она повторяется по новому содержимому.
+func Rerender(documentID int) (string, int, error) {
+	for attempt := 1; ; attempt++ {
+		var version int
+		if err := db.QueryRow(`SELECT version FROM documents WHERE id = $1`, documentID).Scan(&version); err != nil {
+			return "", 0, err
+		}
+		rendered, newVersion, err := Render(documentID, []int{version})
+		if errors.Is(err, ErrVersionConflict) && attempt < rerenderAttempts {
+			continue
+		}
+		return rendered, newVersion, err
+	}
+}
+
+// SaveContent заменяет content документа и сохраняет собранный по нему
+// rendered_content, если версия документа есть среди versions
+func SaveContent(documentID int, content string, versions []int) (string, int, error) {
+	rendered, err := renderContent(documentID, content)
+	if err != nil {
+		return "", 0, err
+	}
+	version, err := UpdateDocumentContent(documentID, content, rendered, versions)
+	if err != nil {
+		return "", 0, err
+	}
+	return rendered, version, nil
+}
+
+// renderContent подставляет в content значения для документа
+func renderContent(documentID int, content string) (string, error) {
+	values, err := GetRenderValues(documentID)
+	if err != nil {
+		return "", err
+	}
+
+	rendered, err := render.Execute(content, values)
+	if err != nil {
+		return "", fmt.Errorf("ошибка в синтаксисе шаблона: %w", err)
+	}
+	return rendered, nil
+}
+
+// GetRenderValues собирает значения для подстановки: сначала значения тегов по умолчанию,
+// поверх них — сохранённые поля документа. Списки хранятся в document_data как JSON-массивы.
+func GetRenderValues(documentID int) (render.Values, error) {
+	orgID, err := documentOrganization(documentID)
+	if err != nil {
+		return nil, err
+	}
+
+	defaults, err := templates.GetTagDefaults(orgID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения значений тегов по умолчанию: %w", err)
+	}
+
+	data, err := GetDocumentData(documentID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения данных документа: %w", err)
+	}
+
+	values := make(render.Values, len(defaults)+len(data))
+	for name, value := range defaults {
+		values[name] = render.DecodeValue(value)
+	}
+	for name, value := range data {
+		values[name] = render.DecodeValue(value)
+	}
+	return values, nil
+}
@@ -0,0 +1,53 @@
+package document
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"doc-generation/etag"
+)
+
+// Render сохраняет сборку только для версии из If-Match: устаревшая версия — конфликт
+func TestRenderChecksVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		updated bool
+		want    error
+	}{
+		{name: "актуальная версия", updated: true},
+		{name: "устаревшая версия", want: ErrVersionConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := mockDB(t)
+			mock.ExpectQuery(`SELECT content FROM documents WHERE id = \$1`).WithArgs(5).
+				WillReturnRows(sqlmock.NewRows([]string{"content"}).AddRow("<p>{{client}}</p>"))
+			mock.ExpectQuery(`SELECT organization_id FROM documents WHERE id = \$1`).WithArgs(5).
+				WillReturnRows(sqlmock.NewRows([]string{"organization_id"}).AddRow(ownOrg))
+			mock.ExpectQuery(`FROM tags WHERE organization_id = \$1`).WithArgs(ownOrg).
+				WillReturnRows(sqlmock.NewRows([]string{"name", "default_value"}))
+			mock.ExpectQuery(`FROM document_data WHERE document_id = \$1`).WithArgs(5).
+				WillReturnRows(sqlmock.NewRows([]string{"field_name", "field_value"}).AddRow("client", "ООО Ромашка"))
+
+			update := mock.ExpectQuery(`UPDATE documents SET rendered_content = \$1`).
+				WithArgs("<p>ООО Ромашка</p>", 5, "{3}", etag.Any)
+			if tt.updated {
+				update.WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(4))
+			} else {
+				update.WillReturnRows(sqlmock.NewRows([]string{"version"}))
+				mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM documents WHERE id = \$1\)`).WithArgs(5).
+					WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+			}
+
+			rendered, version, err := Render(5, []int{3})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Render: %v, ожидалось %v", err, tt.want)
+			}
+			if tt.updated && (rendered != "<p>ООО Ромашка</p>" || version != 4) {
+				t.Errorf("Render = %q, версия %d", rendered, version)
+			}
+		})
+	}
+}
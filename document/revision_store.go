@@ -0,0 +1,240 @@
+package document
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"doc-generation/htmldiff"
+)
+
+// Хранение версий: каждая snapshotInterval-я версия — полный снимок, остальные —
+// патч относительно последнего снимка. Если патч получается больше maxDeltaRatio
+// от размера содержимого, версия тоже сохраняется снимком.
+const (
+	snapshotInterval = 20
+	maxDeltaRatio    = 0.5
+)
+
+// revisionSelect выбирает версию вместе с содержимым её снимка; scanRevision применяет патч
+const revisionSelect = `
+	SELECT r.id, r.document_id, r.author_id, r.message, r.size,
+	       COALESCE(r.content, b.content, ''), r.delta, r.created_at
+	FROM document_revisions r
+	LEFT JOIN document_revisions b ON b.id = r.base_id`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// queryer — общее у *sql.DB и *sql.Tx
+type queryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func scanRevision(row rowScanner) (*DocumentRevision, error) {
+	var rev DocumentRevision
+	var authorID sql.NullInt64
+	var delta sql.NullString
+	if err := row.Scan(&rev.ID, &rev.DocumentID, &authorID, &rev.Message, &rev.Size, &rev.Content, &delta, &rev.CreatedAt); err != nil {
+		return nil, err
+	}
+	if authorID.Valid {
+		id := int(authorID.Int64)
+		rev.AuthorID = &id
+	}
+	if delta.Valid {
+		var patch htmldiff.Patch
+		if err := json.Unmarshal([]byte(delta.String), &patch); err != nil {
+			return nil, fmt.Errorf("версия %d: некорректный патч: %w", rev.ID, err)
+		}
+		content, err := patch.Apply(rev.Content)
+		if err != nil {
+			return nil, fmt.Errorf("версия %d: %w", rev.ID, err)
+		}
+		rev.Content = content
+	}
+	return &rev, nil
+}
+
+// encodePatch сериализует патч без экранирования < и >, чтобы не раздувать HTML
+func encodePatch(patch htmldiff.Patch) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(patch); err != nil {
+		return "", err
+	}
+	return string(bytes.TrimSpace(buf.Bytes())), nil
+}
+
+// revisionDelta решает, сохранять ли версию патчем к снимку base, после которого
+// уже сохранено since версий. Пустая строка — сохранить полный снимок.
+func revisionDelta(base string, since int, content string) (string, error) {
+	if since+1 >= snapshotInterval {
+		return "", nil
+	}
+	delta, err := encodePatch(htmldiff.MakePatch(base, content))
+	if err != nil {
+		return "", err
+	}
+	if float64(len(delta)) > float64(len(content))*maxDeltaRatio {
+		return "", nil
+	}
+	return delta, nil
+}
+
+// insertRevision добавляет версию, выбирая между снимком и патчем
+func insertRevision(q queryer, documentID, authorID int, message, content string) (*DocumentRevision, error) {
+	var baseID int
+	var base string
+	var since int
+	err := q.QueryRow(`
+		SELECT s.id, s.content,
+		       (SELECT COUNT(*) FROM document_revisions r WHERE r.document_id = s.document_id AND r.id > s.id)
+		FROM document_revisions s
+		WHERE s.document_id = $1 AND s.base_id IS NULL
+		ORDER BY s.id DESC
+		LIMIT 1
+	`, documentID).Scan(&baseID, &base, &since)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	var delta string
+	if err == nil {
+		if delta, err = revisionDelta(base, since, content); err != nil {
+			return nil, err
+		}
+	}
+
+	rev := DocumentRevision{DocumentID: documentID, Message: message, Size: len(content), Content: content}
+	if authorID != 0 {
+		rev.AuthorID = &authorID
+	}
+	if delta == "" {
+		err = q.QueryRow(`
+			INSERT INTO document_revisions (document_id, author_id, message, size, content)
+			VALUES ($1, NULLIF($2, 0), $3, $4, $5)
+			RETURNING id, created_at
+		`, documentID, authorID, message, len(content), content).Scan(&rev.ID, &rev.CreatedAt)
+	} else {
+		err = q.QueryRow(`
+			INSERT INTO document_revisions (document_id, author_id, message, size, base_id, delta)
+			VALUES ($1, NULLIF($2, 0), $3, $4, $5, $6)
+			RETURNING id, created_at
+		`, documentID, authorID, message, len(content), baseID, delta).Scan(&rev.ID, &rev.CreatedAt)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
+
+// CompactRevisions пересохраняет историю документа по текущим правилам:
+// полные копии заменяются патчами к снимкам. Возвращает размер хранимых данных до и после.
+func CompactRevisions(documentID int) (before, after int64, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	storedSize := func() (int64, error) {
+		var size int64
+		err := tx.QueryRow(`
+			SELECT COALESCE(SUM(COALESCE(octet_length(content), 0) + COALESCE(octet_length(delta), 0)), 0)
+			FROM document_revisions WHERE document_id = $1
+		`, documentID).Scan(&size)
+		return size, err
+	}
+	if before, err = storedSize(); err != nil {
+		return 0, 0, err
+	}
+
+	// сначала восстанавливаем все версии: при перезаписи снимки могут стать патчами
+	rows, err := tx.Query(revisionSelect+` WHERE r.document_id = $1 ORDER BY r.id FOR UPDATE OF r`, documentID)
+	if err != nil {
+		return 0, 0, err
+	}
+	var revisions []*DocumentRevision
+	for rows.Next() {
+		rev, err := scanRevision(rows)
+		if err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		revisions = append(revisions, rev)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	var baseID, since int
+	var base string
+	for i, rev := range revisions {
+		delta := ""
+		if i > 0 {
+			if delta, err = revisionDelta(base, since, rev.Content); err != nil {
+				return 0, 0, err
+			}
+		}
+
+		if delta == "" {
+			_, err = tx.Exec(`
+				UPDATE document_revisions SET content = $1, base_id = NULL, delta = NULL, size = $2 WHERE id = $3
+			`, rev.Content, len(rev.Content), rev.ID)
+			baseID, base, since = rev.ID, rev.Content, 0
+		} else {
+			_, err = tx.Exec(`
+				UPDATE document_revisions SET content = NULL, base_id = $1, delta = $2, size = $3 WHERE id = $4
+			`, baseID, delta, len(rev.Content), rev.ID)
+			since++
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if after, err = storedSize(); err != nil {
+		return 0, 0, err
+	}
+	return before, after, tx.Commit()
+}
+
+// CompactAllRevisions сжимает историю всех документов
+func CompactAllRevisions() error {
+	rows, err := db.Query(`SELECT DISTINCT document_id FROM document_revisions ORDER BY document_id`)
+	if err != nil {
+		return err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var totalBefore, totalAfter int64
+	for _, id := range ids {
+		before, after, err := CompactRevisions(id)
+		if err != nil {
+			return fmt.Errorf("документ %d: %w", id, err)
+		}
+		log.Printf("✅ История документа ID=%d сжата: %d → %d байт", id, before, after)
+		totalBefore += before
+		totalAfter += after
+	}
+	log.Printf("✅ Сжатие истории завершено: документов %d, %d → %d байт", len(ids), totalBefore, totalAfter)
+	return nil
+}
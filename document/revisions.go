@@ -0,0 +1,185 @@
+package document
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"doc-generation/etag"
+	"doc-generation/htmldiff"
+)
+
+// revisionParams разбирает :id и :rev из пути
+func revisionParams(c *gin.Context) (documentID, revisionID int, ok bool) {
+	documentID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID документа"})
+		return 0, 0, false
+	}
+	revisionID, err = strconv.Atoi(c.Param("rev"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID версии"})
+		return 0, 0, false
+	}
+	return documentID, revisionID, true
+}
+
+// Размер страницы истории версий
+const (
+	defaultRevisionsLimit = 20
+	maxRevisionsLimit     = 100
+)
+
+// ListRevisionsHandler возвращает страницу истории версий без содержимого (?page=1&limit=20)
+func ListRevisionsHandler(c *gin.Context) {
+	documentID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID документа"})
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный номер страницы"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultRevisionsLimit)))
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный размер страницы"})
+		return
+	}
+	limit = min(limit, maxRevisionsLimit)
+
+	revisions, total, err := ListDocumentRevisions(documentID, limit, (page-1)*limit)
+	if err != nil {
+		log.Printf("❌ Ошибка получения версий документа ID=%d: %v", documentID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении версий"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items": revisions,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+// GetRevisionHandler возвращает одну версию документа вместе с содержимым
+func GetRevisionHandler(c *gin.Context) {
+	documentID, revisionID, ok := revisionParams(c)
+	if !ok {
+		return
+	}
+
+	rev, err := GetDocumentRevision(documentID, revisionID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Версия не найдена"})
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Ошибка получения версии %d документа ID=%d: %v", revisionID, documentID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении версии"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rev)
+}
+
+// revisionContent возвращает содержимое версии; "current" — текущий rendered_content документа
+func revisionContent(documentID int, rev string) (string, error) {
+	if rev == "current" {
+		var current sql.NullString
+		err := db.QueryRow(`SELECT rendered_content FROM documents WHERE id = $1`, documentID).Scan(&current)
+		return current.String, err
+	}
+	revisionID, err := strconv.Atoi(rev)
+	if err != nil {
+		return "", sql.ErrNoRows
+	}
+	r, err := GetDocumentRevision(documentID, revisionID)
+	if err != nil {
+		return "", err
+	}
+	return r.Content, nil
+}
+
+// DiffRevisionsHandler сравнивает две версии документа.
+// Вторая версия может быть "current" — сравнение с текущим содержимым.
+func DiffRevisionsHandler(c *gin.Context) {
+	documentID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID документа"})
+		return
+	}
+
+	var contents [2]string
+	for i, rev := range []string{c.Param("rev"), c.Param("other")} {
+		contents[i], err = revisionContent(documentID, rev)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Версия не найдена", "revision": rev})
+			return
+		}
+		if err != nil {
+			log.Printf("❌ Ошибка загрузки версии %s документа ID=%d: %v", rev, documentID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при сравнении версий"})
+			return
+		}
+	}
+
+	ops := htmldiff.Diff(contents[0], contents[1])
+	c.JSON(http.StatusOK, gin.H{
+		"from":  c.Param("rev"),
+		"to":    c.Param("other"),
+		"ops":   ops,
+		"stats": htmldiff.Summary(ops),
+		"html":  htmldiff.Markup(ops),
+	})
+}
+
+// RestoreRevisionHandler восстанавливает версию документа, сохранив текущее содержимое.
+// Ожидаемая версия документа передаётся в If-Match.
+func RestoreRevisionHandler(c *gin.Context) {
+	documentID, revisionID, ok := revisionParams(c)
+	if !ok {
+		return
+	}
+	versions, ok := etag.IfMatch(c)
+	if !ok || !ensureEditable(c, documentID) {
+		return
+	}
+
+	snapshot, version, err := RestoreDocumentRevision(documentID, revisionID, c.GetInt("user_id"), versions)
+	if err == ErrVersionConflict {
+		restoreConflict(c, documentID)
+		return
+	}
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Документ или версия не найдены"})
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Ошибка восстановления версии %d документа ID=%d: %v", revisionID, documentID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при восстановлении версии"})
+		return
+	}
+
+	notifyContent(documentID, c.GetInt("user_id"))
+	log.Printf("✅ Документ ID=%d восстановлен из версии %d, прежнее содержимое сохранено как версия %d", documentID, revisionID, snapshot.ID)
+	etag.Set(c, version)
+	c.JSON(http.StatusOK, gin.H{"restored": revisionID, "snapshot_id": snapshot.ID, "version": version})
+}
+
+// restoreConflict отвечает 412: документ изменён после того, как клиент получил его
+// версию, и восстановление затёрло бы эти правки
+func restoreConflict(c *gin.Context, documentID int) {
+	var version int
+	if err := db.QueryRow(`SELECT version FROM documents WHERE id = $1`, documentID).Scan(&version); err == nil {
+		etag.Set(c, version)
+	}
+	log.Printf("⚠️ Восстановление документа ID=%d отклонено: версия из If-Match устарела", documentID)
+	c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Документ изменён другим пользователем", "version": version})
+}
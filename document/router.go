@@ -4,71 +4,217 @@ import (
 	"baliance.com/gooxml/document"
 
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"golang.org/x/net/html"
 	"log"
 	"net/http"
-	"os"
-	"os/exec"
-	"path/filepath"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
-	"baliance.com/gooxml/measurement"
 	"github.com/gin-gonic/gin"
+
+	"doc-generation/auth"
+	"doc-generation/docx"
+	"doc-generation/etag"
+	"doc-generation/render"
+	"doc-generation/richtext"
+	"doc-generation/templates"
 	// Импорт модели из текущего пакета, так как файл model.go тоже в пакете `document`
 	// НЕ нужно использовать alias вроде `model`, можно вызывать напрямую
 )
 
-// RegisterDocumentRoutes подключает все маршруты, связанные с документами
+// RegisterDocumentRoutes подключает все маршруты, связанные с документами.
+// Все маршруты, кроме списка форматов и WebSocket, требуют авторизации; документы
+// других организаций для пользователя не существуют (404).
 func RegisterDocumentRoutes(r *gin.Engine) {
-	r.POST("/documents/update-content", UpdateDocumentContentHandler)
-	r.POST("/documents/:id/revision", SaveDocumentRevisionHandler)
-	r.POST("/documents/create", CreateDocumentHandler)
-	r.GET("/documents/:id", GetDocumentByIDHandler)
-	r.GET("/documents/:id/data", GetDocumentDataHandler)
-	r.POST("/documents/:id/data", SaveDocumentFieldHandler)
-	r.GET("/documents/user/:id", GetDocumentsByUserHandler)
-	r.POST("/documents/:id/export-word", ExportDocumentToWordHandler)
-	r.GET("/documents/:id/export", ExportDocxHandler)
-	r.GET("/documents/:id/export-docx", ExportDocxHandler)
-	r.GET("/documents/:id/export-pdf", ExportPdfHandler)
+	r.GET("/documents/export-formats", ExportFormatsHandler)
+	// браузер не передаёт Authorization при открытии WebSocket, токен приходит в Sec-WebSocket-Protocol
+	r.GET("/documents/:id/ws", DocumentWSHandler)
+
+	g := r.Group("/documents")
+	g.Use(auth.AuthMiddleware())
+	g.POST("/update-content", UpdateDocumentContentHandler)
+	g.POST("/create", CreateDocumentHandler)
+	g.GET("/mentions", ListMentionsHandler)
+	g.GET("/user/:id", GetDocumentsByUserHandler)
+
+	d := g.Group("/:id")
+	d.Use(documentAccess())
+	d.GET("", GetDocumentByIDHandler)
+	d.POST("/render", RenderDocumentHandler)
+	d.POST("/revision", SaveDocumentRevisionHandler)
+	d.GET("/revisions", ListRevisionsHandler)
+	d.GET("/revisions/:rev", GetRevisionHandler)
+	d.GET("/revisions/:rev/diff/:other", DiffRevisionsHandler)
+	d.POST("/revisions/:rev/restore", RestoreRevisionHandler)
+	d.GET("/status", GetStatusHandler)
+	d.POST("/status", ChangeStatusHandler)
+	d.PUT("/approvers", SetApproversHandler)
+	d.POST("/approve", ApproveDocumentHandler)
+	d.POST("/reject", RejectDocumentHandler)
+	d.GET("/status-history", StatusHistoryHandler)
+	d.GET("/comments", ListCommentsHandler)
+	d.POST("/comments", CreateCommentHandler)
+	d.POST("/comments/:comment/resolve", ResolveCommentHandler)
+	d.POST("/comments/:comment/reopen", ReopenCommentHandler)
+	d.GET("/data", GetDocumentDataHandler)
+	d.GET("/validate", ValidateDocumentHandler)
+	d.POST("/data", SaveDocumentFieldHandler)
+	d.GET("/export", ExportHandler)
+	// старые маршруты экспорта оставлены для совместимости
+	d.POST("/export-word", ExportDocumentToWordHandler)
+	d.GET("/export-docx", ExportDocxHandler)
+	d.GET("/export-pdf", ExportPdfHandler)
+}
 
+// documentAccess пропускает запрос, только если пользователь может читать документ :id
+func documentAccess() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID документа"})
+			return
+		}
+		if !checkDocumentAccess(c, id) {
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// documentAccessKey — права на документ в контексте запроса
+const documentAccessKey = "document_access"
+
+// checkDocumentAccess проверяет право чтения документа и сохраняет права в контексте.
+// Документ чужой организации — 404, недоступный пользователю — 403.
+func checkDocumentAccess(c *gin.Context, id int) bool {
+	access, err := GetDocumentAccess(c.GetInt("organization_id"), c.GetInt("user_id"), id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Документ не найден"})
+		return false
+	}
+	if err != nil {
+		log.Printf("❌ Ошибка проверки доступа к документу ID=%d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при проверке доступа к документу"})
+		return false
+	}
+	if !access.Read {
+		log.Printf("⛔ Нет доступа к документу ID=%d: user_id=%d", id, c.GetInt("user_id"))
+		c.JSON(http.StatusForbidden, gin.H{"error": "Нет доступа к документу"})
+		return false
+	}
+	c.Set(documentAccessKey, access)
+	return true
 }
 
 // --- Запрос для обновления контента ---
 type UpdateDocumentContentRequest struct {
 	ID      int    `json:"id"`
-	Content string `json:"content"`
+	Content string `json:"content"` // HTML документа с плейсхолдерами {{tag}}
 }
 
-// Обработчик обновления контента документа
-// Обновление финального render-контента (только rendered_content)
+// UpdateDocumentContentHandler сохраняет content документа и пересобирает по нему
+// rendered_content на сервере: готовый HTML от клиента не принимается, чтобы экспорт
+// не расходился с сохранёнными значениями полей. Ожидаемая версия документа
+// передаётся в If-Match.
 func UpdateDocumentContentHandler(c *gin.Context) {
 	var req UpdateDocumentContentRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный JSON"})
 		return
 	}
+	if !checkDocumentAccess(c, req.ID) {
+		return
+	}
+	versions, ok := etag.IfMatch(c)
+	if !ok || !ensureEditable(c, req.ID) {
+		return
+	}
+
+	rendered, version, err := SaveContent(req.ID, req.Content, versions)
+	if !renderResponse(c, req.ID, err) {
+		return
+	}
+
+	notifyContent(req.ID, c.GetInt("user_id"))
+	etag.Set(c, version)
+	c.JSON(http.StatusOK, gin.H{"status": "content updated", "version": version, "rendered_content": rendered})
+}
 
-	err := UpdateRenderedContent(req.ID, req.Content)
+// renderResponse отвечает на ошибку сборки документа и возвращает false, если она была
+func renderResponse(c *gin.Context, id int, err error) bool {
+	var parseErr *render.ParseError
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, ErrVersionConflict):
+		documentConflict(c, id)
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Документ не найден"})
+	case errors.As(err, &parseErr):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Ошибка в синтаксисе шаблона", "details": parseErr.Error()})
+	default:
+		log.Printf("❌ Ошибка рендеринга документа ID=%d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при рендеринге документа"})
+	}
+	return false
+}
+
+// documentConflict отвечает 409 с текущей версией документа, чтобы клиент мог объединить правки
+func documentConflict(c *gin.Context, id int) {
+	var version int
+	var content string
+	var rendered sql.NullString
+	err := db.QueryRow(`SELECT version, content, rendered_content FROM documents WHERE id = $1`, id).Scan(&version, &content, &rendered)
 	if err != nil {
-		log.Printf("❌ Ошибка при сохранении rendered_content: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при сохранении контента"})
+		log.Printf("❌ Ошибка получения текущей версии документа ID=%d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении текущей версии документа"})
+		return
+	}
+	log.Printf("⚠️ Конфликт версий документа ID=%d, текущая версия %d", id, version)
+	etag.Set(c, version)
+	c.JSON(http.StatusConflict, gin.H{
+		"error":            "Документ изменён другим пользователем",
+		"version":          version,
+		"content":          content,
+		"rendered_content": rendered.String,
+	})
+}
+
+// RenderDocumentHandler собирает rendered_content из шаблона документа и сохранённых
+// значений полей. Ожидаемая версия документа передаётся в If-Match.
+func RenderDocumentHandler(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID документа"})
+		return
+	}
+	versions, ok := etag.IfMatch(c)
+	if !ok || !ensureEditable(c, id) {
+		return
+	}
+
+	rendered, version, err := Render(id, versions)
+	if !renderResponse(c, id, err) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"status": "rendered content updated"})
+	notifyContent(id, c.GetInt("user_id"))
+	etag.Set(c, version)
+	c.JSON(http.StatusOK, gin.H{"rendered_content": rendered, "version": version})
 }
 
 // --- Запрос для сохранения ревизии ---
 type SaveRevisionRequest struct {
 	Content string `json:"content"`
+	Message string `json:"message"` // необязательный комментарий к версии
 }
 
-// Обработчик сохранения новой ревизии
+// Обработчик сохранения новой ревизии; автор — пользователь из токена
 func SaveDocumentRevisionHandler(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
@@ -82,20 +228,22 @@ func SaveDocumentRevisionHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный JSON"})
 		return
 	}
+	if !ensureEditable(c, id) {
+		return
+	}
 
-	err = SaveDocumentRevision(id, req.Content)
+	rev, err := SaveDocumentRevision(id, c.GetInt("user_id"), strings.TrimSpace(req.Message), req.Content)
 	if err != nil {
 		log.Printf("❌ Ошибка сохранения ревизии: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при сохранении версии"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"status": "revision saved"})
+	c.JSON(http.StatusOK, gin.H{"status": "revision saved", "id": rev.ID, "size": rev.Size})
 }
 
-// POST /documents/create
+// POST /documents/create; автор документа — пользователь из токена
 type CreateDocumentRequest struct {
-	UserID     int `json:"user_id"`
 	TemplateID int `json:"template_id"`
 }
 
@@ -110,13 +258,11 @@ func CreateDocumentHandler(c *gin.Context) {
 		return
 	}
 
-	var newID int
-	err := db.QueryRow(`
-		INSERT INTO documents (user_id, template_id, name, content)
-		SELECT $1, $2, t.name, t.content FROM templates t WHERE t.id = $2
-		RETURNING id
-	`, req.UserID, req.TemplateID).Scan(&newID)
-
+	newID, err := CreateDocument(c.GetInt("organization_id"), c.GetInt("user_id"), req.TemplateID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Шаблон не найден"})
+		return
+	}
 	if err != nil {
 		log.Printf("❌ Ошибка создания документа: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось создать документ"})
@@ -135,37 +281,14 @@ func GetDocumentByIDHandler(c *gin.Context) {
 		return
 	}
 
-	row := db.QueryRow(`
-		SELECT id, user_id, template_id, name, content, rendered_content, created_at
-		FROM documents
-		WHERE id = $1
-	`, id)
-
-	var rendered sql.NullString
-	var doc struct {
-		ID              int       `json:"id"`
-		UserID          int       `json:"user_id"`
-		TemplateID      int       `json:"template_id"`
-		Name            string    `json:"name"`
-		Content         string    `json:"content"`
-		RenderedContent string    `json:"rendered_content"`
-		CreatedAt       time.Time `json:"created_at"`
-	}
-
-	err = row.Scan(&doc.ID, &doc.UserID, &doc.TemplateID, &doc.Name, &doc.Content, &rendered, &doc.CreatedAt)
+	doc, err := GetDocumentByID(c.GetInt("organization_id"), id)
 	if err != nil {
 		log.Printf("❌ Документ не найден: %v", err)
 		c.JSON(http.StatusNotFound, gin.H{"error": "Документ не найден"})
 		return
 	}
 
-	// если rendered_content = NULL, заполним пустой строкой
-	if rendered.Valid {
-		doc.RenderedContent = rendered.String
-	} else {
-		doc.RenderedContent = ""
-	}
-
+	etag.Set(c, doc.Version)
 	c.JSON(http.StatusOK, doc)
 }
 
@@ -204,6 +327,31 @@ func SaveDocumentFieldHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный запрос"})
 		return
 	}
+	if !ensureEditable(c, documentID) {
+		return
+	}
+	// Поле, занятое в канале совместного редактирования, меняет только занявший его
+	if holder, locked := hub.LockHolder(documentID, req.FieldName); locked && holder.UserID != c.GetInt("user_id") {
+		c.JSON(http.StatusLocked, gin.H{"error": "Поле редактирует другой пользователь", "field": req.FieldName, "locked_by": holder})
+		return
+	}
+
+	// Проверяем значение по типу тега (поля без тега принимаются как есть)
+	tag, err := templates.GetTagByName(c.GetInt("organization_id"), req.FieldName)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("❌ Ошибка получения тега %s: %v", req.FieldName, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось проверить поле"})
+		return
+	}
+	if tag != nil {
+		if fe := templates.ValidateTagValue(*tag, req.FieldValue); fe != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error":  "Некорректное значение поля",
+				"fields": []templates.FieldError{*fe},
+			})
+			return
+		}
+	}
 
 	err = SaveOrUpdateDocumentField(documentID, req.FieldName, req.FieldValue)
 	if err != nil {
@@ -211,10 +359,51 @@ func SaveDocumentFieldHandler(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"status": "saved"})
+	if user, err := participant(c.GetInt("user_id")); err == nil {
+		hub.BroadcastField(documentID, user, req.FieldName, req.FieldValue)
+	}
+
+	// rendered_content пересобирается сразу, чтобы экспорт совпадал с сохранёнными полями
+	_, version, err := Rerender(documentID)
+	if err != nil {
+		log.Printf("⚠️ Поле %s документа ID=%d сохранено, но документ не пересобран: %v", req.FieldName, documentID, err)
+		c.JSON(http.StatusOK, gin.H{"status": "saved", "render_error": "Документ не пересобран, проверьте синтаксис шаблона"})
+		return
+	}
+	notifyContent(documentID, c.GetInt("user_id"))
+
+	etag.Set(c, version)
+	c.JSON(http.StatusOK, gin.H{"status": "saved", "version": version})
+}
+
+// ValidateDocumentHandler проверяет все поля документа, включая обязательные
+func ValidateDocumentHandler(c *gin.Context) {
+	idStr := c.Param("id")
+	documentID, err := strconv.Atoi(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID"})
+		return
+	}
+
+	fieldErrors, err := ValidateDocument(documentID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Документ не найден"})
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Ошибка валидации документа ID=%d: %v", documentID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось проверить документ"})
+		return
+	}
+
+	if fieldErrors == nil {
+		fieldErrors = []templates.FieldError{}
+	}
+	c.JSON(http.StatusOK, gin.H{"valid": len(fieldErrors) == 0, "fields": fieldErrors})
 }
 
-// GetDocumentsByUserHandler возвращает все документы пользователя
+// GetDocumentsByUserHandler возвращает все документы пользователя. Чужие списки
+// доступны с правом documents.read.
 func GetDocumentsByUserHandler(c *gin.Context) {
 	userIDStr := c.Param("id")
 	userID, err := strconv.Atoi(userIDStr)
@@ -222,13 +411,25 @@ func GetDocumentsByUserHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID пользователя"})
 		return
 	}
+	if userID != c.GetInt("user_id") {
+		allowed, err := auth.HasPermission(c.GetInt("user_id"), auth.PermDocumentsRead)
+		if err != nil {
+			log.Printf("❌ Ошибка проверки прав пользователя ID=%d: %v", c.GetInt("user_id"), err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить документы"})
+			return
+		}
+		if !allowed {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Нет доступа к документам пользователя"})
+			return
+		}
+	}
 
 	rows, err := db.Query(`
-		SELECT id, user_id, template_id, name, content, rendered_content, created_at
+		SELECT id, user_id, template_id, name, content, rendered_content, status, created_at
 		FROM documents
-		WHERE user_id = $1
+		WHERE user_id = $1 AND organization_id = $2
 		ORDER BY created_at DESC
-	`, userID)
+	`, userID, c.GetInt("organization_id"))
 	if err != nil {
 		log.Printf("❌ Ошибка при получении документов: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить документы"})
@@ -245,10 +446,11 @@ func GetDocumentsByUserHandler(c *gin.Context) {
 			TemplateID int
 			Name       string
 			Content    string
+			Status     string
 			CreatedAt  time.Time
 		}
 
-		err := rows.Scan(&doc.ID, &doc.UserID, &doc.TemplateID, &doc.Name, &doc.Content, &rendered, &doc.CreatedAt)
+		err := rows.Scan(&doc.ID, &doc.UserID, &doc.TemplateID, &doc.Name, &doc.Content, &rendered, &doc.Status, &doc.CreatedAt)
 		if err != nil {
 			log.Printf("❌ Ошибка при чтении строки: %v", err)
 			continue
@@ -261,6 +463,7 @@ func GetDocumentsByUserHandler(c *gin.Context) {
 			"name":             doc.Name,
 			"content":          doc.Content,
 			"rendered_content": rendered.String,
+			"status":           doc.Status,
 			"created_at":       doc.CreatedAt,
 		})
 	}
@@ -268,258 +471,60 @@ func GetDocumentsByUserHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, documents)
 }
 
+// ExportDocumentToWordHandler — прежний экспорт в Word, теперь через реестр форматов
 func ExportDocumentToWordHandler(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID"})
-		return
-	}
-
-	// Получаем rendered_content из БД
-	row := db.QueryRow(`SELECT rendered_content FROM documents WHERE id = $1`, id)
-	var rendered sql.NullString
-	if err := row.Scan(&rendered); err != nil || !rendered.Valid {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Документ не найден или пуст"})
-		return
-	}
-
-	// Создаём временный HTML-файл
-	tmpDir := os.TempDir()
-	htmlPath := filepath.Join(tmpDir, fmt.Sprintf("document_%d.html", id))
-	docxPath := filepath.Join(tmpDir, fmt.Sprintf("document_%d.docx", id))
-
-	if err := os.WriteFile(htmlPath, []byte(rendered.String), 0644); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка записи HTML"})
-		return
-	}
-
-	// Вызываем Pandoc
-	cmd := exec.Command("pandoc", htmlPath, "-o", docxPath)
-	if err := cmd.Run(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка вызова pandoc"})
-		return
-	}
-
-	// Отправляем файл пользователю
-	c.FileAttachment(docxPath, fmt.Sprintf("document_%d.docx", id))
-
-	// (необязательно) удаляем временные файлы — если хочешь подчистить
-	// defer os.Remove(htmlPath)
-	// defer os.Remove(docxPath)
+	exportAs(c, "docx")
 }
 
-func ConvertHTMLToWord(doc *document.Document, htmlStr string, styleMap map[string]int) error {
-	root, err := html.Parse(strings.NewReader(htmlStr))
-	if err != nil {
-		return err
-	}
-
-	var walk func(n *html.Node, p document.Paragraph)
-
-	walk = func(n *html.Node, p document.Paragraph) {
-		switch {
-		case n.Type == html.ElementNode && n.Data == "p":
-			p = doc.AddParagraph()
-
-			for _, attr := range n.Attr {
-				if attr.Key == "style" {
-					if indent := parseIndent(attr.Val); indent > 0 {
-						p.Properties().SetFirstLineIndent(measurement.Distance(indent))
-						fmt.Printf("📎 Отступ (indent): %d twips\n", indent)
-					}
-				}
-			}
-
-			for c := n.FirstChild; c != nil; c = c.NextSibling {
-				walk(c, p)
-			}
-
-		case n.Type == html.ElementNode && n.Data == "span":
-			run := p.AddRun()
-			var styleID string
-			for _, attr := range n.Attr {
-				if attr.Key == "data-style-id" {
-					styleID = attr.Val
-					break
-				}
-			}
-
-			if styleID != "" {
-				if pt, ok := styleMap[styleID]; ok && pt > 0 {
-					run.Properties().SetSize(measurement.Distance(pt * 2))
-					fmt.Printf("✅ span[data-style-id=\"%s\"] → %dpt (size=%d half-points)\n", styleID, pt, pt*2)
-				} else {
-					fmt.Printf("⚠️ Не найден размер для style-id: %s\n", styleID)
-				}
-			} else {
-				fmt.Println("⚠️ span без data-style-id")
-			}
-
-			for c := n.FirstChild; c != nil; c = c.NextSibling {
-				if c.Type == html.TextNode {
-					run.AddText(c.Data)
-					fmt.Printf("📝 Текст: \"%s\"\n", c.Data)
-				} else {
-					walk(c, p)
-				}
-			}
-
-		case n.Type == html.TextNode:
-			p.AddRun().AddText(n.Data)
-			fmt.Printf("📝 Обычный текст (вне span): \"%s\"\n", n.Data)
-
-		default:
-			for c := n.FirstChild; c != nil; c = c.NextSibling {
-				walk(c, p)
-			}
-		}
-	}
-
-	walk(root, document.Paragraph{}) // пустой старт
-	return nil
-}
-
-func GenerateDocxFromRendered(documentID int, renderedHTML string) (string, error) {
-	doc := document.New()
-
-	// Получаем карту styleID → font_size_pt
-	styleMap, err := GetFontSizesByDocumentID(documentID)
-	if err != nil {
-		return "", fmt.Errorf("ошибка получения размеров шрифта: %w", err)
-	}
-
-	if err := ConvertHTMLToWord(doc, renderedHTML, styleMap); err != nil {
-		return "", fmt.Errorf("ошибка генерации Word-документа: %w", err)
-	}
-
-	outputDir := "exports"
-	if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
-		return "", fmt.Errorf("ошибка создания директории: %w", err)
-	}
-
-	filename := fmt.Sprintf("document_%d.docx", documentID)
-	path := filepath.Join(outputDir, filename)
-
-	if err := doc.SaveToFile(path); err != nil {
-		return "", fmt.Errorf("ошибка сохранения docx: %w", err)
-	}
-
-	return path, nil
+// ConvertHTMLToWord добавляет HTML-контент в документ Word.
+// rules — CSS-правила шаблона из template_styles.
+func ConvertHTMLToWord(doc *document.Document, htmlStr string, rules []richtext.StyleRule) error {
+	return docx.Convert(doc, htmlStr, docx.Options{Rules: rules})
 }
 
+// ExportDocxHandler — прежний маршрут экспорта в DOCX
 func ExportDocxHandler(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID"})
-		return
-	}
-
-	row := db.QueryRow(`SELECT rendered_content FROM documents WHERE id = $1`, id)
-	var rendered sql.NullString
-	if err := row.Scan(&rendered); err != nil || !rendered.Valid {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Документ не найден или пуст"})
-		return
-	}
-
-	path, err := GenerateDocxFromRendered(id, rendered.String)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка создания docx"})
-		return
-	}
-
-	c.FileAttachment(path, fmt.Sprintf("document_%d.docx", id))
+	exportAs(c, "docx")
 }
 
-// Парсит text-indent: 5.25em и возвращает значение в twips (1/20 pt)
-func parseIndent(style string) int {
-	re := regexp.MustCompile(`text-indent:\s*([\d.]+)em`)
-	matches := re.FindStringSubmatch(style)
-	if len(matches) >= 2 {
-		em, _ := strconv.ParseFloat(matches[1], 64)
-		return int(em * 20 * 12) // эм × 12pt × 20 (в twips)
-	}
-	return 0
-}
-
-func GetFontSizesByDocumentID(docID int) (map[string]int, error) {
+// GetStyleRulesByDocumentID возвращает CSS-правила шаблона документа из template_styles
+func GetStyleRulesByDocumentID(docID int) ([]richtext.StyleRule, error) {
 	rows, err := db.Query(`
-		SELECT ts.selector, ts.font_size_pt
+		SELECT ts.selector, ts.styles, ts.scope
 		FROM template_styles ts
 		JOIN documents d ON ts.template_id = d.template_id
-		WHERE d.id = $1 AND ts.scope = 'inline'
+		WHERE d.id = $1
+		ORDER BY ts.id
 	`, docID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	styleMap := make(map[string]int)
-	re := regexp.MustCompile(`data-style-id="([^"]+)"`)
-
+	var rules []richtext.StyleRule
 	for rows.Next() {
-		var selector string
-		var size int
-		if err := rows.Scan(&selector, &size); err == nil {
-			if matches := re.FindStringSubmatch(selector); len(matches) == 2 {
-				styleID := matches[1]
-				styleMap[styleID] = size
-			}
+		var selector, scope string
+		var stylesData []byte
+		if err := rows.Scan(&selector, &stylesData, &scope); err != nil {
+			return nil, err
 		}
-	}
-	return styleMap, nil
-}
-
-func ExportPdfHandler(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID"})
-		return
-	}
-
-	// Получаем оригинальный HTML из поля `content`
-	var content sql.NullString
-	err = db.QueryRow(`SELECT content FROM documents WHERE id = $1`, id).Scan(&content)
-	if err != nil || !content.Valid {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Документ не найден или пуст"})
-		return
-	}
 
-	// Создаём временные файлы
-	tmpDir := os.TempDir()
-	htmlPath := filepath.Join(tmpDir, fmt.Sprintf("document_%d.html", id))
-	pdfPath := filepath.Join(tmpDir, fmt.Sprintf("document_%d.pdf", id))
-
-	// Формируем полный HTML с указанием кодировки
-	htmlContent := fmt.Sprintf(`
-	<!DOCTYPE html>
-	<html lang="ru">
-	<head>
-	  <meta charset="UTF-8">
-	  <title>Документ %d</title>
-	</head>
-	<body>
-	%s
-	</body>
-	</html>
-	`, id, content.String)
-
-	// Сохраняем сформированный HTML во временный файл
-	if err := os.WriteFile(htmlPath, []byte(htmlContent), 0644); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка записи HTML"})
-		return
-	}
+		var styles map[string]interface{}
+		if err := json.Unmarshal(stylesData, &styles); err != nil {
+			log.Printf("⚠️ Некорректные стили для селектора %s: %v", selector, err)
+			continue
+		}
 
-	// Генерация PDF через wkhtmltopdf
-	cmd := exec.Command("wkhtmltopdf", htmlPath, pdfPath)
-	if err := cmd.Run(); err != nil {
-		log.Printf("❌ wkhtmltopdf error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка генерации PDF (wkhtmltopdf)"})
-		return
+		decl := make(map[string]string, len(styles))
+		for k, v := range styles {
+			decl[strings.ToLower(k)] = fmt.Sprint(v)
+		}
+		rules = append(rules, richtext.StyleRule{Selector: selector, Scope: scope, Declarations: decl})
 	}
+	return rules, rows.Err()
+}
 
-	// Отдаём PDF клиенту
-	c.FileAttachment(pdfPath, fmt.Sprintf("document_%d.pdf", id))
+// ExportPdfHandler — прежний маршрут экспорта в PDF
+func ExportPdfHandler(c *gin.Context) {
+	exportAs(c, "pdf")
 }
@@ -0,0 +1,694 @@
+package document
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+
+	"doc-generation/auth"
+	"doc-generation/templates"
+)
+
+// Статусы жизненного цикла документа
+const (
+	StatusDraft    = "draft"
+	StatusOnReview = "on_review"
+	StatusApproved = "approved"
+	StatusSigned   = "signed"
+	StatusArchived = "archived"
+)
+
+// Решения согласующих
+const (
+	DecisionPending  = "pending"
+	DecisionApproved = "approved"
+	DecisionRejected = "rejected"
+)
+
+// transition — допустимый переход и кто может его выполнить: пользователи с правом
+// permission, автор документа (author) и назначенные согласующие (approver)
+type transition struct {
+	from, to   string
+	permission string
+	author     bool
+	approver   bool
+}
+
+var transitions = []transition{
+	{from: StatusDraft, to: StatusOnReview, permission: auth.PermDocumentsEdit, author: true},
+	{from: StatusOnReview, to: StatusDraft, permission: auth.PermDocumentsApprove, author: true, approver: true},
+	{from: StatusOnReview, to: StatusApproved, permission: auth.PermDocumentsApprove, approver: true},
+	{from: StatusApproved, to: StatusDraft, permission: auth.PermDocumentsApprove},
+	{from: StatusApproved, to: StatusSigned, permission: auth.PermDocumentsApprove},
+	{from: StatusSigned, to: StatusArchived, permission: auth.PermDocumentsArchive, author: true},
+	{from: StatusDraft, to: StatusArchived, permission: auth.PermDocumentsEdit, author: true},
+}
+
+var (
+	ErrInvalidTransition   = errors.New("недопустимый переход статуса")
+	ErrTransitionForbidden = errors.New("недостаточно прав для смены статуса")
+	ErrDocumentLocked      = errors.New("документ нельзя изменять в текущем статусе")
+	ErrNotApprover         = errors.New("пользователь не согласует документ на текущем этапе")
+	ErrApprovalPending     = errors.New("не все согласующие приняли решение")
+	ErrAuthorApprover      = errors.New("автор не может согласовывать свой документ")
+	ErrRouteFromTemplate   = errors.New("согласующие документа заданы маршрутом шаблона")
+)
+
+// actor — пользователь, выполняющий переход, и его отношение к документу.
+// approver — согласующий текущего этапа, ещё не принявший решение.
+type actor struct {
+	user        *auth.User
+	permissions []string
+	author      bool
+	approver    bool
+}
+
+func (a actor) can(permission string) bool {
+	return slices.Contains(a.permissions, permission)
+}
+
+func (t transition) allows(a actor) bool {
+	return (t.author && a.author) || (t.approver && a.approver) || a.can(t.permission)
+}
+
+func findTransition(from, to string) (transition, bool) {
+	for _, t := range transitions {
+		if t.from == from && t.to == to {
+			return t, true
+		}
+	}
+	return transition{}, false
+}
+
+// StatusChange — результат смены статуса. При согласовании несколькими
+// согласующими статус меняется только после решения последнего из них.
+type StatusChange struct {
+	Status  string `json:"status"`
+	Changed bool   `json:"changed"`
+	Pending int    `json:"pending_approvers"`
+	Step    int    `json:"current_step,omitempty"`
+}
+
+// Approver — согласующий документа
+type Approver struct {
+	Step      int        `json:"step"`
+	UserID    int        `json:"user_id"`
+	Name      string     `json:"name"`
+	Decision  string     `json:"decision"`
+	Comment   string     `json:"comment"`
+	DecidedAt *time.Time `json:"decided_at"`
+}
+
+// StatusHistoryEntry — запись истории смены статусов
+type StatusHistoryEntry struct {
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	UserID     *int      `json:"user_id"`
+	UserName   string    `json:"user_name"`
+	Comment    string    `json:"comment"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// GetDocumentStatus возвращает статус документа
+func GetDocumentStatus(documentID int) (string, error) {
+	var status string
+	err := db.QueryRow(`SELECT status FROM documents WHERE id = $1`, documentID).Scan(&status)
+	return status, err
+}
+
+// EnsureEditable возвращает ErrDocumentLocked, если документ уже не черновик
+func EnsureEditable(documentID int) error {
+	status, err := GetDocumentStatus(documentID)
+	if err != nil {
+		return err
+	}
+	if status != StatusDraft {
+		return ErrDocumentLocked
+	}
+	return nil
+}
+
+// currentStep — этап маршрута, на котором документ согласуется сейчас:
+// первый этап, где есть не согласовавшие
+const currentStep = `(SELECT MIN(step) FROM document_approvers WHERE document_id = $1 AND decision <> 'approved')`
+
+// pendingApprovals возвращает число ещё не согласовавших и текущий этап маршрута
+func pendingApprovals(q queryer, documentID int) (int, sql.NullInt64, error) {
+	var pending int
+	var step sql.NullInt64
+	err := q.QueryRow(`
+		SELECT COUNT(*), `+currentStep+` FROM document_approvers WHERE document_id = $1 AND decision <> 'approved'
+	`, documentID).Scan(&pending, &step)
+	return pending, step, err
+}
+
+// loadActor определяет роль пользователя, авторство и участие в согласовании документа
+func loadActor(q queryer, documentID, userID int) (actor, error) {
+	user, err := auth.GetUserByID(userID)
+	if err != nil {
+		return actor{}, err
+	}
+	perms, err := auth.UserPermissions(userID)
+	if err != nil {
+		return actor{}, err
+	}
+	a := actor{user: user, permissions: perms}
+	err = q.QueryRow(`
+		SELECT d.user_id = $2,
+		       EXISTS (
+		           SELECT 1 FROM document_approvers a
+		           WHERE a.document_id = d.id AND a.user_id = $2 AND a.decision = 'pending'
+		             AND a.step = `+currentStep+`
+		       )
+		FROM documents d WHERE d.id = $1
+	`, documentID, userID).Scan(&a.author, &a.approver)
+	return a, err
+}
+
+// ChangeDocumentStatus переводит документ в статус to от имени userID.
+// Согласующий, переводящий документ в approved, фиксирует своё решение; статус
+// меняется, когда согласуют все этапы. Возврат согласующим в draft — отказ в согласовании.
+func ChangeDocumentStatus(documentID, userID int, to, comment string) (*StatusChange, error) {
+	return changeStatus(documentID, userID, to, comment, false)
+}
+
+// DecideDocument записывает решение согласующего текущего этапа. Отказ возвращает
+// документ в черновик, даже если согласующий — его автор.
+func DecideDocument(documentID, userID int, approve bool, comment string) (*StatusChange, error) {
+	to := StatusDraft
+	if approve {
+		to = StatusApproved
+	}
+	return changeStatus(documentID, userID, to, comment, true)
+}
+
+// changeStatus выполняет переход; asApprover — пользователь действует только как согласующий
+func changeStatus(documentID, userID int, to, comment string, asApprover bool) (*StatusChange, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var from string
+	if err := tx.QueryRow(`SELECT status FROM documents WHERE id = $1 FOR UPDATE`, documentID).Scan(&from); err != nil {
+		return nil, err
+	}
+	t, ok := findTransition(from, to)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s → %s", ErrInvalidTransition, from, to)
+	}
+	a, err := loadActor(tx, documentID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if asApprover && !a.approver {
+		return nil, ErrNotApprover
+	}
+	if !t.allows(a) {
+		return nil, ErrTransitionForbidden
+	}
+
+	result := &StatusChange{Status: from}
+	switch {
+	case from == StatusOnReview && to == StatusApproved && a.approver:
+		if err := setDecision(tx, documentID, userID, DecisionApproved, comment); err != nil {
+			return nil, err
+		}
+		pending, step, err := pendingApprovals(tx, documentID)
+		if err != nil {
+			return nil, err
+		}
+		if pending > 0 {
+			result.Pending, result.Step = pending, int(step.Int64)
+			return result, tx.Commit()
+		}
+
+	case from == StatusOnReview && to == StatusApproved:
+		// право documents.approve не отменяет маршрут: пока есть несогласовавшие,
+		// документ согласуют только они
+		pending, step, err := pendingApprovals(tx, documentID)
+		if err != nil {
+			return nil, err
+		}
+		if pending > 0 {
+			return nil, fmt.Errorf("%w: осталось %d, этап %d", ErrApprovalPending, pending, step.Int64)
+		}
+
+	case from == StatusOnReview && to == StatusDraft && a.approver && (asApprover || !a.author):
+		if err := setDecision(tx, documentID, userID, DecisionRejected, comment); err != nil {
+			return nil, err
+		}
+
+	case to == StatusOnReview:
+		// новый круг согласования
+		if _, err := tx.Exec(`
+			UPDATE document_approvers SET decision = $2, comment = '', decided_at = NULL WHERE document_id = $1
+		`, documentID, DecisionPending); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := tx.Exec(`UPDATE documents SET status = $1 WHERE id = $2`, to, documentID); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO document_status_history (document_id, from_status, to_status, user_id, comment)
+		VALUES ($1, $2, $3, $4, $5)
+	`, documentID, from, to, userID, comment); err != nil {
+		return nil, err
+	}
+
+	result.Status, result.Changed = to, true
+	return result, tx.Commit()
+}
+
+func setDecision(tx *sql.Tx, documentID, userID int, decision, comment string) error {
+	_, err := tx.Exec(`
+		UPDATE document_approvers SET decision = $3, comment = $4, decided_at = NOW()
+		WHERE document_id = $1 AND user_id = $2
+	`, documentID, userID, decision, comment)
+	return err
+}
+
+// SetDocumentApprovers заменяет маршрут согласования черновика. Согласующие должны
+// состоять в организации документа, автор согласующим быть не может. Если у шаблона
+// документа есть маршрут согласования, согласующие берутся из него и не меняются
+// (ErrRouteFromTemplate). Решения оставшихся согласующих сохраняются.
+func SetDocumentApprovers(documentID int, steps []templates.ApprovalStep) error {
+	userIDs := make([]int, len(steps))
+	for i, s := range steps {
+		userIDs[i] = s.UserID
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var authorID int
+	var status string
+	var templateRoute bool
+	if err := tx.QueryRow(`
+		SELECT d.user_id, d.status,
+		       EXISTS (SELECT 1 FROM template_approval_steps s WHERE s.template_id = d.template_id)
+		FROM documents d WHERE d.id = $1
+		FOR UPDATE
+	`, documentID).Scan(&authorID, &status, &templateRoute); err != nil {
+		return err
+	}
+	if status != StatusDraft {
+		return ErrDocumentLocked
+	}
+	if templateRoute {
+		return ErrRouteFromTemplate
+	}
+	if slices.Contains(userIDs, authorID) {
+		return ErrAuthorApprover
+	}
+
+	var found int
+	if err := tx.QueryRow(`
+		SELECT COUNT(*) FROM users u JOIN documents d ON d.organization_id = u.organization_id
+		WHERE d.id = $1 AND u.id = ANY($2)
+	`, documentID, pq.Array(userIDs)).Scan(&found); err != nil {
+		return err
+	}
+	if found != len(userIDs) {
+		return templates.ErrUnknownApprover
+	}
+
+	if _, err := tx.Exec(`
+		DELETE FROM document_approvers WHERE document_id = $1 AND NOT (user_id = ANY($2))
+	`, documentID, pq.Array(userIDs)); err != nil {
+		return err
+	}
+	for _, s := range steps {
+		if _, err := tx.Exec(`
+			INSERT INTO document_approvers (document_id, user_id, step) VALUES ($1, $2, $3)
+			ON CONFLICT (document_id, user_id) DO UPDATE SET step = EXCLUDED.step
+		`, documentID, s.UserID, s.Step); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetDocumentApprovers возвращает согласующих документа
+func GetDocumentApprovers(documentID int) ([]Approver, error) {
+	rows, err := db.Query(`
+		SELECT a.step, a.user_id, u.first_name || ' ' || u.last_name, a.decision, a.comment, a.decided_at
+		FROM document_approvers a
+		JOIN users u ON u.id = a.user_id
+		WHERE a.document_id = $1
+		ORDER BY a.step, u.last_name, u.first_name
+	`, documentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	approvers := []Approver{}
+	for rows.Next() {
+		var a Approver
+		var decidedAt sql.NullTime
+		if err := rows.Scan(&a.Step, &a.UserID, &a.Name, &a.Decision, &a.Comment, &decidedAt); err != nil {
+			return nil, err
+		}
+		if decidedAt.Valid {
+			a.DecidedAt = &decidedAt.Time
+		}
+		approvers = append(approvers, a)
+	}
+	return approvers, rows.Err()
+}
+
+// GetDocumentStatusHistory возвращает историю смены статусов в хронологическом порядке
+func GetDocumentStatusHistory(documentID int) ([]StatusHistoryEntry, error) {
+	rows, err := db.Query(`
+		SELECT h.from_status, h.to_status, h.user_id, COALESCE(u.first_name || ' ' || u.last_name, ''),
+		       h.comment, h.created_at
+		FROM document_status_history h
+		LEFT JOIN users u ON u.id = h.user_id
+		WHERE h.document_id = $1
+		ORDER BY h.created_at, h.id
+	`, documentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []StatusHistoryEntry{}
+	for rows.Next() {
+		var e StatusHistoryEntry
+		var userID sql.NullInt64
+		if err := rows.Scan(&e.FromStatus, &e.ToStatus, &userID, &e.UserName, &e.Comment, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if userID.Valid {
+			id := int(userID.Int64)
+			e.UserID = &id
+		}
+		history = append(history, e)
+	}
+	return history, rows.Err()
+}
+
+// ---------- обработчики ----------
+
+// ensureEditable отвечает 403, если пользователь не может править документ,
+// и 409, если документ нельзя изменять в текущем статусе. Права берутся
+// из контекста, их сохраняет checkDocumentAccess.
+func ensureEditable(c *gin.Context, documentID int) bool {
+	if access, _ := c.Get(documentAccessKey); access == nil || !access.(DocumentAccess).Edit {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Недостаточно прав для изменения документа"})
+		return false
+	}
+
+	err := EnsureEditable(documentID)
+	if err == nil {
+		return true
+	}
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Документ не найден"})
+		return false
+	}
+	if errors.Is(err, ErrDocumentLocked) {
+		status, _ := GetDocumentStatus(documentID)
+		c.JSON(http.StatusConflict, gin.H{"error": "Документ можно изменять только в статусе черновика", "status": status})
+		return false
+	}
+	log.Printf("❌ Ошибка проверки статуса документа ID=%d: %v", documentID, err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка проверки статуса документа"})
+	return false
+}
+
+// GetStatusHandler возвращает статус, согласующих и переходы, доступные текущему пользователю
+func GetStatusHandler(c *gin.Context) {
+	documentID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID документа"})
+		return
+	}
+
+	status, err := GetDocumentStatus(documentID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Документ не найден"})
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Ошибка получения статуса документа ID=%d: %v", documentID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении статуса"})
+		return
+	}
+
+	approvers, err := GetDocumentApprovers(documentID)
+	if err != nil {
+		log.Printf("❌ Ошибка получения согласующих документа ID=%d: %v", documentID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении согласующих"})
+		return
+	}
+
+	a, err := loadActor(db, documentID, c.GetInt("user_id"))
+	if err != nil {
+		log.Printf("❌ Ошибка получения пользователя ID=%d: %v", c.GetInt("user_id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении статуса"})
+		return
+	}
+	pending, _, err := pendingApprovals(db, documentID)
+	if err != nil {
+		log.Printf("❌ Ошибка получения согласующих документа ID=%d: %v", documentID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении статуса"})
+		return
+	}
+	available := []string{}
+	for _, t := range transitions {
+		if t.from != status || !t.allows(a) {
+			continue
+		}
+		if t.to == StatusApproved && pending > 0 && !a.approver {
+			continue
+		}
+		available = append(available, t.to)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": status, "approvers": approvers, "available": available})
+}
+
+// ChangeStatusRequest — запрос смены статуса
+type ChangeStatusRequest struct {
+	Status  string `json:"status"`
+	Comment string `json:"comment"`
+}
+
+// ChangeStatusHandler переводит документ в новый статус
+func ChangeStatusHandler(c *gin.Context) {
+	documentID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID документа"})
+		return
+	}
+
+	var req ChangeStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Status == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Не указан статус"})
+		return
+	}
+
+	// на согласование уходит только документ с корректно заполненными полями
+	if req.Status == StatusOnReview {
+		fieldErrors, err := ValidateDocument(documentID)
+		if err != nil && err != sql.ErrNoRows {
+			log.Printf("❌ Ошибка валидации документа ID=%d: %v", documentID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось проверить документ"})
+			return
+		}
+		if len(fieldErrors) > 0 {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Документ заполнен с ошибками", "fields": fieldErrors})
+			return
+		}
+	}
+
+	userID := c.GetInt("user_id")
+	result, err := ChangeDocumentStatus(documentID, userID, req.Status, strings.TrimSpace(req.Comment))
+	switch {
+	case err == sql.ErrNoRows:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Документ не найден"})
+	case errors.Is(err, ErrInvalidTransition), errors.Is(err, ErrApprovalPending):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrTransitionForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Недостаточно прав для смены статуса"})
+	case err != nil:
+		log.Printf("❌ Ошибка смены статуса документа ID=%d: %v", documentID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при смене статуса"})
+	default:
+		if result.Changed {
+			log.Printf("✅ Документ ID=%d переведён в статус %s пользователем ID=%d", documentID, result.Status, userID)
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// SetApproversRequest — маршрут согласования по этапам, как у шаблона.
+// user_ids — один этап с параллельными согласующими.
+type SetApproversRequest struct {
+	Steps   [][]int `json:"steps"`
+	UserIDs []int   `json:"user_ids"`
+}
+
+// SetApproversHandler назначает согласующих черновика. Доступно автору и праву
+// documents.approve; документам с маршрутом шаблона согласующих не назначают.
+func SetApproversHandler(c *gin.Context) {
+	documentID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID документа"})
+		return
+	}
+
+	var req SetApproversRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный JSON"})
+		return
+	}
+	if len(req.Steps) == 0 {
+		req.Steps = [][]int{req.UserIDs}
+	}
+	steps, err := templates.ApprovalSteps(req.Steps)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	status, err := GetDocumentStatus(documentID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Документ не найден"})
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Ошибка получения статуса документа ID=%d: %v", documentID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при назначении согласующих"})
+		return
+	}
+	if status != StatusDraft {
+		c.JSON(http.StatusConflict, gin.H{"error": "Согласующих можно менять только в черновике", "status": status})
+		return
+	}
+
+	a, err := loadActor(db, documentID, c.GetInt("user_id"))
+	if err != nil {
+		log.Printf("❌ Ошибка получения пользователя ID=%d: %v", c.GetInt("user_id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при назначении согласующих"})
+		return
+	}
+	if !a.author && !a.can(auth.PermDocumentsApprove) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Недостаточно прав для назначения согласующих"})
+		return
+	}
+
+	err = SetDocumentApprovers(documentID, steps)
+	if err == templates.ErrUnknownApprover || err == ErrAuthorApprover {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err == ErrRouteFromTemplate || err == ErrDocumentLocked {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Ошибка назначения согласующих документа ID=%d: %v", documentID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при назначении согласующих"})
+		return
+	}
+
+	approvers, err := GetDocumentApprovers(documentID)
+	if err != nil {
+		log.Printf("❌ Ошибка получения согласующих документа ID=%d: %v", documentID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении согласующих"})
+		return
+	}
+	c.JSON(http.StatusOK, approvers)
+}
+
+// StatusHistoryHandler возвращает историю смены статусов документа
+func StatusHistoryHandler(c *gin.Context) {
+	documentID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID документа"})
+		return
+	}
+
+	history, err := GetDocumentStatusHistory(documentID)
+	if err != nil {
+		log.Printf("❌ Ошибка получения истории статусов документа ID=%d: %v", documentID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении истории статусов"})
+		return
+	}
+	c.JSON(http.StatusOK, history)
+}
+
+// DecisionRequest — решение согласующего
+type DecisionRequest struct {
+	Comment string `json:"comment"`
+}
+
+// ApproveDocumentHandler — согласование документа согласующим текущего этапа
+func ApproveDocumentHandler(c *gin.Context) {
+	decideHandler(c, true)
+}
+
+// RejectDocumentHandler — отказ в согласовании; документ возвращается в черновик.
+// Комментарий с причиной обязателен.
+func RejectDocumentHandler(c *gin.Context) {
+	decideHandler(c, false)
+}
+
+func decideHandler(c *gin.Context, approve bool) {
+	documentID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID документа"})
+		return
+	}
+
+	var req DecisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный JSON"})
+		return
+	}
+	req.Comment = strings.TrimSpace(req.Comment)
+	if !approve && req.Comment == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Укажите причину отказа"})
+		return
+	}
+
+	userID := c.GetInt("user_id")
+	result, err := DecideDocument(documentID, userID, approve, req.Comment)
+	switch {
+	case err == sql.ErrNoRows:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Документ не найден"})
+	case errors.Is(err, ErrInvalidTransition):
+		c.JSON(http.StatusConflict, gin.H{"error": "Документ не находится на согласовании"})
+	case errors.Is(err, ErrNotApprover), errors.Is(err, ErrTransitionForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Вы не согласуете документ на текущем этапе"})
+	case err != nil:
+		log.Printf("❌ Ошибка решения по документу ID=%d: %v", documentID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при сохранении решения"})
+	default:
+		if approve {
+			log.Printf("✅ Документ ID=%d согласован пользователем ID=%d", documentID, userID)
+		} else {
+			log.Printf("⚠️ Документ ID=%d отклонён пользователем ID=%d и возвращён в черновик", documentID, userID)
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
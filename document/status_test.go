@@ -0,0 +1,42 @@
+package document
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"doc-generation/templates"
+)
+
+func TestSetDocumentApproversRestrictions(t *testing.T) {
+	const author = 10
+	tests := []struct {
+		name          string
+		status        string
+		templateRoute bool
+		approvers     []int
+		want          error
+	}{
+		{name: "на согласовании", status: StatusOnReview, approvers: []int{11}, want: ErrDocumentLocked},
+		{name: "маршрут шаблона", status: StatusDraft, templateRoute: true, approvers: []int{11}, want: ErrRouteFromTemplate},
+		{name: "автор согласующим", status: StatusDraft, approvers: []int{11, author}, want: ErrAuthorApprover},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := mockDB(t)
+			mock.ExpectBegin()
+			mock.ExpectQuery(`FROM documents d WHERE d.id = \$1\s+FOR UPDATE`).
+				WithArgs(5).
+				WillReturnRows(sqlmock.NewRows([]string{"user_id", "status", "exists"}).AddRow(author, tt.status, tt.templateRoute))
+			mock.ExpectRollback()
+
+			steps, err := templates.ApprovalSteps([][]int{tt.approvers})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := SetDocumentApprovers(5, steps); err != tt.want {
+				t.Errorf("SetDocumentApprovers: %v, ожидалось %v", err, tt.want)
+			}
+		})
+	}
+}
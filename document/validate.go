@@ -0,0 +1,37 @@
+package document
+
+import (
+	"doc-generation/render"
+	"doc-generation/templates"
+)
+
+// ValidateDocument проверяет сохранённые поля документа по типам тегов.
+// Пустое поле с заданным значением по умолчанию считается заполненным.
+func ValidateDocument(documentID int) ([]templates.FieldError, error) {
+	var orgID int
+	var content string
+	err := db.QueryRow(`SELECT organization_id, content FROM documents WHERE id = $1`, documentID).Scan(&orgID, &content)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := templates.GetTagsByNames(orgID, render.Fields(content))
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := GetDocumentData(documentID)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(tags))
+	for name, tag := range tags {
+		values[name] = data[name]
+		if values[name] == "" {
+			values[name] = tag.DefaultValue
+		}
+	}
+
+	return templates.ValidateValues(tags, values), nil
+}
@@ -0,0 +1,176 @@
+// Package docx переводит разобранный HTML (richtext) в документ Word через gooxml.
+package docx
+
+import (
+	"fmt"
+
+	"baliance.com/gooxml/color"
+	"baliance.com/gooxml/document"
+	"baliance.com/gooxml/measurement"
+	"baliance.com/gooxml/schema/soo/wml"
+
+	"doc-generation/richtext"
+)
+
+// Options — параметры преобразования
+type Options struct {
+	// Rules — CSS-правила шаблона (template_styles), глобальные и по data-style-id
+	Rules []richtext.StyleRule
+}
+
+// alignments — выравнивание абзаца по значению text-align
+var alignments = map[string]wml.ST_Jc{
+	"left":    wml.ST_JcLeft,
+	"center":  wml.ST_JcCenter,
+	"right":   wml.ST_JcRight,
+	"justify": wml.ST_JcBoth,
+}
+
+var autoColor = "auto"
+
+// bullets — маркеры для уровней маркированного списка
+var bullets = []string{"•", "◦", "▪"}
+
+// Convert разбирает HTML и добавляет его содержимое в документ Word
+func Convert(doc *document.Document, htmlStr string, opts Options) error {
+	parsed, err := richtext.Parse(htmlStr, opts.Rules)
+	if err != nil {
+		return err
+	}
+	Write(doc, parsed, opts)
+	return nil
+}
+
+// Write добавляет блоки разобранного документа в документ Word
+func Write(doc *document.Document, parsed *richtext.Document, opts Options) {
+	w := &writer{doc: doc, opts: opts, numbering: make(map[int]document.NumberingDefinition)}
+	for _, b := range parsed.Blocks {
+		w.writeBlock(b)
+	}
+}
+
+type writer struct {
+	doc       *document.Document
+	opts      Options
+	numbering map[int]document.NumberingDefinition // ListInfo.ID → определение нумерации
+}
+
+func (w *writer) writeBlock(b richtext.Block) {
+	if b.Table != nil {
+		w.writeTable(b.Table)
+		return
+	}
+	w.writeParagraph(w.doc.AddParagraph(), b)
+}
+
+func (w *writer) writeParagraph(p document.Paragraph, b richtext.Block) {
+	if b.Heading > 0 {
+		p.SetStyle(fmt.Sprintf("Heading%d", b.Heading))
+	}
+	w.applyParagraphFormat(p.Properties(), b.Format)
+	if b.List != nil {
+		p.SetNumberingDefinition(w.numberingFor(b.List))
+		p.SetNumberingLevel(b.List.Level)
+	}
+
+	for _, r := range b.Runs {
+		w.writeRun(p, r)
+	}
+}
+
+func (w *writer) writeRun(p document.Paragraph, r richtext.Run) {
+	run := p.AddRun()
+	if r.Break {
+		run.AddBreak()
+		return
+	}
+
+	props := run.Properties()
+	if r.Format.Bold {
+		props.SetBold(true)
+	}
+	if r.Format.Italic {
+		props.SetItalic(true)
+	}
+	if r.Format.Underline {
+		props.SetUnderline(wml.ST_UnderlineSingle, color.Auto)
+	}
+	if r.Format.Strike {
+		props.SetStrikeThrough(true)
+	}
+	if r.Format.FontFamily != "" {
+		props.SetFontFamily(r.Format.FontFamily)
+	}
+	if r.Format.FontSizePt > 0 {
+		props.SetSize(pt(r.Format.FontSizePt))
+	}
+	if r.Format.Color != "" {
+		props.SetColor(color.FromHex(r.Format.Color))
+	}
+	if r.Format.LetterSpacing != 0 {
+		props.SetCharacterSpacing(pt(r.Format.LetterSpacing))
+	}
+	if r.Format.Background != "" {
+		// произвольный цвет фона задаётся заливкой: подсветка Word ограничена палитрой
+		fill := r.Format.Background
+		props.X().Shd = &wml.CT_Shd{ValAttr: wml.ST_ShdClear, ColorAttr: &autoColor, FillAttr: &fill}
+	}
+
+	run.AddText(r.Text)
+}
+
+func (w *writer) applyParagraphFormat(props document.ParagraphProperties, f richtext.ParagraphFormat) {
+	if jc, ok := alignments[f.Align]; ok {
+		props.SetAlignment(jc)
+	}
+	if f.FirstIndent > 0 {
+		props.SetFirstLineIndent(pt(f.FirstIndent))
+	}
+	if f.IndentLeft > 0 {
+		props.SetStartIndent(pt(f.IndentLeft))
+	}
+	if f.IndentRight > 0 {
+		props.SetEndIndent(pt(f.IndentRight))
+	}
+	if f.SpaceBefore > 0 || f.SpaceAfter > 0 {
+		props.SetSpacing(pt(f.SpaceBefore), pt(f.SpaceAfter))
+	}
+	switch {
+	case f.LineHeightPt > 0:
+		props.SetLineSpacing(pt(f.LineHeightPt), wml.ST_LineSpacingRuleExact)
+	case f.LineHeight > 0:
+		// в режиме auto интервал задаётся в 240-х долях строки: 1.5 → 360 twips = 18pt
+		props.SetLineSpacing(pt(f.LineHeight*12), wml.ST_LineSpacingRuleAuto)
+	}
+}
+
+// pt переводит пункты в measurement.Distance
+func pt(v float64) measurement.Distance {
+	return measurement.Distance(v) * measurement.Point
+}
+
+// numberingFor создаёт отдельное определение нумерации для каждого списка,
+// чтобы нумерация <ol> начиналась заново
+func (w *writer) numberingFor(list *richtext.ListInfo) document.NumberingDefinition {
+	if nd, ok := w.numbering[list.ID]; ok {
+		return nd
+	}
+
+	nd := w.doc.Numbering.AddDefinition()
+	for lvl := 0; lvl < 9; lvl++ {
+		level := nd.AddLevel()
+		if list.Ordered {
+			level.SetFormat(wml.ST_NumberFormatDecimal)
+			level.SetText(fmt.Sprintf("%%%d.", lvl+1))
+		} else {
+			level.SetFormat(wml.ST_NumberFormatBullet)
+			level.SetText(bullets[lvl%len(bullets)])
+		}
+		level.SetAlignment(wml.ST_JcLeft)
+		level.Properties().SetStartIndent(measurement.Distance(lvl+1) * 0.5 * measurement.Inch)
+		level.Properties().SetHangingIndent(0.25 * measurement.Inch)
+	}
+
+	w.numbering[list.ID] = nd
+	return nd
+}
@@ -0,0 +1,253 @@
+package docx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"baliance.com/gooxml/document"
+)
+
+var update = flag.Bool("update", false, "перезаписать golden-файлы в testdata")
+
+// inputDir — HTML-входы общие с тестами richtext
+const inputDir = "../richtext/testdata"
+
+// documentXML конвертирует HTML в новый документ и возвращает его word/document.xml
+func documentXML(t *testing.T, htmlStr string) []byte {
+	t.Helper()
+
+	doc := document.New()
+	if err := Convert(doc, htmlStr, Options{}); err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := doc.Save(&buf); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range zr.File {
+		if f.Name != "word/document.xml" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+		data, err := io.ReadAll(rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	t.Fatal("в архиве нет word/document.xml")
+	return nil
+}
+
+// xmlNode — элемент document.xml с атрибутами и вложенными элементами
+type xmlNode struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Nodes   []xmlNode  `xml:",any"`
+	Text    string     `xml:",chardata"`
+}
+
+func (n xmlNode) attr(local string) string {
+	for _, a := range n.Attrs {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func (n xmlNode) child(local string) (xmlNode, bool) {
+	for _, c := range n.Nodes {
+		if c.XMLName.Local == local {
+			return c, true
+		}
+	}
+	return xmlNode{}, false
+}
+
+// outline сводит document.xml к структуре, которую задаёт конвертер: абзацы со
+// стилем, выравниванием и нумерацией, фрагменты текста с начертанием, таблицы
+// с объединёнными ячейками. Размеры, цвета и служебная разметка gooxml в сводку
+// не попадают, номера определений нумерации заменяются порядковыми.
+func outline(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	var doc xmlNode
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("разбор document.xml: %v", err)
+	}
+	body, ok := doc.child("body")
+	if !ok {
+		t.Fatal("в document.xml нет w:body")
+	}
+
+	var b strings.Builder
+	lists := make(map[string]int)
+	line := func(depth int, format string, args ...interface{}) {
+		b.WriteString(strings.Repeat("  ", depth))
+		fmt.Fprintf(&b, format, args...)
+		b.WriteByte('\n')
+	}
+
+	var paragraph func(p xmlNode, depth int)
+	paragraph = func(p xmlNode, depth int) {
+		head := "p"
+		if ppr, ok := p.child("pPr"); ok {
+			if style, ok := ppr.child("pStyle"); ok {
+				head += " style=" + style.attr("val")
+			}
+			if jc, ok := ppr.child("jc"); ok {
+				head += " jc=" + jc.attr("val")
+			}
+			if num, ok := ppr.child("numPr"); ok {
+				id, _ := num.child("numId")
+				lvl, _ := num.child("ilvl")
+				if _, seen := lists[id.attr("val")]; !seen {
+					lists[id.attr("val")] = len(lists) + 1
+				}
+				head += fmt.Sprintf(" list=%d lvl=%s", lists[id.attr("val")], lvl.attr("val"))
+			}
+		}
+		line(depth, "%s", head)
+
+		for _, r := range p.Nodes {
+			if r.XMLName.Local != "r" {
+				continue
+			}
+			var flags []string
+			if rpr, ok := r.child("rPr"); ok {
+				for _, f := range []string{"b", "i", "u", "strike"} {
+					if el, ok := rpr.child(f); ok && el.attr("val") != "false" && el.attr("val") != "0" && el.attr("val") != "none" {
+						flags = append(flags, f)
+					}
+				}
+			}
+			for _, c := range r.Nodes {
+				switch c.XMLName.Local {
+				case "t":
+					line(depth+1, "r%s %q", strings.Join(append([]string{""}, flags...), " "), c.Text)
+				case "br":
+					line(depth+1, "br")
+				}
+			}
+		}
+	}
+
+	for _, el := range body.Nodes {
+		switch el.XMLName.Local {
+		case "p":
+			paragraph(el, 0)
+		case "tbl":
+			line(0, "tbl")
+			for _, tr := range el.Nodes {
+				if tr.XMLName.Local != "tr" {
+					continue
+				}
+				line(1, "tr")
+				for _, tc := range tr.Nodes {
+					if tc.XMLName.Local != "tc" {
+						continue
+					}
+					head := "tc"
+					if tcpr, ok := tc.child("tcPr"); ok {
+						if span, ok := tcpr.child("gridSpan"); ok {
+							head += " span=" + span.attr("val")
+						}
+						if merge, ok := tcpr.child("vMerge"); ok {
+							v := merge.attr("val")
+							if v == "" {
+								v = "continue" // значение по умолчанию в OOXML
+							}
+							head += " vmerge=" + v
+						}
+					}
+					line(2, "%s", head)
+					for _, p := range tc.Nodes {
+						if p.XMLName.Local == "p" {
+							paragraph(p, 3)
+						}
+					}
+				}
+			}
+		}
+	}
+	return []byte(b.String())
+}
+
+// TestWriteGolden сравнивает структуру document.xml для каждого richtext/testdata/*.html
+// с testdata/<имя>.outline. Обновить эталоны: go test ./docx -update
+func TestWriteGolden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join(inputDir, "*.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) == 0 {
+		t.Fatalf("нет входных файлов в %s", inputDir)
+	}
+
+	for _, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), ".html")
+		t.Run(name, func(t *testing.T) {
+			src, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := outline(t, documentXML(t, string(src)))
+
+			golden := filepath.Join("testdata", name+".outline")
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (создать эталон: go test ./docx -update)", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("структура document.xml для %s отличается от %s:\n%s", input, golden, got)
+			}
+		})
+	}
+}
+
+var numIDRe = regexp.MustCompile(`<w:numId w:val="(\d+)"`)
+
+// TestWriteRestartsOrderedLists проверяет, что два <ol> ссылаются на разные
+// определения нумерации, а элементы одного списка — на одно
+func TestWriteRestartsOrderedLists(t *testing.T) {
+	xml := documentXML(t, `<ol><li>a</li><li>b</li></ol><p>x</p><ol><li>c</li></ol>`)
+
+	var ids []string
+	for _, m := range numIDRe.FindAllSubmatch(xml, -1) {
+		ids = append(ids, string(m[1]))
+	}
+	if len(ids) != 3 {
+		t.Fatalf("numId у %d абзацев, ожидалось 3:\n%s", len(ids), xml)
+	}
+	if ids[0] != ids[1] {
+		t.Errorf("элементы одного списка с разными numId: %v", ids)
+	}
+	if ids[0] == ids[2] {
+		t.Errorf("второй <ol> продолжает нумерацию первого: %v", ids)
+	}
+}
@@ -0,0 +1,181 @@
+package docx
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"baliance.com/gooxml/document"
+	"baliance.com/gooxml/schema/soo/wml"
+
+	"doc-generation/render"
+)
+
+// placeholderRe находит {{…}} в тексте абзаца
+var placeholderRe = regexp.MustCompile(`{{[^{}]+}}`)
+
+// blockTagRe — блочные теги {{#if}}, {{#each}}, {{else}}, {{/…}}
+var blockTagRe = regexp.MustCompile(`{{\s*(?:[#/]|else\s*}})`)
+
+// Fill подставляет значения в документ Word: теги {{…}} в абзацах тела, таблиц,
+// колонтитулов и поля слияния MERGEFIELD. Word часто разбивает тег на несколько
+// прогонов — значение записывается в прогон, где тег начинается, и получает его оформление.
+//
+// Блоки {{#if}} и {{#each}} поддерживаются в пределах одного абзаца; такой абзац
+// выполняется целиком и получает оформление первого прогона.
+func Fill(doc *document.Document, values render.Values) error {
+	seen := make(map[*wml.CT_P]bool)
+	var paragraphs []document.Paragraph
+	add := func(ps []document.Paragraph) {
+		for _, p := range ps {
+			if !seen[p.X()] {
+				seen[p.X()] = true
+				paragraphs = append(paragraphs, p)
+			}
+		}
+	}
+	addTables := func(tables []document.Table) {
+		for _, t := range tables {
+			for _, row := range t.Rows() {
+				for _, cell := range row.Cells() {
+					add(cell.Paragraphs())
+				}
+			}
+		}
+	}
+
+	add(doc.Paragraphs())
+	addTables(doc.Tables())
+	for _, h := range doc.Headers() {
+		add(h.Paragraphs())
+		addTables(h.Tables())
+	}
+	for _, f := range doc.Footers() {
+		add(f.Paragraphs())
+		addTables(f.Tables())
+	}
+
+	for _, p := range paragraphs {
+		if err := fillParagraph(p, values); err != nil {
+			return err
+		}
+	}
+
+	doc.MailMerge(mergeValues(doc.MergeFields(), values))
+	return nil
+}
+
+func fillParagraph(p document.Paragraph, values render.Values) error {
+	runs := p.Runs()
+	texts := make([]string, len(runs))
+	starts := make([]int, len(runs))
+	var full strings.Builder
+	for i, r := range runs {
+		starts[i] = full.Len()
+		texts[i] = runText(r.X())
+		full.WriteString(texts[i])
+	}
+	text := full.String()
+	if !strings.Contains(text, "{{") {
+		return nil
+	}
+
+	changed := make([]bool, len(runs))
+	if blockTagRe.MatchString(text) {
+		out, err := render.ExecuteText(text, values)
+		if err != nil {
+			return fmt.Errorf("абзац %q: %w", text, err)
+		}
+		// прогоны без текста (рисунки, табуляции) не трогаем
+		first := -1
+		for i := range texts {
+			if texts[i] == "" {
+				continue
+			}
+			if first < 0 {
+				first = i
+			}
+			texts[i], changed[i] = "", true
+		}
+		texts[first] = out
+	} else {
+		// с конца, чтобы смещения ещё не обработанных тегов не сдвигались
+		matches := placeholderRe.FindAllStringIndex(text, -1)
+		for k := len(matches) - 1; k >= 0; k-- {
+			start, end := matches[k][0], matches[k][1]
+			value, err := render.ExecuteText(text[start:end], values)
+			if err != nil {
+				return fmt.Errorf("тег %s: %w", text[start:end], err)
+			}
+
+			i, j := runAt(starts, start), runAt(starts, end-1)
+			tail := ""
+			if i == j {
+				tail = texts[i][end-starts[i]:]
+			} else {
+				for m := i + 1; m < j; m++ {
+					texts[m], changed[m] = "", true
+				}
+				texts[j], changed[j] = texts[j][end-starts[j]:], true
+			}
+			texts[i], changed[i] = texts[i][:start-starts[i]]+value+tail, true
+		}
+	}
+
+	for i, r := range runs {
+		if changed[i] {
+			setRunText(r, texts[i])
+		}
+	}
+	return nil
+}
+
+// runText — текст прогона (только элементы w:t)
+func runText(r *wml.CT_R) string {
+	var sb strings.Builder
+	for _, ic := range r.EG_RunInnerContent {
+		if ic.T != nil {
+			sb.WriteString(ic.T.Content)
+		}
+	}
+	return sb.String()
+}
+
+// runAt возвращает индекс прогона, которому принадлежит позиция pos в тексте абзаца
+func runAt(starts []int, pos int) int {
+	i := 0
+	for k, s := range starts {
+		if s <= pos {
+			i = k
+		}
+	}
+	return i
+}
+
+// setRunText заменяет содержимое прогона текстом; переводы строк становятся разрывами
+func setRunText(r document.Run, text string) {
+	r.ClearContent()
+	for i, line := range strings.Split(text, "\n") {
+		if i > 0 {
+			r.AddBreak()
+		}
+		if line != "" {
+			r.AddText(line)
+		}
+	}
+}
+
+// mergeValues сопоставляет поля MERGEFIELD значениям тегов: пробелы в имени поля
+// заменяются подчёркиванием, как при импорте шаблона из .docx
+func mergeValues(fields []string, values render.Values) map[string]string {
+	result := make(map[string]string, len(fields))
+	for _, field := range fields {
+		name := strings.Join(strings.Fields(field), "_")
+		value, err := render.ExecuteText("{{"+name+"}}", values)
+		if err != nil {
+			continue
+		}
+		result[field] = value
+	}
+	return result
+}
@@ -0,0 +1,131 @@
+package docx
+
+import (
+	"baliance.com/gooxml/color"
+	"baliance.com/gooxml/measurement"
+	"baliance.com/gooxml/schema/soo/wml"
+
+	"doc-generation/richtext"
+)
+
+var verticalAlignments = map[string]wml.ST_VerticalJc{
+	"top":    wml.ST_VerticalJcTop,
+	"middle": wml.ST_VerticalJcCenter,
+	"center": wml.ST_VerticalJcCenter,
+	"bottom": wml.ST_VerticalJcBottom,
+}
+
+// spanned — ячейка с rowspan, которая ещё занимает колонки в следующих строках
+type spanned struct {
+	rowsLeft int
+	colSpan  int
+	width    measurement.Distance
+}
+
+func (w *writer) writeTable(t *richtext.Table) {
+	table := w.doc.AddTable()
+	props := table.Properties()
+
+	switch {
+	case t.WidthPercent > 0:
+		props.SetWidthPercent(t.WidthPercent)
+	case t.WidthPt > 0:
+		props.SetWidth(pt(t.WidthPt))
+	default:
+		props.SetWidthPercent(100)
+	}
+
+	if t.Border > 0 {
+		c := color.Auto
+		if t.BorderColor != "" {
+			c = color.FromHex(t.BorderColor)
+		}
+		props.Borders().SetAll(wml.ST_BorderSingle, c, pt(t.Border))
+	}
+
+	// pending[col] — ячейки из предыдущих строк, объединённые по вертикали (rowspan)
+	pending := make(map[int]*spanned)
+
+	for _, r := range t.Rows {
+		row := table.AddRow()
+		col := 0
+
+		// продолжения объединённых ячеек, стоящих в колонке col и правее подряд
+		fillSpanned := func() {
+			for {
+				s, ok := pending[col]
+				if !ok {
+					return
+				}
+				cell := row.AddCell()
+				cell.Properties().SetVerticalMerge(wml.ST_MergeContinue)
+				if s.colSpan > 1 {
+					cell.Properties().SetColumnSpan(s.colSpan)
+				}
+				if s.width > 0 {
+					cell.Properties().SetWidth(s.width)
+				}
+				// Word требует хотя бы один абзац в каждой ячейке
+				cell.AddParagraph()
+
+				s.rowsLeft--
+				if s.rowsLeft == 0 {
+					delete(pending, col)
+				}
+				col += s.colSpan
+			}
+		}
+
+		for _, c := range r.Cells {
+			fillSpanned()
+
+			cell := row.AddCell()
+			cp := cell.Properties()
+			if c.ColSpan > 1 {
+				cp.SetColumnSpan(c.ColSpan)
+			}
+			width := w.cellWidth(t, c, col)
+			switch {
+			case c.WidthPercent > 0:
+				cp.SetWidthPercent(c.WidthPercent)
+			case width > 0:
+				cp.SetWidth(width)
+			}
+			if c.RowSpan > 1 {
+				cp.SetVerticalMerge(wml.ST_MergeRestart)
+				pending[col] = &spanned{rowsLeft: c.RowSpan - 1, colSpan: c.ColSpan, width: width}
+			}
+			if c.Background != "" {
+				cp.SetShading(wml.ST_ShdClear, color.Auto, color.FromHex(c.Background))
+			}
+			if va, ok := verticalAlignments[c.VAlign]; ok {
+				cp.SetVerticalAlignment(va)
+			}
+
+			if len(c.Blocks) == 0 {
+				cell.AddParagraph()
+			}
+			for _, b := range c.Blocks {
+				w.writeParagraph(cell.AddParagraph(), b)
+			}
+
+			col += c.ColSpan
+		}
+		fillSpanned()
+	}
+}
+
+// cellWidth возвращает ширину ячейки: явную из стиля или сумму ширин колонок из <col>
+func (w *writer) cellWidth(t *richtext.Table, c richtext.TableCell, col int) measurement.Distance {
+	if c.WidthPt > 0 {
+		return pt(c.WidthPt)
+	}
+	var sum float64
+	for i := col; i < col+c.ColSpan && i < len(t.ColumnWidths); i++ {
+		if t.ColumnWidths[i] == 0 {
+			return 0
+		}
+		sum += t.ColumnWidths[i]
+	}
+	return pt(sum)
+}
@@ -0,0 +1,73 @@
+// Package etag — версии ресурсов для оптимистичной блокировки: ETag в ответах
+// и обязательный If-Match в запросах на изменение.
+package etag
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Any — версия из If-Match: *, изменение без проверки версии
+const Any = -1
+
+// AnyVersion — список версий для изменений без проверки (If-Match: *)
+func AnyVersion() []int {
+	return []int{Any}
+}
+
+// Format возвращает ETag для версии ресурса
+func Format(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// Set добавляет в ответ заголовок ETag
+func Set(c *gin.Context, version int) {
+	c.Header("ETag", Format(version))
+}
+
+// Parse разбирает значение If-Match: * или список тегов через запятую
+// ("3", "4" — подходит любая из версий). If-Match требует строгого сравнения,
+// поэтому слабые ETag (W/"3") делают заголовок некорректным.
+// Чужие теги ("abc") никогда не совпадают с версией и пропускаются.
+func Parse(header string) ([]int, bool) {
+	if strings.TrimSpace(header) == "*" {
+		return AnyVersion(), true
+	}
+
+	versions := []int{}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			return nil, false
+		}
+		if version, err := strconv.Atoi(tag[1 : len(tag)-1]); err == nil && version > 0 {
+			versions = append(versions, version)
+		}
+	}
+	return versions, true
+}
+
+// Matches сообщает, подходит ли версия ресурса под версии из If-Match
+func Matches(versions []int, version int) bool {
+	return slices.Contains(versions, Any) || slices.Contains(versions, version)
+}
+
+// IfMatch возвращает версии из заголовка If-Match. Без заголовка отвечает
+// 428 Precondition Required, при некорректном значении — 400.
+func IfMatch(c *gin.Context) ([]int, bool) {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		c.JSON(http.StatusPreconditionRequired, gin.H{"error": "Укажите версию в заголовке If-Match"})
+		return nil, false
+	}
+	versions, ok := Parse(header)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный заголовок If-Match"})
+		return nil, false
+	}
+	return versions, true
+}
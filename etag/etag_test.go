@@ -0,0 +1,44 @@
+package etag
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []int
+		ok     bool
+	}{
+		{header: "*", want: []int{Any}, ok: true},
+		{header: ` * `, want: []int{Any}, ok: true},
+		{header: `"3"`, want: []int{3}, ok: true},
+		{header: `"3", "4"`, want: []int{3, 4}, ok: true},
+		{header: `"abc", "5"`, want: []int{5}, ok: true},
+		{header: `"0"`, want: []int{}, ok: true},
+		{header: `W/"3"`, ok: false},
+		{header: `"3", W/"4"`, ok: false},
+		{header: `3`, ok: false},
+		{header: `"3`, ok: false},
+		{header: `"3",`, ok: false},
+	}
+	for _, tt := range tests {
+		got, ok := Parse(tt.header)
+		if ok != tt.ok || (ok && !slices.Equal(got, tt.want)) {
+			t.Errorf("Parse(%q) = %v, %v; ожидалось %v, %v", tt.header, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestMatches(t *testing.T) {
+	if !Matches(AnyVersion(), 7) {
+		t.Error("* должен подходить под любую версию")
+	}
+	if !Matches([]int{3, 7}, 7) {
+		t.Error("версия 7 есть в списке")
+	}
+	if Matches([]int{3}, 7) || Matches([]int{}, 7) {
+		t.Error("версии 7 нет в списке")
+	}
+}
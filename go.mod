@@ -4,10 +4,13 @@ go 1.24
 
 require (
 	baliance.com/gooxml v1.0.1
+	github.com/DATA-DOG/go-sqlmock v1.5.2
 	github.com/gin-contrib/cors v1.7.5
 	github.com/gin-gonic/gin v1.10.1
 	github.com/goccy/go-json v0.10.5
 	github.com/golang-jwt/jwt/v5 v5.2.2
+	github.com/gorilla/websocket v1.5.3
+	github.com/jung-kurt/gofpdf v1.16.2
 	github.com/lib/pq v1.10.9
 	golang.org/x/crypto v0.39.0
 	golang.org/x/net v0.38.0
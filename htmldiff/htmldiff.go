@@ -0,0 +1,292 @@
+// Package htmldiff сравнивает две версии HTML-контента по словам.
+// Теги считаются неделимыми токенами: правка внутри текста не разрывает разметку,
+// а изменение оформления (например, <b> вокруг слова) видно как замена тега.
+package htmldiff
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// OpType — вид фрагмента сравнения
+type OpType string
+
+const (
+	Equal  OpType = "equal"
+	Insert OpType = "insert"
+	Delete OpType = "delete"
+)
+
+// Op — непрерывный фрагмент одного вида. HTML — исходный фрагмент с тегами,
+// Text — только текст без тегов (пустой, если изменилась лишь разметка).
+type Op struct {
+	Type OpType `json:"type"`
+	HTML string `json:"html"`
+	Text string `json:"text"`
+}
+
+// Stats — число вставленных и удалённых слов
+type Stats struct {
+	Inserted int `json:"inserted"`
+	Deleted  int `json:"deleted"`
+}
+
+// maxCells ограничивает таблицу LCS; при большем объёме изменённая середина
+// документа считается заменённой целиком
+const maxCells = 8 << 20
+
+// Diff сравнивает старую и новую версии
+func Diff(oldHTML, newHTML string) []Op {
+	a, b := tokenize(oldHTML), tokenize(newHTML)
+
+	// общие начало и конец не участвуют в LCS
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []Op
+	ops = appendOp(ops, Equal, a[:prefix])
+	ops = append(ops, diffMiddle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	ops = appendOp(ops, Equal, a[len(a)-suffix:])
+	return ops
+}
+
+func diffMiddle(a, b []string) []Op {
+	var ops []Op
+	if (len(a)+1)*(len(b)+1) > maxCells {
+		ops = appendOp(ops, Delete, a)
+		return appendOp(ops, Insert, b)
+	}
+
+	// lcs[i][j] — длина общей подпоследовательности a[i:] и b[j:]
+	width := len(b) + 1
+	lcs := make([]int32, (len(a)+1)*width)
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i*width+j] = lcs[(i+1)*width+j+1] + 1
+			} else {
+				lcs[i*width+j] = max(lcs[(i+1)*width+j], lcs[i*width+j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = appendOp(ops, Equal, a[i:i+1])
+			i, j = i+1, j+1
+		case lcs[(i+1)*width+j] >= lcs[i*width+j+1]:
+			ops = appendOp(ops, Delete, a[i:i+1])
+			i++
+		default:
+			ops = appendOp(ops, Insert, b[j:j+1])
+			j++
+		}
+	}
+	ops = appendOp(ops, Delete, a[i:])
+	return appendOp(ops, Insert, b[j:])
+}
+
+// appendOp добавляет токены, склеивая их с предыдущим фрагментом того же вида
+func appendOp(ops []Op, typ OpType, tokens []string) []Op {
+	if len(tokens) == 0 {
+		return ops
+	}
+	fragment := strings.Join(tokens, "")
+	text := textOf(tokens)
+	if n := len(ops); n > 0 && ops[n-1].Type == typ {
+		ops[n-1].HTML += fragment
+		ops[n-1].Text += text
+		return ops
+	}
+	return append(ops, Op{Type: typ, HTML: fragment, Text: text})
+}
+
+// Summary считает вставленные и удалённые слова
+func Summary(ops []Op) Stats {
+	var s Stats
+	for _, op := range ops {
+		words := 0
+		for _, tok := range tokenize(op.HTML) {
+			r, _ := utf8.DecodeRuneInString(tok)
+			if !isTag(tok) && runeClass(r) == wordClass {
+				words++
+			}
+		}
+		switch op.Type {
+		case Insert:
+			s.Inserted += words
+		case Delete:
+			s.Deleted += words
+		}
+	}
+	return s
+}
+
+// Markup собирает новую версию документа, где вставленный текст обёрнут в <ins>,
+// а удалённый — в <del>. Теги удалённых фрагментов отбрасываются, поэтому
+// разметка результата совпадает с разметкой новой версии.
+func Markup(ops []Op) string {
+	var sb strings.Builder
+	for _, op := range ops {
+		if op.Type == Equal {
+			sb.WriteString(op.HTML)
+			continue
+		}
+		wrap := "ins"
+		if op.Type == Delete {
+			wrap = "del"
+		}
+
+		var text strings.Builder
+		flush := func() {
+			if text.Len() > 0 {
+				sb.WriteString("<" + wrap + ">" + text.String() + "</" + wrap + ">")
+				text.Reset()
+			}
+		}
+		for _, tok := range tokenize(op.HTML) {
+			if !isTag(tok) {
+				text.WriteString(tok)
+				continue
+			}
+			flush()
+			if op.Type == Insert {
+				sb.WriteString(tok)
+			}
+		}
+		flush()
+	}
+	return sb.String()
+}
+
+// tokenize делит HTML на теги, слова, пробельные промежутки и отдельные знаки
+func tokenize(s string) []string {
+	var tokens []string
+	for len(s) > 0 {
+		n := tokenLen(s)
+		tokens = append(tokens, s[:n])
+		s = s[n:]
+	}
+	return tokens
+}
+
+func tokenLen(s string) int {
+	switch s[0] {
+	case '<':
+		if end := strings.IndexByte(s, '>'); end > 0 {
+			return end + 1
+		}
+	case '&':
+		if end := strings.IndexByte(s, ';'); end > 1 && end <= 10 && !strings.ContainsAny(s[1:end], " <&") {
+			return end + 1
+		}
+	}
+
+	r, size := utf8.DecodeRuneInString(s)
+	class := runeClass(r)
+	if class == otherClass {
+		return size
+	}
+	n := size
+	for n < len(s) && s[n] != '<' && s[n] != '&' {
+		r, size := utf8.DecodeRuneInString(s[n:])
+		if runeClass(r) != class {
+			break
+		}
+		n += size
+	}
+	return n
+}
+
+const (
+	otherClass = iota
+	spaceClass
+	wordClass
+)
+
+func runeClass(r rune) int {
+	switch {
+	case unicode.IsSpace(r):
+		return spaceClass
+	case unicode.IsLetter(r), unicode.IsDigit(r), r == '_':
+		return wordClass
+	}
+	return otherClass
+}
+
+func isTag(tok string) bool {
+	return strings.HasPrefix(tok, "<") && strings.HasSuffix(tok, ">")
+}
+
+func textOf(tokens []string) string {
+	var sb strings.Builder
+	for _, tok := range tokens {
+		if !isTag(tok) {
+			sb.WriteString(html.UnescapeString(tok))
+		}
+	}
+	return sb.String()
+}
+
+// PatchOp — шаг патча: оставить Keep байт старой версии, пропустить Skip байт
+// или вставить Insert. В каждом шаге заполнено ровно одно поле.
+type PatchOp struct {
+	Keep   int    `json:"k,omitempty"`
+	Skip   int    `json:"d,omitempty"`
+	Insert string `json:"i,omitempty"`
+}
+
+// Patch — компактная разница между версиями, из которой по старой версии
+// восстанавливается новая
+type Patch []PatchOp
+
+// MakePatch строит патч, превращающий oldHTML в newHTML
+func MakePatch(oldHTML, newHTML string) Patch {
+	var patch Patch
+	for _, op := range Diff(oldHTML, newHTML) {
+		switch op.Type {
+		case Equal:
+			patch = append(patch, PatchOp{Keep: len(op.HTML)})
+		case Delete:
+			patch = append(patch, PatchOp{Skip: len(op.HTML)})
+		case Insert:
+			patch = append(patch, PatchOp{Insert: op.HTML})
+		}
+	}
+	return patch
+}
+
+// Apply применяет патч к старой версии
+func (p Patch) Apply(oldHTML string) (string, error) {
+	var sb strings.Builder
+	pos := 0
+	for _, op := range p {
+		switch {
+		case op.Keep > 0:
+			if pos+op.Keep > len(oldHTML) {
+				return "", fmt.Errorf("патч выходит за пределы исходной версии (%d > %d)", pos+op.Keep, len(oldHTML))
+			}
+			sb.WriteString(oldHTML[pos : pos+op.Keep])
+			pos += op.Keep
+		case op.Skip > 0:
+			pos += op.Skip
+		default:
+			sb.WriteString(op.Insert)
+		}
+	}
+	if pos != len(oldHTML) {
+		return "", fmt.Errorf("патч не соответствует исходной версии: обработано %d из %d байт", pos, len(oldHTML))
+	}
+	return sb.String(), nil
+}
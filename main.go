@@ -2,6 +2,7 @@ package main
 
 import (
 	"log"
+	"os"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -10,6 +11,7 @@ import (
 	"doc-generation/auth"
 	"doc-generation/config"
 	"doc-generation/document"
+	"doc-generation/migrations"
 	"doc-generation/templates"
 )
 
@@ -21,11 +23,29 @@ func main() {
 	InitDB()
 	defer DB.Close()
 
+	// Применяем миграции схемы
+	if err := migrations.Apply(DB); err != nil {
+		log.Fatalf("Ошибка применения миграций: %v", err)
+	}
+
 	// Инициализация бизнес-логики с подключением к БД
 	auth.InitAuth(DB)
 	templates.InitTemplates(DB)
 	document.InitDocumentRepo(DB)
 
+	// Служебные команды: go run . compact-revisions
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "compact-revisions":
+			if err := document.CompactAllRevisions(); err != nil {
+				log.Fatalf("Ошибка сжатия истории версий: %v", err)
+			}
+			return
+		default:
+			log.Fatalf("Неизвестная команда: %s", os.Args[1])
+		}
+	}
+
 	// Создаём маршрутизатор Gin
 	r := gin.Default()
 
@@ -34,7 +54,8 @@ func main() {
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{frontendURL},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Authorization", "Content-Type"},
+		AllowHeaders:     []string{"Authorization", "Content-Type", "If-Match"},
+		ExposeHeaders:    []string{"ETag"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
@@ -0,0 +1,2 @@
+-- Значение тега по умолчанию, используется при серверном рендеринге документа
+ALTER TABLE tags ADD COLUMN IF NOT EXISTS default_value TEXT NOT NULL DEFAULT '';
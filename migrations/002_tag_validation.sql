@@ -0,0 +1,3 @@
+-- Правила валидации значений тегов: обязательность и допустимые значения для enum
+ALTER TABLE tags ADD COLUMN IF NOT EXISTS required BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE tags ADD COLUMN IF NOT EXISTS options JSONB NOT NULL DEFAULT '[]'::jsonb;
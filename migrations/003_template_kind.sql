@@ -0,0 +1,4 @@
+-- Вид шаблона: html — контент в редакторе, docx — исходный документ Word,
+-- в который значения подставляются напрямую (source_docx)
+ALTER TABLE templates ADD COLUMN IF NOT EXISTS kind TEXT NOT NULL DEFAULT 'html';
+ALTER TABLE templates ADD COLUMN IF NOT EXISTS source_docx BYTEA;
@@ -0,0 +1,6 @@
+-- Автор, комментарий и размер версии документа; список версий отдаётся постранично без содержимого
+ALTER TABLE document_revisions ADD COLUMN IF NOT EXISTS author_id INTEGER REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE document_revisions ADD COLUMN IF NOT EXISTS message TEXT NOT NULL DEFAULT '';
+ALTER TABLE document_revisions ADD COLUMN IF NOT EXISTS size INTEGER NOT NULL DEFAULT 0;
+UPDATE document_revisions SET size = octet_length(content) WHERE size = 0;
+CREATE INDEX IF NOT EXISTS document_revisions_document_created_idx ON document_revisions (document_id, created_at DESC);
@@ -0,0 +1,6 @@
+-- Версии документа хранятся как полные снимки (content) или как патч (delta)
+-- относительно снимка base_id; содержимое восстанавливается при чтении
+ALTER TABLE document_revisions ALTER COLUMN content DROP NOT NULL;
+ALTER TABLE document_revisions ADD COLUMN IF NOT EXISTS base_id INTEGER REFERENCES document_revisions(id);
+ALTER TABLE document_revisions ADD COLUMN IF NOT EXISTS delta TEXT;
+CREATE INDEX IF NOT EXISTS document_revisions_snapshot_idx ON document_revisions (document_id, id DESC) WHERE base_id IS NULL;
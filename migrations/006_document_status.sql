@@ -0,0 +1,25 @@
+-- Жизненный цикл документа: draft → on_review → approved → signed → archived
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'draft'
+	CHECK (status IN ('draft', 'on_review', 'approved', 'signed', 'archived'));
+
+-- Согласующие документа и их решения в текущем круге согласования
+CREATE TABLE IF NOT EXISTS document_approvers (
+	document_id INTEGER NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+	user_id     INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	decision    TEXT NOT NULL DEFAULT 'pending' CHECK (decision IN ('pending', 'approved', 'rejected')),
+	comment     TEXT NOT NULL DEFAULT '',
+	decided_at  TIMESTAMP,
+	PRIMARY KEY (document_id, user_id)
+);
+
+-- История смены статусов: кто и когда перевёл документ
+CREATE TABLE IF NOT EXISTS document_status_history (
+	id          SERIAL PRIMARY KEY,
+	document_id INTEGER NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+	from_status TEXT NOT NULL,
+	to_status   TEXT NOT NULL,
+	user_id     INTEGER REFERENCES users(id) ON DELETE SET NULL,
+	comment     TEXT NOT NULL DEFAULT '',
+	created_at  TIMESTAMP NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS document_status_history_document_idx ON document_status_history (document_id, created_at);
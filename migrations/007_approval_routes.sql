@@ -0,0 +1,11 @@
+-- Маршрут согласования шаблона: этапы проходятся по порядку,
+-- согласующие одного этапа согласуют параллельно
+CREATE TABLE IF NOT EXISTS template_approval_steps (
+	template_id INTEGER NOT NULL REFERENCES templates(id) ON DELETE CASCADE,
+	step        INTEGER NOT NULL CHECK (step > 0),
+	user_id     INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	PRIMARY KEY (template_id, user_id)
+);
+
+-- Этап согласующего в маршруте документа
+ALTER TABLE document_approvers ADD COLUMN IF NOT EXISTS step INTEGER NOT NULL DEFAULT 1 CHECK (step > 0);
@@ -0,0 +1,30 @@
+-- Комментарии к документу. Корневой комментарий открывает обсуждение и может быть
+-- привязан к версии и фрагменту текста (anchor_start..anchor_end) или к элементу
+-- с data-style-id; ответы ссылаются на корневой комментарий через parent_id.
+CREATE TABLE IF NOT EXISTS document_comments (
+	id              SERIAL PRIMARY KEY,
+	document_id     INTEGER NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+	parent_id       INTEGER REFERENCES document_comments(id) ON DELETE CASCADE,
+	revision_id     INTEGER REFERENCES document_revisions(id) ON DELETE SET NULL,
+	anchor_start    INTEGER,
+	anchor_end      INTEGER,
+	anchor_style_id TEXT,
+	quote           TEXT NOT NULL DEFAULT '',
+	author_id       INTEGER REFERENCES users(id) ON DELETE SET NULL,
+	body            TEXT NOT NULL,
+	status          TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'resolved')),
+	resolved_by     INTEGER REFERENCES users(id) ON DELETE SET NULL,
+	resolved_at     TIMESTAMP,
+	created_at      TIMESTAMP NOT NULL DEFAULT NOW(),
+	CHECK (anchor_start IS NULL OR (anchor_start >= 0 AND anchor_end > anchor_start))
+);
+CREATE INDEX IF NOT EXISTS document_comments_document_idx ON document_comments (document_id, status);
+CREATE INDEX IF NOT EXISTS document_comments_parent_idx ON document_comments (parent_id);
+
+-- Упоминания пользователей в комментариях
+CREATE TABLE IF NOT EXISTS document_comment_mentions (
+	comment_id INTEGER NOT NULL REFERENCES document_comments(id) ON DELETE CASCADE,
+	user_id    INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	PRIMARY KEY (comment_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS document_comment_mentions_user_idx ON document_comment_mentions (user_id);
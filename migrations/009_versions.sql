@@ -0,0 +1,4 @@
+-- Версии для оптимистичной блокировки: увеличиваются при каждом изменении,
+-- клиент передаёт ожидаемую версию в If-Match
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE templates ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,41 @@
+-- Данные организаций разделены: шаблоны, стили, теги и документы принадлежат организации.
+-- Шаблоны и документы получают организацию автора; записи, автор которых не найден
+-- или не состоит в организации, переходят к первой организации.
+ALTER TABLE templates ADD COLUMN IF NOT EXISTS organization_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE;
+UPDATE templates t SET organization_id = u.organization_id
+FROM users u WHERE u.id = t.user_id AND t.organization_id IS NULL;
+UPDATE templates SET organization_id = (SELECT MIN(id) FROM organizations) WHERE organization_id IS NULL;
+ALTER TABLE templates ALTER COLUMN organization_id SET NOT NULL;
+CREATE INDEX IF NOT EXISTS templates_organization_idx ON templates (organization_id);
+
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS organization_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE;
+UPDATE documents d SET organization_id = u.organization_id
+FROM users u WHERE u.id = d.user_id AND d.organization_id IS NULL;
+UPDATE documents d SET organization_id = t.organization_id
+FROM templates t WHERE t.id = d.template_id AND d.organization_id IS NULL;
+UPDATE documents SET organization_id = (SELECT MIN(id) FROM organizations) WHERE organization_id IS NULL;
+ALTER TABLE documents ALTER COLUMN organization_id SET NOT NULL;
+CREATE INDEX IF NOT EXISTS documents_organization_idx ON documents (organization_id);
+
+ALTER TABLE template_styles ADD COLUMN IF NOT EXISTS organization_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE;
+UPDATE template_styles s SET organization_id = t.organization_id
+FROM templates t WHERE t.id = s.template_id AND s.organization_id IS NULL;
+UPDATE template_styles SET organization_id = (SELECT MIN(id) FROM organizations) WHERE organization_id IS NULL;
+ALTER TABLE template_styles ALTER COLUMN organization_id SET NOT NULL;
+
+-- Имя тега уникально в пределах организации. Глобальное ограничение снимается
+-- до копирования, иначе копии тегов с теми же именами его нарушат.
+ALTER TABLE tags DROP CONSTRAINT IF EXISTS tags_name_key;
+
+-- Теги были общими: каждая организация получает свою копию набора тегов,
+-- исходные строки остаются за первой организацией
+ALTER TABLE tags ADD COLUMN IF NOT EXISTS organization_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE;
+INSERT INTO tags (organization_id, name, label, description, type, default_value, required, options, style_id, created_at)
+SELECT o.id, t.name, t.label, t.description, t.type, t.default_value, t.required, t.options, t.style_id, t.created_at
+FROM tags t
+CROSS JOIN organizations o
+WHERE t.organization_id IS NULL
+  AND o.id <> (SELECT MIN(id) FROM organizations);
+UPDATE tags SET organization_id = (SELECT MIN(id) FROM organizations) WHERE organization_id IS NULL;
+ALTER TABLE tags ALTER COLUMN organization_id SET NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS tags_organization_name_idx ON tags (organization_id, name);
@@ -0,0 +1,30 @@
+-- Права ролей задаются в каждой организации отдельно.
+-- Встроенные роли создаются, если их ещё нет.
+INSERT INTO roles (name)
+SELECT r.name FROM (VALUES ('Владелец'), ('Администратор'), ('Менеджер'), ('Сотрудник')) AS r(name)
+WHERE NOT EXISTS (SELECT 1 FROM roles WHERE roles.name = r.name);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+	organization_id INTEGER NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+	role_id         INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+	permission      TEXT    NOT NULL,
+	PRIMARY KEY (organization_id, role_id, permission)
+);
+
+-- Права по умолчанию (повторяют auth.DefaultRolePermissions)
+INSERT INTO role_permissions (organization_id, role_id, permission)
+SELECT o.id, r.id, p.permission
+FROM organizations o
+CROSS JOIN (VALUES
+	('Владелец', 'users.invite'), ('Владелец', 'users.manage'), ('Владелец', 'roles.manage'),
+	('Владелец', 'templates.edit'), ('Владелец', 'tags.edit'),
+	('Владелец', 'documents.read'), ('Владелец', 'documents.edit'),
+	('Владелец', 'documents.approve'), ('Владелец', 'documents.archive'),
+	('Администратор', 'users.invite'), ('Администратор', 'users.manage'), ('Администратор', 'roles.manage'),
+	('Администратор', 'templates.edit'), ('Администратор', 'tags.edit'),
+	('Администратор', 'documents.read'), ('Администратор', 'documents.edit'),
+	('Администратор', 'documents.approve'), ('Администратор', 'documents.archive'),
+	('Менеджер', 'users.invite'), ('Менеджер', 'documents.archive')
+) AS p(role, permission)
+JOIN roles r ON r.name = p.role
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,43 @@
+-- Роли принадлежат организации: переименование или удаление роли
+-- не затрагивает другие организации.
+ALTER TABLE roles ADD COLUMN IF NOT EXISTS organization_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE;
+ALTER TABLE roles ADD COLUMN IF NOT EXISTS builtin BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE roles SET builtin = TRUE
+WHERE organization_id IS NULL AND name IN ('Владелец', 'Администратор', 'Менеджер', 'Сотрудник');
+
+-- Имя роли уникально в пределах организации; старое ограничение на name
+-- запретило бы копии ролей ниже
+ALTER TABLE roles DROP CONSTRAINT IF EXISTS roles_name_key;
+
+-- Каждая организация получает копии общих ролей, пользователи и права переносятся на них
+INSERT INTO roles (organization_id, name, builtin)
+SELECT o.id, r.name, r.builtin
+FROM roles r CROSS JOIN organizations o
+WHERE r.organization_id IS NULL;
+
+UPDATE users u SET role_id = nr.id
+FROM roles old, roles nr
+WHERE old.id = u.role_id AND old.organization_id IS NULL
+  AND nr.organization_id = u.organization_id AND nr.name = old.name;
+
+UPDATE role_permissions rp SET role_id = nr.id
+FROM roles old, roles nr
+WHERE old.id = rp.role_id AND old.organization_id IS NULL
+  AND nr.organization_id = rp.organization_id AND nr.name = old.name;
+
+DELETE FROM roles r
+WHERE r.organization_id IS NULL AND NOT EXISTS (SELECT 1 FROM users u WHERE u.role_id = r.id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS roles_organization_name_idx ON roles (organization_id, name);
+
+-- Журнал изменений ролей
+CREATE TABLE IF NOT EXISTS role_changes (
+	id              SERIAL PRIMARY KEY,
+	organization_id INTEGER NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+	user_id         INTEGER REFERENCES users(id) ON DELETE SET NULL,
+	action          TEXT NOT NULL,
+	role_name       TEXT NOT NULL,
+	details         TEXT NOT NULL DEFAULT '',
+	created_at      TIMESTAMP NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS role_changes_organization_idx ON role_changes (organization_id, created_at);
@@ -0,0 +1,10 @@
+-- Токены сброса пароля: хранится только SHA-256 токена, токен одноразовый
+CREATE TABLE IF NOT EXISTS password_resets (
+	id         SERIAL PRIMARY KEY,
+	user_id    INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	token_hash TEXT NOT NULL UNIQUE,
+	expires_at TIMESTAMP NOT NULL,
+	used_at    TIMESTAMP,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS password_resets_user_idx ON password_resets (user_id);
@@ -0,0 +1,16 @@
+-- Refresh-токены: хранится только SHA-256 токена. Токен одноразовый —
+-- при обновлении выдаётся следующий той же цепочки (family_id).
+-- Повторное предъявление уже использованного токена отзывает всю цепочку.
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+	id         SERIAL PRIMARY KEY,
+	user_id    INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	family_id  TEXT NOT NULL,
+	token_hash TEXT NOT NULL UNIQUE,
+	session_id INTEGER REFERENCES sessions(id) ON DELETE SET NULL,
+	expires_at TIMESTAMP NOT NULL,
+	used_at    TIMESTAMP,
+	revoked    BOOLEAN NOT NULL DEFAULT FALSE,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS refresh_tokens_family_idx ON refresh_tokens (family_id);
+CREATE INDEX IF NOT EXISTS refresh_tokens_user_idx ON refresh_tokens (user_id);
@@ -0,0 +1,68 @@
+package migrations
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"sort"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// Apply применяет все ещё не выполненные SQL-миграции в порядке имён файлов
+func Apply(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    TEXT PRIMARY KEY,
+			applied_at TIMESTAMP NOT NULL DEFAULT NOW()
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("не удалось создать таблицу schema_migrations: %w", err)
+	}
+
+	names, err := fs.Glob(files, "*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var exists bool
+		err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, name).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("ошибка проверки миграции %s: %w", name, err)
+		}
+		if exists {
+			continue
+		}
+
+		body, err := files.ReadFile(name)
+		if err != nil {
+			return err
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(string(body)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("ошибка выполнения миграции %s: %w", name, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES ($1)`, name); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("ошибка записи миграции %s: %w", name, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+
+		log.Printf("🗄️ Применена миграция %s", name)
+	}
+
+	return nil
+}
@@ -0,0 +1,398 @@
+package odt
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"doc-generation/richtext"
+)
+
+// textWidth — ширина области текста страницы A4 с полями 2 см, пт
+const textWidth = 481.89
+
+// bullets — маркеры для уровней маркированного списка (как в DOCX)
+var bullets = []string{"•", "◦", "▪"}
+
+// alignments — значение fo:text-align по text-align
+var alignments = map[string]string{
+	"left":    "start",
+	"center":  "center",
+	"right":   "end",
+	"justify": "justify",
+}
+
+// builder собирает content.xml: тело документа и автоматические стили,
+// одинаковые наборы свойств получают один стиль
+type builder struct {
+	body   strings.Builder
+	styles strings.Builder
+	names  map[string]string // ключ набора свойств → имя стиля
+	counts map[string]int    // префикс имени → число стилей
+
+	lists []openList // открытые списки по уровням вложенности
+}
+
+type openList struct {
+	id       int
+	itemOpen bool
+}
+
+func buildContent(d *richtext.Document) string {
+	b := &builder{names: make(map[string]string), counts: make(map[string]int)}
+	for _, block := range d.Blocks {
+		if block.Table != nil {
+			b.closeLists(0)
+			b.writeTable(block.Table)
+			continue
+		}
+		b.writeBlock(block)
+	}
+	b.closeLists(0)
+
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:style="urn:oasis:names:tc:opendocument:xmlns:style:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0" xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0" xmlns:fo="urn:oasis:names:tc:opendocument:xmlns:xsl-fo-compatible:1.0" office:version="1.2">
+ <office:automatic-styles>
+` + b.styles.String() + ` </office:automatic-styles>
+ <office:body>
+  <office:text>
+` + b.body.String() + `  </office:text>
+ </office:body>
+</office:document-content>
+`
+}
+
+// style возвращает имя автоматического стиля для набора свойств, создавая его при первом обращении
+func (b *builder) style(prefix, family, parent, props string) string {
+	key := prefix + "|" + parent + "|" + props
+	if name, ok := b.names[key]; ok {
+		return name
+	}
+	b.counts[prefix]++
+	name := fmt.Sprintf("%s%d", prefix, b.counts[prefix])
+	b.names[key] = name
+
+	parentAttr := ""
+	if parent != "" {
+		parentAttr = fmt.Sprintf(` style:parent-style-name="%s"`, parent)
+	}
+	fmt.Fprintf(&b.styles, "  <style:style style:name=\"%s\" style:family=\"%s\"%s>%s</style:style>\n", name, family, parentAttr, props)
+	return name
+}
+
+// writeBlock выводит абзац; пункты списков собираются во вложенные text:list
+func (b *builder) writeBlock(block richtext.Block) {
+	if block.List == nil {
+		b.closeLists(0)
+	} else {
+		b.enterList(block.List)
+	}
+	b.writeParagraph(&b.body, block)
+}
+
+func (b *builder) enterList(list *richtext.ListInfo) {
+	level := list.Level
+	b.closeLists(level + 1)
+
+	if len(b.lists) == level+1 && b.lists[level].id != list.ID {
+		b.closeLists(level)
+	}
+	if len(b.lists) == level+1 {
+		// следующий пункт того же списка
+		b.body.WriteString("</text:list-item><text:list-item>")
+		return
+	}
+
+	// недостающие уровни (вложенный список без родительского пункта) открываются пустыми
+	for len(b.lists) <= level {
+		name := b.listStyle(list)
+		if n := len(b.lists); n > 0 && !b.lists[n-1].itemOpen {
+			b.body.WriteString("<text:list-item>")
+			b.lists[n-1].itemOpen = true
+		}
+		fmt.Fprintf(&b.body, `<text:list text:style-name="%s"><text:list-item>`, name)
+		b.lists = append(b.lists, openList{id: list.ID, itemOpen: true})
+	}
+}
+
+// closeLists закрывает списки глубже уровня level (0 — все)
+func (b *builder) closeLists(level int) {
+	for len(b.lists) > level {
+		top := b.lists[len(b.lists)-1]
+		if top.itemOpen {
+			b.body.WriteString("</text:list-item>")
+		}
+		b.body.WriteString("</text:list>\n")
+		b.lists = b.lists[:len(b.lists)-1]
+	}
+}
+
+// listStyle описывает все 9 уровней: нумерация или маркеры, отступ 0.5in на уровень
+func (b *builder) listStyle(list *richtext.ListInfo) string {
+	key := "L|" + strconv.FormatBool(list.Ordered)
+	if name, ok := b.names[key]; ok {
+		return name
+	}
+
+	var levels strings.Builder
+	for lvl := 1; lvl <= 9; lvl++ {
+		props := fmt.Sprintf(`<style:list-level-properties text:list-level-position-and-space-mode="label-alignment">`+
+			`<style:list-level-label-alignment text:label-followed-by="listtab" text:list-tab-stop-position="%.2fin" fo:text-indent="-0.25in" fo:margin-left="%.2fin"/>`+
+			`</style:list-level-properties>`, float64(lvl)*0.5, float64(lvl)*0.5)
+		if list.Ordered {
+			fmt.Fprintf(&levels, `<text:list-level-style-number text:level="%d" style:num-suffix="." style:num-format="1">%s</text:list-level-style-number>`, lvl, props)
+		} else {
+			fmt.Fprintf(&levels, `<text:list-level-style-bullet text:level="%d" text:bullet-char="%s">%s</text:list-level-style-bullet>`, lvl, bullets[(lvl-1)%len(bullets)], props)
+		}
+	}
+
+	b.counts["L"]++
+	name := fmt.Sprintf("L%d", b.counts["L"])
+	b.names[key] = name
+	fmt.Fprintf(&b.styles, "  <text:list-style style:name=\"%s\">%s</text:list-style>\n", name, levels.String())
+	return name
+}
+
+func (b *builder) writeParagraph(out *strings.Builder, block richtext.Block) {
+	parent := "Standard"
+	tag := "text:p"
+	outline := ""
+	if block.Heading > 0 {
+		parent = fmt.Sprintf("Heading_20_%d", block.Heading)
+		tag = "text:h"
+		outline = fmt.Sprintf(` text:outline-level="%d"`, block.Heading)
+	}
+
+	name := parent
+	if props := paragraphProps(block.Format); props != "" {
+		name = b.style("P", "paragraph", parent, props)
+	}
+	fmt.Fprintf(out, `<%s text:style-name="%s"%s>`, tag, name, outline)
+
+	for _, r := range block.Runs {
+		if r.Break {
+			out.WriteString("<text:line-break/>")
+			continue
+		}
+		text := escapeText(r.Text)
+		if props := textProps(r.Format); props != "" {
+			fmt.Fprintf(out, `<text:span text:style-name="%s">%s</text:span>`, b.style("T", "text", "", props), text)
+		} else {
+			out.WriteString(text)
+		}
+	}
+	fmt.Fprintf(out, "</%s>\n", tag)
+}
+
+func paragraphProps(f richtext.ParagraphFormat) string {
+	var attrs []string
+	if v, ok := alignments[f.Align]; ok {
+		attrs = append(attrs, fmt.Sprintf(`fo:text-align="%s"`, v))
+	}
+	if f.FirstIndent != 0 {
+		attrs = append(attrs, fmt.Sprintf(`fo:text-indent="%s"`, pt(f.FirstIndent)))
+	}
+	if f.IndentLeft > 0 {
+		attrs = append(attrs, fmt.Sprintf(`fo:margin-left="%s"`, pt(f.IndentLeft)))
+	}
+	if f.IndentRight > 0 {
+		attrs = append(attrs, fmt.Sprintf(`fo:margin-right="%s"`, pt(f.IndentRight)))
+	}
+	if f.SpaceBefore > 0 {
+		attrs = append(attrs, fmt.Sprintf(`fo:margin-top="%s"`, pt(f.SpaceBefore)))
+	}
+	if f.SpaceAfter > 0 {
+		attrs = append(attrs, fmt.Sprintf(`fo:margin-bottom="%s"`, pt(f.SpaceAfter)))
+	}
+	switch {
+	case f.LineHeightPt > 0:
+		attrs = append(attrs, fmt.Sprintf(`fo:line-height="%s"`, pt(f.LineHeightPt)))
+	case f.LineHeight > 0:
+		attrs = append(attrs, fmt.Sprintf(`fo:line-height="%s%%"`, strconv.FormatFloat(f.LineHeight*100, 'f', -1, 64)))
+	}
+	if len(attrs) == 0 {
+		return ""
+	}
+	return "<style:paragraph-properties " + strings.Join(attrs, " ") + "/>"
+}
+
+func textProps(f richtext.RunFormat) string {
+	var attrs []string
+	if f.Bold {
+		attrs = append(attrs, `fo:font-weight="bold"`)
+	}
+	if f.Italic {
+		attrs = append(attrs, `fo:font-style="italic"`)
+	}
+	if f.Underline {
+		attrs = append(attrs, `style:text-underline-style="solid" style:text-underline-width="auto" style:text-underline-color="font-color"`)
+	}
+	if f.Strike {
+		attrs = append(attrs, `style:text-line-through-style="solid"`)
+	}
+	if f.FontFamily != "" {
+		attrs = append(attrs, fmt.Sprintf(`fo:font-family="%s"`, escapeAttr(f.FontFamily)))
+	}
+	if f.FontSizePt > 0 {
+		attrs = append(attrs, fmt.Sprintf(`fo:font-size="%s"`, pt(f.FontSizePt)))
+	}
+	if f.Color != "" {
+		attrs = append(attrs, fmt.Sprintf(`fo:color="#%s"`, f.Color))
+	}
+	if f.Background != "" {
+		attrs = append(attrs, fmt.Sprintf(`fo:background-color="#%s"`, f.Background))
+	}
+	if f.LetterSpacing != 0 {
+		attrs = append(attrs, fmt.Sprintf(`fo:letter-spacing="%s"`, pt(f.LetterSpacing)))
+	}
+	if len(attrs) == 0 {
+		return ""
+	}
+	sort.Strings(attrs)
+	return "<style:text-properties " + strings.Join(attrs, " ") + "/>"
+}
+
+func (b *builder) writeTable(t *richtext.Table) {
+	cells, cols := t.Grid()
+	if cols == 0 {
+		return
+	}
+
+	width := textWidth
+	switch {
+	case t.WidthPercent > 0:
+		width = textWidth * t.WidthPercent / 100
+	case t.WidthPt > 0 && t.WidthPt < textWidth:
+		width = t.WidthPt
+	}
+	tableStyle := b.style("Tbl", "table", "", fmt.Sprintf(`<style:table-properties style:width="%s" table:align="left"/>`, pt(width)))
+	fmt.Fprintf(&b.body, "<table:table table:style-name=\"%s\">\n", tableStyle)
+
+	for _, w := range t.ResolveColumnWidths(width) {
+		col := b.style("Col", "table-column", "", fmt.Sprintf(`<style:table-column-properties style:column-width="%s"/>`, pt(w)))
+		fmt.Fprintf(&b.body, "<table:table-column table:style-name=\"%s\"/>\n", col)
+	}
+
+	start := make(map[[2]int]*richtext.TableCell)
+	covered := make(map[[2]int]bool)
+	for _, pc := range cells {
+		start[[2]int{pc.Row, pc.Col}] = pc.Cell
+		for dr := 0; dr < pc.Cell.RowSpan; dr++ {
+			for dc := 0; dc < pc.Cell.ColSpan; dc++ {
+				if dr > 0 || dc > 0 {
+					covered[[2]int{pc.Row + dr, pc.Col + dc}] = true
+				}
+			}
+		}
+	}
+
+	border := "none"
+	if t.Border > 0 {
+		c := "000000"
+		if t.BorderColor != "" {
+			c = t.BorderColor
+		}
+		border = fmt.Sprintf("%s solid #%s", pt(t.Border), c)
+	}
+
+	for r := range t.Rows {
+		b.body.WriteString("<table:table-row>")
+		for col := 0; col < cols; col++ {
+			pos := [2]int{r, col}
+			c, ok := start[pos]
+			switch {
+			case ok:
+				b.writeCell(c, border)
+			case covered[pos]:
+				b.body.WriteString("<table:covered-table-cell/>")
+			default:
+				// строка короче остальных — дополняем пустой ячейкой
+				fmt.Fprintf(&b.body, `<table:table-cell table:style-name="%s" office:value-type="string"><text:p/></table:table-cell>`, b.cellStyle(richtext.TableCell{}, border))
+			}
+		}
+		b.body.WriteString("</table:table-row>\n")
+	}
+	b.body.WriteString("</table:table>\n")
+}
+
+func (b *builder) writeCell(c *richtext.TableCell, border string) {
+	var attrs string
+	if c.ColSpan > 1 {
+		attrs += fmt.Sprintf(` table:number-columns-spanned="%d"`, c.ColSpan)
+	}
+	if c.RowSpan > 1 {
+		attrs += fmt.Sprintf(` table:number-rows-spanned="%d"`, c.RowSpan)
+	}
+	fmt.Fprintf(&b.body, `<table:table-cell table:style-name="%s" office:value-type="string"%s>`, b.cellStyle(*c, border), attrs)
+
+	var content strings.Builder
+	for _, block := range c.Blocks {
+		b.writeParagraph(&content, block)
+	}
+	if len(c.Blocks) == 0 {
+		content.WriteString("<text:p/>")
+	}
+	b.body.WriteString(content.String())
+	b.body.WriteString("</table:table-cell>")
+}
+
+func (b *builder) cellStyle(c richtext.TableCell, border string) string {
+	attrs := []string{fmt.Sprintf(`fo:border="%s"`, border), `fo:padding="4pt"`}
+	if c.Background != "" {
+		attrs = append(attrs, fmt.Sprintf(`fo:background-color="#%s"`, c.Background))
+	}
+	switch c.VAlign {
+	case "top", "bottom":
+		attrs = append(attrs, fmt.Sprintf(`style:vertical-align="%s"`, c.VAlign))
+	case "middle", "center":
+		attrs = append(attrs, `style:vertical-align="middle"`)
+	}
+	return b.style("Cell", "table-cell", "", "<style:table-cell-properties "+strings.Join(attrs, " ")+"/>")
+}
+
+func pt(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64) + "pt"
+}
+
+var xmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+var attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+// escapeText экранирует текст; ведущие и повторные пробелы ODF схлопывает, поэтому они
+// записываются через text:s
+func escapeText(s string) string {
+	s = xmlEscaper.Replace(s)
+	if !strings.Contains(s, "  ") && !strings.HasPrefix(s, " ") {
+		return s
+	}
+	var sb strings.Builder
+	spaces := 0
+	flush := func() {
+		if spaces == 0 {
+			return
+		}
+		if sb.Len() > 0 {
+			sb.WriteString(" ")
+			spaces--
+		}
+		if spaces > 0 {
+			fmt.Fprintf(&sb, `<text:s text:c="%d"/>`, spaces)
+		}
+		spaces = 0
+	}
+	for _, ch := range s {
+		if ch == ' ' {
+			spaces++
+			continue
+		}
+		flush()
+		sb.WriteRune(ch)
+	}
+	flush()
+	return sb.String()
+}
+
+func escapeAttr(s string) string {
+	return attrEscaper.Replace(s)
+}
@@ -0,0 +1,118 @@
+// Package odt переводит разобранный HTML (richtext) в документ OpenDocument (.odt)
+// без внешних утилит. Оформление берётся из той же модели, что и для DOCX и PDF.
+package odt
+
+import (
+	"archive/zip"
+	"io"
+
+	"doc-generation/richtext"
+)
+
+// MimeType — MIME-тип документа OpenDocument Text
+const MimeType = "application/vnd.oasis.opendocument.text"
+
+// Convert разбирает HTML и записывает ODT в w. rules — стили шаблона из template_styles.
+func Convert(w io.Writer, htmlStr string, rules []richtext.StyleRule) error {
+	parsed, err := richtext.Parse(htmlStr, rules)
+	if err != nil {
+		return err
+	}
+	return Write(w, parsed)
+}
+
+// Write записывает разобранный документ в w в формате ODT
+func Write(w io.Writer, parsed *richtext.Document) error {
+	content := buildContent(parsed)
+
+	zw := zip.NewWriter(w)
+
+	// mimetype должен быть первым файлом архива и храниться без сжатия
+	mt, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(mt, MimeType); err != nil {
+		return err
+	}
+
+	files := []struct {
+		name string
+		data string
+	}{
+		{"content.xml", content},
+		{"styles.xml", stylesXML},
+		{"meta.xml", metaXML},
+		{"META-INF/manifest.xml", manifestXML},
+	}
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, f.data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+const manifestXML = `<?xml version="1.0" encoding="UTF-8"?>
+<manifest:manifest xmlns:manifest="urn:oasis:names:tc:opendocument:xmlns:manifest:1.0" manifest:version="1.2">
+ <manifest:file-entry manifest:full-path="/" manifest:version="1.2" manifest:media-type="application/vnd.oasis.opendocument.text"/>
+ <manifest:file-entry manifest:full-path="content.xml" manifest:media-type="text/xml"/>
+ <manifest:file-entry manifest:full-path="styles.xml" manifest:media-type="text/xml"/>
+ <manifest:file-entry manifest:full-path="meta.xml" manifest:media-type="text/xml"/>
+</manifest:manifest>
+`
+
+const metaXML = `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-meta xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:meta="urn:oasis:names:tc:opendocument:xmlns:meta:1.0" office:version="1.2">
+ <office:meta>
+  <meta:generator>doc-generation</meta:generator>
+ </office:meta>
+</office:document-meta>
+`
+
+// stylesXML — общие стили: шрифт по умолчанию, заголовки и страница A4 с полями 2 см
+const stylesXML = `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-styles xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:style="urn:oasis:names:tc:opendocument:xmlns:style:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0" xmlns:fo="urn:oasis:names:tc:opendocument:xmlns:xsl-fo-compatible:1.0" office:version="1.2">
+ <office:styles>
+  <style:default-style style:family="paragraph">
+   <style:paragraph-properties fo:orphans="2" fo:widows="2"/>
+   <style:text-properties fo:font-size="12pt" fo:language="ru" fo:country="RU"/>
+  </style:default-style>
+  <style:style style:name="Standard" style:family="paragraph" style:class="text"/>
+  <style:style style:name="Heading" style:family="paragraph" style:parent-style-name="Standard" style:class="text">
+   <style:paragraph-properties fo:margin-top="12pt" fo:margin-bottom="6pt" fo:keep-with-next="always"/>
+   <style:text-properties fo:font-weight="bold"/>
+  </style:style>
+  <style:style style:name="Heading_20_1" style:display-name="Heading 1" style:family="paragraph" style:parent-style-name="Heading" style:default-outline-level="1" style:class="text">
+   <style:text-properties fo:font-size="24pt"/>
+  </style:style>
+  <style:style style:name="Heading_20_2" style:display-name="Heading 2" style:family="paragraph" style:parent-style-name="Heading" style:default-outline-level="2" style:class="text">
+   <style:text-properties fo:font-size="18pt"/>
+  </style:style>
+  <style:style style:name="Heading_20_3" style:display-name="Heading 3" style:family="paragraph" style:parent-style-name="Heading" style:default-outline-level="3" style:class="text">
+   <style:text-properties fo:font-size="14pt"/>
+  </style:style>
+  <style:style style:name="Heading_20_4" style:display-name="Heading 4" style:family="paragraph" style:parent-style-name="Heading" style:default-outline-level="4" style:class="text">
+   <style:text-properties fo:font-size="12pt"/>
+  </style:style>
+  <style:style style:name="Heading_20_5" style:display-name="Heading 5" style:family="paragraph" style:parent-style-name="Heading" style:default-outline-level="5" style:class="text">
+   <style:text-properties fo:font-size="10pt"/>
+  </style:style>
+  <style:style style:name="Heading_20_6" style:display-name="Heading 6" style:family="paragraph" style:parent-style-name="Heading" style:default-outline-level="6" style:class="text">
+   <style:text-properties fo:font-size="8pt"/>
+  </style:style>
+ </office:styles>
+ <office:automatic-styles>
+  <style:page-layout style:name="pm1">
+   <style:page-layout-properties fo:page-width="21cm" fo:page-height="29.7cm" style:print-orientation="portrait" fo:margin-top="2cm" fo:margin-bottom="2cm" fo:margin-left="2cm" fo:margin-right="2cm"/>
+  </style:page-layout>
+ </office:automatic-styles>
+ <office:master-styles>
+  <style:master-page style:name="Standard" style:page-layout-name="pm1"/>
+ </office:master-styles>
+</office:document-styles>
+`
@@ -0,0 +1,131 @@
+package odt
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "перезаписать golden-файлы в testdata")
+
+// inputDir — HTML-входы общие с тестами richtext
+const inputDir = "../richtext/testdata"
+
+// contentXML конвертирует HTML в ODT и возвращает content.xml, проверяя,
+// что архив начинается с несжатого mimetype, а content.xml — корректный XML
+func contentXML(t *testing.T, htmlStr string) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := Convert(&buf, htmlStr, nil); err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := zr.File[0]; f.Name != "mimetype" || f.Method != zip.Store {
+		t.Errorf("первый файл архива %s (метод %d), ожидался несжатый mimetype", f.Name, f.Method)
+	}
+
+	f, err := zr.Open("content.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		if _, err := dec.Token(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("content.xml не является корректным XML: %v\n%s", err, data)
+		}
+	}
+	return string(data)
+}
+
+// TestWriteGolden сравнивает content.xml для каждого HTML из richtext/testdata
+// с testdata/*.xml. Обновить эталоны: go test ./odt -update
+func TestWriteGolden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join(inputDir, "*.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) == 0 {
+		t.Fatalf("нет входных файлов в %s", inputDir)
+	}
+
+	for _, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), ".html")
+		t.Run(name, func(t *testing.T) {
+			src, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := contentXML(t, string(src))
+
+			golden := filepath.Join("testdata", name+".xml")
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (создать эталон: go test ./odt -update)", err)
+			}
+			if got != string(want) {
+				t.Errorf("content.xml для %s отличается от %s:\n%s", input, golden, got)
+			}
+		})
+	}
+}
+
+// TestContentEscaping проверяет экранирование текста и атрибутов и запись пробелов через text:s
+func TestContentEscaping(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{name: "спецсимволы", html: `<p>a &lt; b &amp;&amp; c &gt; "d"</p>`, want: `a &lt; b &amp;&amp; c &gt; "d"`},
+		{name: "теги в тексте", html: `<p>&lt;/text:p&gt;&lt;script&gt;</p>`, want: `&lt;/text:p&gt;&lt;script&gt;`},
+		{name: "ведущий пробел прогона", html: `<p><b>a</b> b</p>`, want: `</text:span><text:s text:c="1"/>b`},
+		{name: "шрифт с кавычками", html: `<p><span style="font-family: 'A&quot;B &amp; C'">x</span></p>`, want: `fo:font-family="A&quot;B &amp; C"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := contentXML(t, tt.html)
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("в content.xml нет %q:\n%s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestEscapeText(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"a < b & c", "a &lt; b &amp; c"},
+		{"a   b", `a <text:s text:c="2"/>b`},
+		{"  a", `<text:s text:c="2"/>a`},
+		{"a  ", `a <text:s text:c="1"/>`},
+	}
+	for _, tt := range tests {
+		if got := escapeText(tt.in); got != tt.want {
+			t.Errorf("escapeText(%q) = %q, ожидалось %q", tt.in, got, tt.want)
+		}
+	}
+}
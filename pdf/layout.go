@@ -0,0 +1,332 @@
+package pdf
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jung-kurt/gofpdf"
+
+	"doc-generation/richtext"
+)
+
+// headingSizes — размеры заголовков <h1>–<h6> относительно основного шрифта (как в браузере)
+var headingSizes = map[int]float64{1: 2, 2: 1.5, 3: 1.17, 4: 1, 5: 0.83, 6: 0.67}
+
+// bullets — маркеры для уровней маркированного списка (как в DOCX)
+var bullets = []string{"•", "◦", "▪"}
+
+const (
+	listIndent  = 36 // отступ уровня списка, пт
+	listHanging = 18 // выступ маркера, пт
+)
+
+type writer struct {
+	pdf   *gofpdf.Fpdf
+	fonts *fontSet
+	opts  Options
+
+	left, right float64 // границы области текста по горизонтали
+	top, bottom float64 // границы области текста по вертикали
+	y           float64 // текущая позиция
+
+	counters map[int][]int // ListInfo.ID → счётчики пунктов по уровням
+}
+
+// fragment — слово (с завершающими пробелами) одного формата
+type fragment struct {
+	text   string
+	format richtext.RunFormat
+	family string
+	style  string
+	size   float64
+	width  float64 // ширина с завершающими пробелами
+	trail  float64 // ширина завершающих пробелов
+}
+
+type line struct {
+	frags []fragment
+	width float64 // ширина с завершающими пробелами последнего слова
+	size  float64 // наибольший размер шрифта в строке
+	last  bool    // последняя строка абзаца или перед <br> — не растягивается при justify
+}
+
+// paragraph — абзац, разбитый на строки под заданную ширину
+type paragraph struct {
+	block  richtext.Block
+	lines  []line
+	x      float64 // левая граница текста
+	width  float64
+	prefix string // маркер или номер элемента списка
+}
+
+func (w *writer) newPage() {
+	w.pdf.AddPage()
+	w.y = w.top
+}
+
+func (w *writer) writeParagraph(b richtext.Block) {
+	p := w.prepare(b, w.left, w.right-w.left)
+
+	w.y += b.Format.SpaceBefore
+	for i, l := range p.lines {
+		h := w.lineHeight(l, b.Format)
+		if w.y+h > w.bottom && w.y > w.top {
+			w.newPage()
+		}
+		w.drawLine(p, i, w.y)
+		w.y += h
+	}
+	w.y += b.Format.SpaceAfter
+}
+
+// prepare раскладывает абзац по строкам внутри колонки [x, x+width]
+func (w *writer) prepare(b richtext.Block, x, width float64) *paragraph {
+	p := &paragraph{block: b, x: x + b.Format.IndentLeft, width: width - b.Format.IndentLeft - b.Format.IndentRight}
+	if b.List != nil {
+		indent := float64(b.List.Level+1) * listIndent
+		p.x += indent
+		p.width -= indent
+		p.prefix = w.listPrefix(b.List)
+	}
+	p.lines = w.layout(b, p.width)
+	return p
+}
+
+// height возвращает высоту абзаца вместе с отступами до и после
+func (w *writer) height(p *paragraph) float64 {
+	h := p.block.Format.SpaceBefore + p.block.Format.SpaceAfter
+	for _, l := range p.lines {
+		h += w.lineHeight(l, p.block.Format)
+	}
+	return h
+}
+
+func (w *writer) listPrefix(list *richtext.ListInfo) string {
+	counters := w.counters[list.ID]
+	for len(counters) <= list.Level {
+		counters = append(counters, 0)
+	}
+	counters[list.Level]++
+	// пункты вложенного уровня нумеруются заново после пункта верхнего уровня
+	w.counters[list.ID] = counters[:list.Level+1]
+
+	if list.Ordered {
+		return fmt.Sprintf("%d.", counters[list.Level])
+	}
+	return bullets[list.Level%len(bullets)]
+}
+
+// font подбирает шрифт фрагмента: семейство, начертание и размер
+func (w *writer) font(f richtext.RunFormat, heading int) (family, style string, size float64) {
+	size = w.opts.FontSize
+	if k, ok := headingSizes[heading]; ok {
+		size *= k
+	}
+	if f.FontSizePt > 0 {
+		size = f.FontSizePt
+	}
+	if f.Bold || heading > 0 {
+		style += "B"
+	}
+	if f.Italic {
+		style += "I"
+	}
+	return w.fonts.family(f.FontFamily), style, size
+}
+
+// layout разбивает абзац на строки по словам; слова длиннее строки режутся по символам
+func (w *writer) layout(b richtext.Block, width float64) []line {
+	var lines []line
+	cur := line{}
+	avail := width - b.Format.FirstIndent
+
+	flush := func(last bool) {
+		if cur.size == 0 {
+			_, _, cur.size = w.font(richtext.RunFormat{}, b.Heading)
+		}
+		cur.last = last
+		lines = append(lines, cur)
+		cur = line{}
+		avail = width
+	}
+	add := func(frag fragment) {
+		cur.frags = append(cur.frags, frag)
+		cur.width += frag.width
+		if frag.size > cur.size {
+			cur.size = frag.size
+		}
+	}
+
+	for _, r := range b.Runs {
+		if r.Break {
+			flush(true)
+			continue
+		}
+
+		family, style, size := w.font(r.Format, b.Heading)
+		w.pdf.SetFont(family, style, size)
+		measure := func(s string) float64 {
+			return w.pdf.GetStringWidth(s) + r.Format.LetterSpacing*float64(utf8.RuneCountInString(s))
+		}
+
+		for _, word := range strings.SplitAfter(r.Text, " ") {
+			if len(cur.frags) == 0 {
+				word = strings.TrimLeft(word, " ")
+			}
+			if word == "" {
+				continue
+			}
+
+			trimmed := strings.TrimRight(word, " ")
+			if len(cur.frags) > 0 && cur.width+measure(trimmed) > avail {
+				flush(false)
+				if word = strings.TrimLeft(word, " "); word == "" {
+					continue
+				}
+				trimmed = strings.TrimRight(word, " ")
+			}
+
+			// слово не помещается даже в пустую строку
+			for measure(trimmed) > avail && utf8.RuneCountInString(trimmed) > 1 {
+				runes := []rune(trimmed)
+				n := 1
+				for n < len(runes)-1 && measure(string(runes[:n+1])) <= avail {
+					n++
+				}
+				head := string(runes[:n])
+				add(fragment{text: head, format: r.Format, family: family, style: style, size: size, width: measure(head)})
+				flush(false)
+				word = string(runes[n:]) + word[len(trimmed):]
+				trimmed = string(runes[n:])
+			}
+
+			wordW := measure(word)
+			add(fragment{
+				text: word, format: r.Format, family: family, style: style, size: size,
+				width: wordW, trail: wordW - measure(trimmed),
+			})
+		}
+	}
+	flush(true)
+	return lines
+}
+
+// lineHeight: line-height в пунктах, множитель от одинарного интервала или одинарный (1.2 размера шрифта)
+func (w *writer) lineHeight(l line, f richtext.ParagraphFormat) float64 {
+	switch {
+	case f.LineHeightPt > 0:
+		return f.LineHeightPt
+	case f.LineHeight > 0:
+		return l.size * 1.2 * f.LineHeight
+	}
+	return l.size * 1.2
+}
+
+// drawLine выводит i-ю строку абзаца, y — верхняя граница строки
+func (w *writer) drawLine(p *paragraph, i int, y float64) {
+	l := p.lines[i]
+	f := p.block.Format
+	h := w.lineHeight(l, f)
+	baseline := y + (h-l.size*1.2)/2 + l.size*0.95
+
+	x, avail := p.x, p.width
+	if i == 0 {
+		x += f.FirstIndent
+		avail -= f.FirstIndent
+		if p.prefix != "" {
+			family, style, size := w.font(richtext.RunFormat{}, p.block.Heading)
+			if len(l.frags) > 0 {
+				size = l.frags[0].size
+			}
+			w.pdf.SetFont(family, style, size)
+			w.pdf.SetTextColor(0, 0, 0)
+			w.pdf.Text(p.x-listHanging, baseline, p.prefix)
+		}
+	}
+
+	used := l.width
+	if n := len(l.frags); n > 0 {
+		used -= l.frags[n-1].trail
+	}
+
+	var gap float64 // дополнительный интервал между словами при выравнивании по ширине
+	switch f.Align {
+	case "center":
+		x += (avail - used) / 2
+	case "right":
+		x += avail - used
+	case "justify":
+		if gaps := countGaps(l); !l.last && gaps > 0 && used < avail {
+			gap = (avail - used) / float64(gaps)
+		}
+	}
+
+	for j, frag := range l.frags {
+		width := frag.width
+		if j == len(l.frags)-1 {
+			width -= frag.trail
+		}
+		w.drawFragment(frag, x, y, width, h, baseline)
+		x += frag.width
+		if frag.trail > 0 {
+			x += gap
+		}
+	}
+}
+
+func countGaps(l line) int {
+	n := 0
+	for _, frag := range l.frags[:max(len(l.frags)-1, 0)] {
+		if frag.trail > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func (w *writer) drawFragment(frag fragment, x, y, width, h, baseline float64) {
+	pdf := w.pdf
+	f := frag.format
+
+	if f.Background != "" {
+		pdf.SetFillColor(hexRGB(f.Background))
+		pdf.Rect(x, y, frag.width, h, "F")
+	}
+
+	r, g, b := 0, 0, 0
+	if f.Color != "" {
+		r, g, b = hexRGB(f.Color)
+	}
+	pdf.SetFont(frag.family, frag.style, frag.size)
+	pdf.SetTextColor(r, g, b)
+
+	slanted := w.fonts.isSlanted(frag.family, frag.style)
+	if slanted {
+		pdf.TransformBegin()
+		pdf.TransformSkewX(obliqueAngle, x, baseline)
+	}
+	if f.LetterSpacing == 0 {
+		pdf.Text(x, baseline, frag.text)
+	} else {
+		cx := x
+		for _, ch := range frag.text {
+			pdf.Text(cx, baseline, string(ch))
+			cx += pdf.GetStringWidth(string(ch)) + f.LetterSpacing
+		}
+	}
+	if slanted {
+		pdf.TransformEnd()
+	}
+
+	if f.Underline || f.Strike {
+		pdf.SetDrawColor(r, g, b)
+		pdf.SetLineWidth(frag.size / 18)
+		if f.Underline {
+			pdf.Line(x, baseline+frag.size*0.12, x+width, baseline+frag.size*0.12)
+		}
+		if f.Strike {
+			pdf.Line(x, baseline-frag.size*0.3, x+width, baseline-frag.size*0.3)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+// Package pdf переводит разобранный HTML (richtext) в PDF средствами Go, без
+// wkhtmltopdf. Поддерживается то же подмножество HTML, что и в экспорте DOCX:
+// абзацы, заголовки, списки, таблицы и форматирование фрагментов. TTF-шрифты
+// с кириллицей встраиваются в документ; основной шрифт (DejaVu Sans) собран
+// в бинарник, поэтому экспорт не зависит от шрифтов в системе.
+package pdf
+
+import (
+	"embed"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+
+	"doc-generation/config"
+	"doc-generation/richtext"
+)
+
+// Options — параметры страницы и шрифтов
+type Options struct {
+	PageSize  string  // A4, A5, Letter, Legal
+	Landscape bool    // альбомная ориентация
+	Margins   Margins // поля страницы в миллиметрах
+	FontDir   string  // каталог с дополнительными TTF-шрифтами; пусто — только встроенные
+	Font      string  // базовое имя файла основного шрифта: DejaVuSans → DejaVuSans.ttf, DejaVuSans-Bold.ttf…
+	FontSize  float64 // основной размер шрифта в пунктах
+	Rules     []richtext.StyleRule
+}
+
+// Margins — поля страницы
+type Margins struct {
+	Top, Right, Bottom, Left float64
+}
+
+// DefaultOptions читает параметры из окружения: PDF_FONT_DIR (необязательный каталог,
+// шрифты из него важнее встроенных), PDF_FONT, PDF_PAGE_SIZE,
+// PDF_ORIENTATION (portrait/landscape), PDF_MARGIN_MM
+func DefaultOptions() Options {
+	margin, err := strconv.ParseFloat(config.GetEnv("PDF_MARGIN_MM", "20"), 64)
+	if err != nil {
+		margin = 20
+	}
+	return Options{
+		PageSize:  config.GetEnv("PDF_PAGE_SIZE", "A4"),
+		Landscape: strings.EqualFold(config.GetEnv("PDF_ORIENTATION", "portrait"), "landscape"),
+		Margins:   Margins{Top: margin, Right: margin, Bottom: margin, Left: margin},
+		FontDir:   config.GetEnv("PDF_FONT_DIR", ""),
+		Font:      config.GetEnv("PDF_FONT", "DejaVuSans"),
+		FontSize:  12,
+	}
+}
+
+// Convert разбирает HTML и записывает PDF в w
+func Convert(w io.Writer, htmlStr string, opts Options) error {
+	parsed, err := richtext.Parse(htmlStr, opts.Rules)
+	if err != nil {
+		return err
+	}
+	return Write(w, parsed, opts)
+}
+
+// Write записывает разобранный документ в w в формате PDF
+func Write(out io.Writer, parsed *richtext.Document, opts Options) error {
+	if opts.FontSize <= 0 {
+		opts.FontSize = 12
+	}
+	if opts.PageSize == "" {
+		opts.PageSize = "A4"
+	}
+	orientation := "P"
+	if opts.Landscape {
+		orientation = "L"
+	}
+
+	doc := gofpdf.New(orientation, "pt", opts.PageSize, "")
+	doc.SetAutoPageBreak(false, 0)
+
+	fonts := newFontSet(doc, opts.FontDir)
+	if !fonts.register(defaultFamily, opts.Font) {
+		return fmt.Errorf("шрифт %s.ttf не найден ни среди встроенных, ни в каталоге %q (PDF_FONT_DIR)", opts.Font, opts.FontDir)
+	}
+
+	pageW, pageH := doc.GetPageSize()
+	w := &writer{
+		pdf:      doc,
+		fonts:    fonts,
+		opts:     opts,
+		left:     mm(opts.Margins.Left),
+		right:    pageW - mm(opts.Margins.Right),
+		top:      mm(opts.Margins.Top),
+		bottom:   pageH - mm(opts.Margins.Bottom),
+		counters: make(map[int][]int),
+	}
+	w.newPage()
+
+	for _, b := range parsed.Blocks {
+		if b.Table != nil {
+			w.writeTable(b.Table)
+			continue
+		}
+		w.writeParagraph(b)
+	}
+
+	if err := doc.Error(); err != nil {
+		return err
+	}
+	return doc.Output(out)
+}
+
+// mm переводит миллиметры в пункты
+func mm(v float64) float64 {
+	return v * 72 / 25.4
+}
+
+const defaultFamily = "main"
+
+// embeddedFonts — шрифты, собранные в бинарник (лицензия в fonts/LICENSE)
+//
+//go:embed fonts/*.ttf
+var embeddedFonts embed.FS
+
+// obliqueAngle — наклон курсива, который рисуется из прямого начертания, в градусах
+// (как у DejaVu Sans Oblique)
+const obliqueAngle = 11
+
+// fontSet регистрирует TTF-шрифты: основной и, если файлы найдутся,
+// шрифты из CSS font-family ("Times New Roman" → TimesNewRoman.ttf).
+// Файлы ищутся сначала в каталоге FontDir, затем среди встроенных. Имя из CSS
+// только сопоставляется со списком найденных файлов и в путь не попадает.
+type fontSet struct {
+	pdf      *gofpdf.Fpdf
+	dir      string
+	dirFiles map[string]bool    // имена TTF-файлов в FontDir
+	embedded map[string]bool    // имена встроенных TTF-файлов
+	bases    map[string]string  // имя семейства без пробелов в нижнем регистре → базовое имя файла
+	families map[string]string  // font-family → зарегистрированное семейство
+	slanted  map[[2]string]bool // семейство и начертание без курсивного файла — наклон рисуется при выводе
+}
+
+func newFontSet(pdf *gofpdf.Fpdf, dir string) *fontSet {
+	fs := &fontSet{
+		pdf:      pdf,
+		dir:      dir,
+		dirFiles: make(map[string]bool),
+		embedded: make(map[string]bool),
+		bases:    make(map[string]string),
+		families: make(map[string]string),
+		slanted:  make(map[[2]string]bool),
+	}
+	if entries, err := embeddedFonts.ReadDir("fonts"); err == nil {
+		fs.addFiles(entries, fs.embedded)
+	}
+	if dir != "" {
+		// каталог может отсутствовать — тогда используются только встроенные шрифты
+		if entries, err := os.ReadDir(dir); err == nil {
+			fs.addFiles(entries, fs.dirFiles)
+		}
+	}
+	return fs
+}
+
+func (fs *fontSet) addFiles(entries []os.DirEntry, files map[string]bool) {
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.EqualFold(filepath.Ext(name), ".ttf") {
+			continue
+		}
+		files[name] = true
+		base := strings.TrimSuffix(name, filepath.Ext(name))
+		fs.bases[familyKey(base)] = base
+	}
+}
+
+// familyKey приводит имя семейства к виду для поиска: "Times New Roman" → "timesnewroman"
+func familyKey(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, " ", ""))
+}
+
+// fontStyles — варианты начертаний и возможные суффиксы файлов
+var fontStyles = []struct {
+	style    string
+	suffixes []string
+}{
+	{"", []string{""}},
+	{"B", []string{"-Bold", "bd"}},
+	{"I", []string{"-Oblique", "-Italic", "i"}},
+	{"BI", []string{"-BoldOblique", "-BoldItalic", "bi", "z"}},
+}
+
+// register добавляет семейство из файлов base*.ttf. Недостающие начертания
+// заменяются ближайшими имеющимися, чтобы текст не пропадал; курсив без своего
+// файла выводится наклоном прямого или жирного начертания.
+func (fs *fontSet) register(family, base string) bool {
+	if !fs.exists(base + ".ttf") {
+		return false
+	}
+	files := make(map[string]string)
+	for _, v := range fontStyles {
+		for _, suffix := range v.suffixes {
+			if name := base + suffix + ".ttf"; fs.exists(name) {
+				files[v.style] = name
+				break
+			}
+		}
+		if files[v.style] != "" {
+			continue
+		}
+		switch {
+		case v.style == "BI" && files["B"] != "":
+			files[v.style] = files["B"]
+		default:
+			files[v.style] = files[""]
+		}
+		if strings.Contains(v.style, "I") {
+			fs.slanted[[2]string{family, v.style}] = true
+		}
+	}
+	for style, file := range files {
+		data, err := fs.read(file)
+		if err != nil {
+			fs.pdf.SetError(err)
+			return false
+		}
+		fs.pdf.AddUTF8FontFromBytes(family, style, data)
+	}
+	return true
+}
+
+// exists проверяет имя файла по списку найденных шрифтов
+func (fs *fontSet) exists(name string) bool {
+	return fs.dirFiles[name] || fs.embedded[name]
+}
+
+func (fs *fontSet) read(name string) ([]byte, error) {
+	if fs.dirFiles[name] {
+		return os.ReadFile(filepath.Join(fs.dir, name))
+	}
+	return embeddedFonts.ReadFile("fonts/" + name)
+}
+
+// family возвращает зарегистрированное семейство для CSS font-family;
+// неизвестные имена заменяются основным шрифтом
+func (fs *fontSet) family(cssFamily string) string {
+	if cssFamily == "" {
+		return defaultFamily
+	}
+	if f, ok := fs.families[cssFamily]; ok {
+		return f
+	}
+	f := defaultFamily
+	if base, ok := fs.bases[familyKey(cssFamily)]; ok {
+		name := "css-" + familyKey(base)
+		if fs.register(name, base) {
+			f = name
+		}
+	}
+	fs.families[cssFamily] = f
+	return f
+}
+
+// isSlanted сообщает, что у начертания нет курсивного файла и наклон рисуется при выводе
+func (fs *fontSet) isSlanted(family, style string) bool {
+	return fs.slanted[[2]string{family, style}]
+}
+
+// hexRGB переводит "FF0000" в компоненты цвета
+func hexRGB(hex string) (r, g, b int) {
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return 0, 0, 0
+	}
+	return int(v >> 16 & 0xFF), int(v >> 8 & 0xFF), int(v & 0xFF)
+}
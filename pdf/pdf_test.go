@@ -0,0 +1,122 @@
+package pdf
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// TestConvertEmbeddedFonts проверяет, что экспорт работает без шрифтов в системе:
+// каталог шрифтов пуст или не задан — используются встроенные
+func TestConvertEmbeddedFonts(t *testing.T) {
+	for _, dir := range []string{"", t.TempDir()} {
+		opts := DefaultOptions()
+		opts.FontDir = dir
+
+		var buf bytes.Buffer
+		err := Convert(&buf, `<h1>Договор</h1><p>Сумма: <b>сто рублей</b>, <i>курсив</i></p>`, opts)
+		if err != nil {
+			t.Fatalf("FontDir=%q: %v", dir, err)
+		}
+		if !bytes.HasPrefix(buf.Bytes(), []byte("%PDF-")) {
+			t.Fatalf("FontDir=%q: результат не похож на PDF", dir)
+		}
+		if !bytes.Contains(buf.Bytes(), []byte("/FontFile2")) {
+			t.Errorf("FontDir=%q: TTF-шрифт не встроен в документ", dir)
+		}
+	}
+}
+
+func TestConvertUnknownFont(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Font = "NoSuchFont"
+	if err := Convert(&bytes.Buffer{}, "<p>текст</p>", opts); err == nil {
+		t.Fatal("ожидалась ошибка для отсутствующего шрифта")
+	}
+}
+
+// fontDir создаёт каталог шрифтов с копиями встроенного DejaVuSans.ttf под именами files
+func fontDir(t *testing.T, dir string, files ...string) {
+	t.Helper()
+	data, err := embeddedFonts.ReadFile("fonts/DejaVuSans.ttf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestFontFamilyWhitelist проверяет, что font-family выбирает только шрифты
+// из каталога FontDir и встроенные, а пути в имени не открывают другие файлы
+func TestFontFamilyWhitelist(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "fonts")
+	fontDir(t, root, "Outside.ttf")
+	fontDir(t, dir, "CustomSerif.ttf")
+
+	fs := newFontSet(gofpdf.New("P", "pt", "A4", ""), dir)
+	if !fs.register(defaultFamily, "DejaVuSans") {
+		t.Fatal("основной шрифт не зарегистрирован")
+	}
+
+	tests := []struct {
+		css, want string
+	}{
+		{"Custom Serif", "css-customserif"},
+		{"customserif", "css-customserif"},
+		{"DejaVu Sans", "css-dejavusans"},
+		{"NoSuchFont", defaultFamily},
+		{"../Outside", defaultFamily},
+		{filepath.Join(root, "Outside"), defaultFamily},
+		{"../../../../etc/passwd", defaultFamily},
+	}
+	for _, tt := range tests {
+		if got := fs.family(tt.css); got != tt.want {
+			t.Errorf("family(%q) = %q, ожидалось %q", tt.css, got, tt.want)
+		}
+	}
+	if err := fs.pdf.Error(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestFontItalicStyles проверяет, что курсив берётся из файла -Oblique, а без
+// него выводится наклоном
+func TestFontItalicStyles(t *testing.T) {
+	dir := t.TempDir()
+	fontDir(t, dir, "Full.ttf", "Full-Bold.ttf", "Full-Oblique.ttf", "Full-BoldOblique.ttf", "Plain.ttf")
+
+	fs := newFontSet(gofpdf.New("P", "pt", "A4", ""), dir)
+	for _, base := range []string{"Full", "Plain"} {
+		if !fs.register(base, base) {
+			t.Fatalf("шрифт %s не зарегистрирован", base)
+		}
+	}
+
+	tests := []struct {
+		family, style string
+		slanted       bool
+	}{
+		{"Full", "", false},
+		{"Full", "I", false},
+		{"Full", "BI", false},
+		{"Plain", "", false},
+		{"Plain", "B", false},
+		{"Plain", "I", true},
+		{"Plain", "BI", true},
+	}
+	for _, tt := range tests {
+		if got := fs.isSlanted(tt.family, tt.style); got != tt.slanted {
+			t.Errorf("isSlanted(%s, %q) = %v, ожидалось %v", tt.family, tt.style, got, tt.slanted)
+		}
+	}
+}
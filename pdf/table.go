@@ -0,0 +1,119 @@
+package pdf
+
+import "doc-generation/richtext"
+
+// cellPadding — внутренний отступ ячейки, пт
+const cellPadding = 4
+
+func (w *writer) writeTable(t *richtext.Table) {
+	cells, cols := t.Grid()
+	if cols == 0 {
+		return
+	}
+
+	tableW := w.right - w.left
+	switch {
+	case t.WidthPercent > 0:
+		tableW = tableW * t.WidthPercent / 100
+	case t.WidthPt > 0 && t.WidthPt < tableW:
+		tableW = t.WidthPt
+	}
+	colW := t.ResolveColumnWidths(tableW)
+
+	// высота строк — по самой высокой ячейке; ячейки с rowspan добавляют
+	// недостающую высоту к последней из объединённых строк
+	rowH := make([]float64, len(t.Rows))
+	content := make([][]*paragraph, len(cells))
+	contentH := make([]float64, len(cells))
+	for i, pc := range cells {
+		_, width := w.cellBox(colW, pc)
+		for _, b := range pc.Cell.Blocks {
+			// абзацы раскладываются относительно левого края ячейки и сдвигаются при выводе
+			p := w.prepare(b, cellPadding, width-2*cellPadding)
+			content[i] = append(content[i], p)
+			contentH[i] += w.height(p)
+		}
+		if len(pc.Cell.Blocks) == 0 {
+			_, _, size := w.font(richtext.RunFormat{}, 0)
+			contentH[i] = size * 1.2
+		}
+		if pc.Cell.RowSpan == 1 {
+			rowH[pc.Row] = max(rowH[pc.Row], contentH[i]+2*cellPadding)
+		}
+	}
+	for i, pc := range cells {
+		last := min(pc.Row+pc.Cell.RowSpan, len(rowH)) - 1
+		var h float64
+		for r := pc.Row; r <= last; r++ {
+			h += rowH[r]
+		}
+		if need := contentH[i] + 2*cellPadding; need > h {
+			rowH[last] += need - h
+		}
+	}
+
+	for r := range t.Rows {
+		if w.y+rowH[r] > w.bottom && w.y > w.top {
+			w.newPage()
+		}
+		for i, pc := range cells {
+			if pc.Row != r {
+				continue
+			}
+			x, width := w.cellBox(colW, pc)
+			var h float64
+			for rr := r; rr < min(r+pc.Cell.RowSpan, len(rowH)); rr++ {
+				h += rowH[rr]
+			}
+			w.drawCell(t, *pc.Cell, content[i], contentH[i], x+w.left, w.y, width, h)
+		}
+		w.y += rowH[r]
+	}
+}
+
+// cellBox возвращает смещение ячейки от левого края таблицы и её ширину
+func (w *writer) cellBox(colW []float64, pc richtext.CellPosition) (x, width float64) {
+	for i := 0; i < pc.Col; i++ {
+		x += colW[i]
+	}
+	for i := pc.Col; i < pc.Col+pc.Cell.ColSpan && i < len(colW); i++ {
+		width += colW[i]
+	}
+	return x, width
+}
+
+func (w *writer) drawCell(t *richtext.Table, c richtext.TableCell, content []*paragraph, contentH, x, y, width, h float64) {
+	pdf := w.pdf
+	if c.Background != "" {
+		pdf.SetFillColor(hexRGB(c.Background))
+		pdf.Rect(x, y, width, h, "F")
+	}
+
+	top := y + cellPadding
+	switch c.VAlign {
+	case "middle", "center":
+		top = y + (h-contentH)/2
+	case "bottom":
+		top = y + h - cellPadding - contentH
+	}
+
+	for _, p := range content {
+		p.x += x
+		top += p.block.Format.SpaceBefore
+		for i, l := range p.lines {
+			w.drawLine(p, i, top)
+			top += w.lineHeight(l, p.block.Format)
+		}
+		top += p.block.Format.SpaceAfter
+	}
+
+	if t.Border > 0 {
+		r, g, b := 0, 0, 0
+		if t.BorderColor != "" {
+			r, g, b = hexRGB(t.BorderColor)
+		}
+		pdf.SetDrawColor(r, g, b)
+		pdf.SetLineWidth(t.Border)
+		pdf.Rect(x, y, width, h, "D")
+	}
+}
@@ -0,0 +1,246 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Падежи в порядке индексов форм: именительный не склоняется и в таблицах не хранится
+const (
+	caseNominative = iota
+	caseGenitive
+	caseDative
+	caseAccusative
+	caseInstrumental
+	casePrepositional
+)
+
+var caseNames = map[string]int{
+	"nominative":    caseNominative,
+	"genitive":      caseGenitive,
+	"dative":        caseDative,
+	"accusative":    caseAccusative,
+	"instrumental":  caseInstrumental,
+	"prepositional": casePrepositional,
+	"именительный":  caseNominative,
+	"родительный":   caseGenitive,
+	"дательный":     caseDative,
+	"винительный":   caseAccusative,
+	"творительный":  caseInstrumental,
+	"предложный":    casePrepositional,
+}
+
+// declRule — окончание слова и формы для косвенных падежей (Р, Д, В, Т, П).
+// cut — сколько последних букв отбросить перед добавлением формы.
+type declRule struct {
+	suffix string
+	cut    int
+	forms  [5]string
+}
+
+var (
+	maleSurnameRules = []declRule{
+		{"ский", 2, [5]string{"ого", "ому", "ого", "им", "ом"}},
+		{"цкий", 2, [5]string{"ого", "ому", "ого", "им", "ом"}},
+		{"ий", 2, [5]string{"его", "ему", "его", "им", "ем"}},
+		{"ый", 2, [5]string{"ого", "ому", "ого", "ым", "ом"}},
+		{"ой", 2, [5]string{"ого", "ому", "ого", "ым", "ом"}},
+		{"ов", 0, [5]string{"а", "у", "а", "ым", "е"}},
+		{"ев", 0, [5]string{"а", "у", "а", "ым", "е"}},
+		{"ёв", 0, [5]string{"а", "у", "а", "ым", "е"}},
+		{"ин", 0, [5]string{"а", "у", "а", "ым", "е"}},
+		{"ын", 0, [5]string{"а", "у", "а", "ым", "е"}},
+		{"ка", 1, [5]string{"и", "е", "у", "ой", "е"}},
+		{"а", 1, [5]string{"ы", "е", "у", "ой", "е"}},
+		{"я", 1, [5]string{"и", "е", "ю", "ей", "е"}},
+		{"ь", 1, [5]string{"я", "ю", "я", "ем", "е"}},
+		{"й", 1, [5]string{"я", "ю", "я", "ем", "е"}},
+	}
+	femaleSurnameRules = []declRule{
+		{"ская", 2, [5]string{"ой", "ой", "ую", "ой", "ой"}},
+		{"цкая", 2, [5]string{"ой", "ой", "ую", "ой", "ой"}},
+		{"ая", 2, [5]string{"ой", "ой", "ую", "ой", "ой"}},
+		{"ова", 1, [5]string{"ой", "ой", "у", "ой", "ой"}},
+		{"ева", 1, [5]string{"ой", "ой", "у", "ой", "ой"}},
+		{"ёва", 1, [5]string{"ой", "ой", "у", "ой", "ой"}},
+		{"ина", 1, [5]string{"ой", "ой", "у", "ой", "ой"}},
+		{"ына", 1, [5]string{"ой", "ой", "у", "ой", "ой"}},
+		{"ка", 1, [5]string{"и", "е", "у", "ой", "е"}},
+		{"а", 1, [5]string{"ы", "е", "у", "ой", "е"}},
+		{"я", 1, [5]string{"и", "е", "ю", "ей", "е"}},
+	}
+	maleNameRules = []declRule{
+		{"ий", 1, [5]string{"я", "ю", "я", "ем", "и"}},
+		{"ей", 1, [5]string{"я", "ю", "я", "ем", "е"}},
+		{"ай", 1, [5]string{"я", "ю", "я", "ем", "е"}},
+		{"ь", 1, [5]string{"я", "ю", "я", "ем", "е"}},
+		{"ка", 1, [5]string{"и", "е", "у", "ой", "е"}},
+		{"га", 1, [5]string{"и", "е", "у", "ой", "е"}},
+		{"ха", 1, [5]string{"и", "е", "у", "ой", "е"}},
+		{"а", 1, [5]string{"ы", "е", "у", "ой", "е"}},
+		{"я", 1, [5]string{"и", "е", "ю", "ей", "е"}},
+	}
+	femaleNameRules = []declRule{
+		{"ия", 1, [5]string{"и", "и", "ю", "ей", "и"}},
+		{"ка", 1, [5]string{"и", "е", "у", "ой", "е"}},
+		{"га", 1, [5]string{"и", "е", "у", "ой", "е"}},
+		{"ха", 1, [5]string{"и", "е", "у", "ой", "е"}},
+		{"жа", 1, [5]string{"и", "е", "у", "ей", "е"}},
+		{"ша", 1, [5]string{"и", "е", "у", "ей", "е"}},
+		{"ча", 1, [5]string{"и", "е", "у", "ей", "е"}},
+		{"ща", 1, [5]string{"и", "е", "у", "ей", "е"}},
+		{"а", 1, [5]string{"ы", "е", "у", "ой", "е"}},
+		{"я", 1, [5]string{"и", "е", "ю", "ей", "е"}},
+		{"ь", 1, [5]string{"и", "и", "ь", "ью", "и"}},
+	}
+	malePatronymicRules = []declRule{
+		{"ич", 0, [5]string{"а", "у", "а", "ем", "е"}},
+	}
+	femalePatronymicRules = []declRule{
+		{"на", 1, [5]string{"ы", "е", "у", "ой", "е"}},
+	}
+)
+
+// indeclinableEndings — окончания фамилий, которые не склоняются (Шевченко, Дюма, Черных)
+var indeclinableEndings = []string{"о", "е", "и", "у", "ю", "ых", "их"}
+
+// formatDecline: decline:"genitive" склоняет ФИО («Фамилия Имя Отчество») по падежам.
+// Род определяется по отчеству, а при его отсутствии — по окончаниям фамилии и имени.
+// Инициалы («И.») не изменяются.
+func formatDecline(value string, args []string) (string, error) {
+	if len(args) == 0 {
+		return "", fmt.Errorf("не указан падеж")
+	}
+	c, ok := caseNames[strings.ToLower(args[0])]
+	if !ok {
+		return "", fmt.Errorf("неизвестный падеж %q", args[0])
+	}
+	return DeclineFullName(value, c), nil
+}
+
+// DeclineFullName склоняет ФИО в указанный падеж (0 — именительный, 1 — родительный и т.д.)
+func DeclineFullName(fullName string, grammaticalCase int) string {
+	words := strings.Fields(fullName)
+	if grammaticalCase == caseNominative || len(words) == 0 {
+		return fullName
+	}
+
+	female := isFemaleName(words)
+	for i, w := range words {
+		if strings.Contains(w, ".") {
+			continue
+		}
+		switch i {
+		case 0:
+			if len(words) == 1 {
+				words[i] = declineName(w, female, grammaticalCase)
+			} else {
+				words[i] = declineSurname(w, female, grammaticalCase)
+			}
+		case 1:
+			words[i] = declineName(w, female, grammaticalCase)
+		case 2:
+			words[i] = declinePatronymic(w, female, grammaticalCase)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// formatInitials: «Иванов Иван Иванович» → «Иванов И. И.»
+func formatInitials(value string, _ []string) (string, error) {
+	words := strings.Fields(value)
+	if len(words) < 2 {
+		return value, nil
+	}
+	out := []string{words[0]}
+	for _, w := range words[1:] {
+		r, _ := utf8.DecodeRuneInString(w)
+		out = append(out, string(r)+".")
+	}
+	return strings.Join(out, " "), nil
+}
+
+func isFemaleName(words []string) bool {
+	if len(words) >= 3 {
+		p := strings.ToLower(words[2])
+		if strings.HasSuffix(p, "вна") || strings.HasSuffix(p, "чна") || strings.HasSuffix(p, "кызы") {
+			return true
+		}
+		if strings.HasSuffix(p, "ич") || strings.HasSuffix(p, "оглы") {
+			return false
+		}
+	}
+	surname := strings.ToLower(words[0])
+	for _, suffix := range []string{"ова", "ева", "ёва", "ина", "ына", "ая"} {
+		if strings.HasSuffix(surname, suffix) {
+			return true
+		}
+	}
+	if len(words) >= 2 {
+		name := strings.ToLower(words[1])
+		return strings.HasSuffix(name, "а") || strings.HasSuffix(name, "я")
+	}
+	return false
+}
+
+func declineSurname(word string, female bool, c int) string {
+	lower := strings.ToLower(word)
+	for _, ending := range indeclinableEndings {
+		if strings.HasSuffix(lower, ending) {
+			return word
+		}
+	}
+	if female {
+		return applyRules(word, femaleSurnameRules, c, false)
+	}
+	return applyRules(word, maleSurnameRules, c, true)
+}
+
+func declineName(word string, female bool, c int) string {
+	if female {
+		return applyRules(word, femaleNameRules, c, false)
+	}
+	return applyRules(word, maleNameRules, c, true)
+}
+
+func declinePatronymic(word string, female bool, c int) string {
+	if female {
+		return applyRules(word, femalePatronymicRules, c, false)
+	}
+	return applyRules(word, malePatronymicRules, c, false)
+}
+
+// applyRules применяет первое подходящее правило. consonantDefault — склонять ли слова
+// на согласную, не попавшие ни под одно правило (мужские имена и фамилии: Петров, Шевчук).
+func applyRules(word string, rules []declRule, c int, consonantDefault bool) string {
+	lower := strings.ToLower(word)
+	for _, r := range rules {
+		if strings.HasSuffix(lower, r.suffix) {
+			return cutRunes(word, r.cut) + r.forms[c-1]
+		}
+	}
+
+	if consonantDefault && endsWithConsonant(lower) {
+		forms := [5]string{"а", "у", "а", "ом", "е"}
+		if strings.HasSuffix(lower, "ж") || strings.HasSuffix(lower, "ш") ||
+			strings.HasSuffix(lower, "ч") || strings.HasSuffix(lower, "щ") || strings.HasSuffix(lower, "ц") {
+			forms[3] = "ем"
+		}
+		return word + forms[c-1]
+	}
+	return word
+}
+
+func cutRunes(s string, n int) string {
+	for i := 0; i < n && s != ""; i++ {
+		_, size := utf8.DecodeLastRuneInString(s)
+		s = s[:len(s)-size]
+	}
+	return s
+}
+
+func endsWithConsonant(s string) bool {
+	r, _ := utf8.DecodeLastRuneInString(s)
+	return strings.ContainsRune("бвгджзклмнпрстфхцчшщ", r)
+}
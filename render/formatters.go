@@ -0,0 +1,381 @@
+package render
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Formatter преобразует значение поля. args — аргументы после двоеточия:
+// {{date | date:"long"}} → args = ["long"].
+type Formatter func(value string, args []string) (string, error)
+
+// formatters — встроенная библиотека форматтеров для русской локали
+var formatters = map[string]Formatter{
+	"upper":        formatUpper,
+	"lower":        formatLower,
+	"capitalize":   formatCapitalize,
+	"default":      formatDefault,
+	"number":       formatNumber,
+	"money":        formatMoney,
+	"number_words": formatNumberWords,
+	"money_words":  formatMoneyWords,
+	"date":         formatDate,
+	"decline":      formatDecline,
+	"initials":     formatInitials,
+}
+
+// RegisterFormatter добавляет или переопределяет форматтер.
+// Вызывать до начала обработки запросов (карта не защищена мьютексом).
+func RegisterFormatter(name string, f Formatter) {
+	formatters[name] = f
+}
+
+// filterCall — один шаг конвейера {{value | name:"arg1","arg2"}}
+type filterCall struct {
+	name string
+	args []string
+}
+
+// parseExpression разбирает «имя | форматтер:"арг" | …»
+func parseExpression(expr string) (string, []filterCall, error) {
+	parts := splitOutsideQuotes(expr, '|')
+	name := strings.TrimSpace(parts[0])
+	if name == "" {
+		return "", nil, fmt.Errorf("не указано имя поля")
+	}
+
+	var filters []filterCall
+	for _, part := range parts[1:] {
+		part = strings.TrimSpace(part)
+		fname, rawArgs, hasArgs := strings.Cut(part, ":")
+		fname = strings.TrimSpace(fname)
+		if _, ok := formatters[fname]; !ok {
+			return "", nil, fmt.Errorf("неизвестный форматтер %q", fname)
+		}
+
+		call := filterCall{name: fname}
+		if hasArgs {
+			for _, arg := range splitOutsideQuotes(rawArgs, ',') {
+				arg = strings.TrimSpace(arg)
+				if unq, err := strconv.Unquote(arg); err == nil {
+					arg = unq
+				}
+				call.args = append(call.args, arg)
+			}
+		}
+		filters = append(filters, call)
+	}
+	return name, filters, nil
+}
+
+// applyFilters прогоняет значение через конвейер. Если форматтер не смог обработать
+// значение (например, money_words для «abc»), значение остаётся как есть.
+func applyFilters(value string, filters []filterCall) string {
+	for _, f := range filters {
+		if out, err := formatters[f.name](value, f.args); err == nil {
+			value = out
+		}
+	}
+	return value
+}
+
+func splitOutsideQuotes(s string, sep rune) []string {
+	var parts []string
+	var cur strings.Builder
+	inQuotes := false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+			cur.WriteRune(r)
+		case r == sep && !inQuotes:
+			parts = append(parts, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	return append(parts, cur.String())
+}
+
+// ----------------- Строки -----------------
+
+func formatUpper(value string, _ []string) (string, error) {
+	return strings.ToUpper(value), nil
+}
+
+func formatLower(value string, _ []string) (string, error) {
+	return strings.ToLower(value), nil
+}
+
+func formatCapitalize(value string, _ []string) (string, error) {
+	r, size := utf8.DecodeRuneInString(value)
+	if r == utf8.RuneError {
+		return value, nil
+	}
+	return string(unicode.ToUpper(r)) + value[size:], nil
+}
+
+func formatDefault(value string, args []string) (string, error) {
+	if strings.TrimSpace(value) == "" && len(args) > 0 {
+		return args[0], nil
+	}
+	return value, nil
+}
+
+// ----------------- Числа и суммы -----------------
+
+// parseAmount разбирает сумму вида «1 234,56» на целую часть и копейки (с округлением)
+func parseAmount(value string) (negative bool, whole uint64, cents uint64, err error) {
+	s := strings.NewReplacer(" ", "", "\u00a0", "", ",", ".").Replace(strings.TrimSpace(value))
+	if strings.HasPrefix(s, "-") {
+		negative = true
+		s = s[1:]
+	}
+
+	intPart, fracPart, _ := strings.Cut(s, ".")
+	if intPart == "" {
+		intPart = "0"
+	}
+	whole, err = strconv.ParseUint(intPart, 10, 64)
+	if err != nil {
+		return false, 0, 0, fmt.Errorf("некорректное число %q", value)
+	}
+
+	fracPart += "000"
+	for _, r := range fracPart {
+		if r < '0' || r > '9' {
+			return false, 0, 0, fmt.Errorf("некорректное число %q", value)
+		}
+	}
+	cents, _ = strconv.ParseUint(fracPart[:2], 10, 64)
+	if fracPart[2] >= '5' {
+		cents++
+		if cents == 100 {
+			cents = 0
+			whole++
+		}
+	}
+	return negative, whole, cents, nil
+}
+
+// groupThousands разбивает число на разряды неразрывными пробелами: 1234567 → «1 234 567»
+func groupThousands(n uint64) string {
+	s := strconv.FormatUint(n, 10)
+	var sb strings.Builder
+	for i, r := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			sb.WriteRune('\u00a0')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// formatNumber: «1234567.5» → «1 234 567,5»
+func formatNumber(value string, _ []string) (string, error) {
+	s := strings.NewReplacer(" ", "", "\u00a0", "", ",", ".").Replace(strings.TrimSpace(value))
+	if _, err := strconv.ParseFloat(s, 64); err != nil {
+		return "", err
+	}
+
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign = "-"
+		s = s[1:]
+	}
+	intPart, fracPart, _ := strings.Cut(s, ".")
+	whole, err := strconv.ParseUint(intPart, 10, 64)
+	if err != nil {
+		return "", err
+	}
+
+	out := sign + groupThousands(whole)
+	if fracPart != "" {
+		out += "," + fracPart
+	}
+	return out, nil
+}
+
+// formatMoney: «1234.5» → «1 234,50»
+func formatMoney(value string, _ []string) (string, error) {
+	negative, whole, cents, err := parseAmount(value)
+	if err != nil {
+		return "", err
+	}
+	out := fmt.Sprintf("%s,%02d", groupThousands(whole), cents)
+	if negative {
+		out = "-" + out
+	}
+	return out, nil
+}
+
+// formatNumberWords: «121» → «сто двадцать один»
+func formatNumberWords(value string, _ []string) (string, error) {
+	negative, whole, _, err := parseAmount(value)
+	if err != nil {
+		return "", err
+	}
+	out := NumberToWords(whole, false)
+	if negative {
+		out = "минус " + out
+	}
+	return out, nil
+}
+
+// formatMoneyWords: «120» → «сто двадцать рублей 00 копеек»
+func formatMoneyWords(value string, _ []string) (string, error) {
+	negative, whole, cents, err := parseAmount(value)
+	if err != nil {
+		return "", err
+	}
+	out := fmt.Sprintf("%s %s %02d %s",
+		NumberToWords(whole, false), plural(whole, "рубль", "рубля", "рублей"),
+		cents, plural(cents, "копейка", "копейки", "копеек"))
+	if negative {
+		out = "минус " + out
+	}
+	return out, nil
+}
+
+var (
+	unitsMasculine = []string{"", "один", "два", "три", "четыре", "пять", "шесть", "семь", "восемь", "девять"}
+	unitsFeminine  = []string{"", "одна", "две", "три", "четыре", "пять", "шесть", "семь", "восемь", "девять"}
+	teens          = []string{"десять", "одиннадцать", "двенадцать", "тринадцать", "четырнадцать", "пятнадцать", "шестнадцать", "семнадцать", "восемнадцать", "девятнадцать"}
+	tens           = []string{"", "", "двадцать", "тридцать", "сорок", "пятьдесят", "шестьдесят", "семьдесят", "восемьдесят", "девяносто"}
+	hundreds       = []string{"", "сто", "двести", "триста", "четыреста", "пятьсот", "шестьсот", "семьсот", "восемьсот", "девятьсот"}
+)
+
+// scales — названия разрядов: род и формы для 1 / 2–4 / 5+
+var scales = []struct {
+	feminine       bool
+	one, few, many string
+}{
+	{},
+	{true, "тысяча", "тысячи", "тысяч"},
+	{false, "миллион", "миллиона", "миллионов"},
+	{false, "миллиард", "миллиарда", "миллиардов"},
+	{false, "триллион", "триллиона", "триллионов"},
+	{false, "квадриллион", "квадриллиона", "квадриллионов"},
+	{false, "квинтиллион", "квинтиллиона", "квинтиллионов"},
+}
+
+// NumberToWords записывает целое число словами; feminine — женский род
+// для единиц («одна», «две»), например для «тысяч» или «копеек»
+func NumberToWords(n uint64, feminine bool) string {
+	if n == 0 {
+		return "ноль"
+	}
+
+	var groups []uint64
+	for n > 0 {
+		groups = append(groups, n%1000)
+		n /= 1000
+	}
+
+	var words []string
+	for i := len(groups) - 1; i >= 0; i-- {
+		g := groups[i]
+		if g == 0 {
+			continue
+		}
+		fem := feminine
+		if i > 0 {
+			fem = scales[i].feminine
+		}
+		words = append(words, tripletToWords(g, fem)...)
+		if i > 0 {
+			words = append(words, plural(g, scales[i].one, scales[i].few, scales[i].many))
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func tripletToWords(n uint64, feminine bool) []string {
+	var words []string
+	if h := n / 100; h > 0 {
+		words = append(words, hundreds[h])
+	}
+	rest := n % 100
+	switch {
+	case rest >= 10 && rest < 20:
+		words = append(words, teens[rest-10])
+	default:
+		if t := rest / 10; t > 0 {
+			words = append(words, tens[t])
+		}
+		if u := rest % 10; u > 0 {
+			if feminine {
+				words = append(words, unitsFeminine[u])
+			} else {
+				words = append(words, unitsMasculine[u])
+			}
+		}
+	}
+	return words
+}
+
+// plural выбирает форму слова для числа: 1 рубль, 2 рубля, 5 рублей
+func plural(n uint64, one, few, many string) string {
+	n100 := n % 100
+	n10 := n % 10
+	switch {
+	case n100 >= 11 && n100 <= 14:
+		return many
+	case n10 == 1:
+		return one
+	case n10 >= 2 && n10 <= 4:
+		return few
+	}
+	return many
+}
+
+// ----------------- Даты -----------------
+
+// DateLayouts — форматы, в которых принимаются даты в значениях полей
+var DateLayouts = []string{"2006-01-02", "02.01.2006", time.RFC3339}
+
+var monthsGenitive = []string{"", "января", "февраля", "марта", "апреля", "мая", "июня", "июля", "августа", "сентября", "октября", "ноября", "декабря"}
+
+// ParseDate разбирает дату в одном из форматов DateLayouts
+func ParseDate(value string) (time.Time, error) {
+	var lastErr error
+	for _, layout := range DateLayouts {
+		t, err := time.Parse(layout, strings.TrimSpace(value))
+		if err == nil {
+			return t, nil
+		}
+		lastErr = err
+	}
+	return time.Time{}, lastErr
+}
+
+// formatDate: date:"long" → «16 октября 2026 г.», date:"short" → «16.10.2026»,
+// date:"iso" → «2026-10-16», иначе аргумент трактуется как Go-layout
+func formatDate(value string, args []string) (string, error) {
+	t, err := ParseDate(value)
+	if err != nil {
+		return "", err
+	}
+
+	style := "short"
+	if len(args) > 0 {
+		style = args[0]
+	}
+
+	switch style {
+	case "long":
+		return fmt.Sprintf("%d %s %d г.", t.Day(), monthsGenitive[t.Month()], t.Year()), nil
+	case "quoted":
+		return fmt.Sprintf("«%02d» %s %d г.", t.Day(), monthsGenitive[t.Month()], t.Year()), nil
+	case "short":
+		return t.Format("02.01.2006"), nil
+	case "iso":
+		return t.Format("2006-01-02"), nil
+	}
+	return t.Format(style), nil
+}
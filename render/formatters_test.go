@@ -0,0 +1,136 @@
+package render
+
+import "testing"
+
+const nbsp = "\u00a0"
+
+func TestFormatters(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  string
+		value   string
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{name: "upper", filter: "upper", value: "ооо ромашка", want: "ООО РОМАШКА"},
+		{name: "lower", filter: "lower", value: "ООО Ромашка", want: "ооо ромашка"},
+		{name: "capitalize", filter: "capitalize", value: "договор", want: "Договор"},
+		{name: "default пустое", filter: "default", value: " ", args: []string{"—"}, want: "—"},
+		{name: "default заполненное", filter: "default", value: "есть", args: []string{"—"}, want: "есть"},
+
+		{name: "number", filter: "number", value: "1234567.5", want: "1" + nbsp + "234" + nbsp + "567,5"},
+		{name: "number отрицательное", filter: "number", value: "-1000", want: "-1" + nbsp + "000"},
+		{name: "number не число", filter: "number", value: "abc", wantErr: true},
+
+		{name: "money", filter: "money", value: "1234.5", want: "1" + nbsp + "234,50"},
+		{name: "money с пробелами и запятой", filter: "money", value: "1 234,56", want: "1" + nbsp + "234,56"},
+		{name: "money округление вверх", filter: "money", value: "0,995", want: "1,00"},
+		{name: "money округление вниз", filter: "money", value: "0,994", want: "0,99"},
+		{name: "money отрицательное", filter: "money", value: "-5", want: "-5,00"},
+		{name: "money не число", filter: "money", value: "12a", wantErr: true},
+
+		{name: "money_words 1", filter: "money_words", value: "1", want: "один рубль 00 копеек"},
+		{name: "money_words 2", filter: "money_words", value: "2,01", want: "два рубля 01 копейка"},
+		{name: "money_words 5", filter: "money_words", value: "5,02", want: "пять рублей 02 копейки"},
+		{name: "money_words 11", filter: "money_words", value: "11,11", want: "одиннадцать рублей 11 копеек"},
+		{name: "money_words 21", filter: "money_words", value: "21,21", want: "двадцать один рубль 21 копейка"},
+		{name: "money_words 120", filter: "money_words", value: "120", want: "сто двадцать рублей 00 копеек"},
+		{name: "money_words округление", filter: "money_words", value: "0,995", want: "один рубль 00 копеек"},
+		{name: "money_words отрицательное", filter: "money_words", value: "-3", want: "минус три рубля 00 копеек"},
+
+		{name: "number_words 0", filter: "number_words", value: "0", want: "ноль"},
+		{name: "number_words 121", filter: "number_words", value: "121", want: "сто двадцать один"},
+		{name: "number_words 1000", filter: "number_words", value: "1000", want: "одна тысяча"},
+		{name: "number_words 2000", filter: "number_words", value: "2000", want: "две тысячи"},
+		{name: "number_words 5000", filter: "number_words", value: "5000", want: "пять тысяч"},
+		{name: "number_words 11000", filter: "number_words", value: "11000", want: "одиннадцать тысяч"},
+		{name: "number_words 21001", filter: "number_words", value: "21001", want: "двадцать одна тысяча один"},
+		{name: "number_words 2000000", filter: "number_words", value: "2000000", want: "два миллиона"},
+		{name: "number_words отрицательное", filter: "number_words", value: "-12", want: "минус двенадцать"},
+
+		{name: "date по умолчанию", filter: "date", value: "2026-10-16", want: "16.10.2026"},
+		{name: "date long", filter: "date", value: "16.10.2026", args: []string{"long"}, want: "16 октября 2026 г."},
+		{name: "date quoted", filter: "date", value: "2026-03-05", args: []string{"quoted"}, want: "«05» марта 2026 г."},
+		{name: "date iso", filter: "date", value: "05.03.2026", args: []string{"iso"}, want: "2026-03-05"},
+		{name: "date layout", filter: "date", value: "2026-03-05", args: []string{"01/2006"}, want: "03/2026"},
+		{name: "date некорректная", filter: "date", value: "32.13.2026", args: []string{"long"}, wantErr: true},
+
+		{name: "decline родительный", filter: "decline", value: "Иванов Иван Иванович", args: []string{"genitive"}, want: "Иванова Ивана Ивановича"},
+		{name: "decline дательный женский", filter: "decline", value: "Петрова Анна Сергеевна", args: []string{"дательный"}, want: "Петровой Анне Сергеевне"},
+		{name: "decline творительный", filter: "decline", value: "Сидоров Олег Ильич", args: []string{"instrumental"}, want: "Сидоровым Олегом Ильичем"},
+		{name: "decline несклоняемая фамилия", filter: "decline", value: "Шевченко Андрей Петрович", args: []string{"genitive"}, want: "Шевченко Андрея Петровича"},
+		{name: "decline инициалы", filter: "decline", value: "Иванов И. И.", args: []string{"dative"}, want: "Иванову И. И."},
+		{name: "decline именительный", filter: "decline", value: "Иванов Иван", args: []string{"nominative"}, want: "Иванов Иван"},
+		{name: "decline без падежа", filter: "decline", value: "Иванов Иван", wantErr: true},
+		{name: "decline неизвестный падеж", filter: "decline", value: "Иванов Иван", args: []string{"vocative"}, wantErr: true},
+
+		{name: "initials", filter: "initials", value: "Иванов Иван Иванович", want: "Иванов И. И."},
+		{name: "initials только фамилия", filter: "initials", value: "Иванов", want: "Иванов"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := formatters[tt.filter](tt.value, tt.args)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("%s(%q, %q): ожидалась ошибка, получено %q", tt.filter, tt.value, tt.args, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%s(%q, %q): %v", tt.filter, tt.value, tt.args, err)
+			}
+			if got != tt.want {
+				t.Errorf("%s(%q, %q) = %q, ожидалось %q", tt.filter, tt.value, tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNumberToWordsGender(t *testing.T) {
+	tests := []struct {
+		n        uint64
+		feminine bool
+		want     string
+	}{
+		{1, false, "один"},
+		{1, true, "одна"},
+		{2, false, "два"},
+		{2, true, "две"},
+		{22, true, "двадцать две"},
+		{1002, true, "одна тысяча две"},
+		{1001001, false, "один миллион одна тысяча один"},
+	}
+	for _, tt := range tests {
+		if got := NumberToWords(tt.n, tt.feminine); got != tt.want {
+			t.Errorf("NumberToWords(%d, %v) = %q, ожидалось %q", tt.n, tt.feminine, got, tt.want)
+		}
+	}
+}
+
+func TestPlural(t *testing.T) {
+	tests := map[uint64]string{
+		0: "рублей", 1: "рубль", 2: "рубля", 4: "рубля", 5: "рублей",
+		11: "рублей", 12: "рублей", 14: "рублей", 21: "рубль", 22: "рубля",
+		101: "рубль", 111: "рублей", 1000: "рублей",
+	}
+	for n, want := range tests {
+		if got := plural(n, "рубль", "рубля", "рублей"); got != want {
+			t.Errorf("plural(%d) = %q, ожидалось %q", n, got, want)
+		}
+	}
+}
+
+func TestApplyFiltersKeepsValueOnError(t *testing.T) {
+	name, filters, err := parseExpression(`sum | money_words | upper`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "sum" {
+		t.Fatalf("имя поля %q, ожидалось sum", name)
+	}
+	if got := applyFilters("abc", filters); got != "ABC" {
+		t.Errorf("applyFilters = %q, ожидалось ABC", got)
+	}
+}
@@ -0,0 +1,362 @@
+// Package render подставляет значения полей в HTML-контент шаблонов.
+//
+// Поддерживаемый синтаксис:
+//
+//	{{name}}                          — значение поля
+//	{{name | money_words}}            — значение через конвейер форматтеров
+//	{{date | date:"long"}}            — форматтер с аргументом
+//	{{#if name}}…{{else}}…{{/if}}     — условная секция
+//	{{#each items}}…{{/each}}         — повтор секции для каждого элемента списка
+//
+// Внутри {{#each}} доступны поля текущего элемента, {{this}} (сам элемент),
+// {{@index}} (номер с нуля) и {{@number}} (номер с единицы).
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Values — значения полей для подстановки. Значение может быть строкой,
+// числом, bool, списком ([]interface{}) или вложенным объектом (map[string]interface{}).
+type Values map[string]interface{}
+
+// tagRe находит все {{…}} (пробелы внутри скобок допускаются)
+var tagRe = regexp.MustCompile(`{{\s*([^{}]+?)\s*}}`)
+
+// standaloneRe находит блочные теги, которые редактор обернул в отдельный абзац
+// или строку таблицы, чтобы при рендеринге не оставались пустые <p></p> и <tr></tr>
+var standaloneRe = regexp.MustCompile(
+	`<p[^>]*>\s*(?:<span[^>]*>\s*)?({{\s*(?:[#/](?:if|each)\b[^{}]*|else)\s*}})\s*(?:</span>\s*)?</p>` +
+		`|<tr[^>]*>\s*<td[^>]*>\s*(?:<p[^>]*>\s*)?(?:<span[^>]*>\s*)?({{\s*(?:[#/](?:if|each)\b[^{}]*|else)\s*}})\s*(?:</span>\s*)?(?:</p>\s*)?</td>\s*</tr>`,
+)
+
+type nodeKind int
+
+const (
+	textNode nodeKind = iota
+	fieldNode
+	ifNode
+	eachNode
+)
+
+type node struct {
+	kind     nodeKind
+	text     string // текст для textNode
+	name     string // имя поля для fieldNode / ifNode / eachNode
+	filters  []filterCall
+	children []node
+	elseBody []node // ветка {{else}} для ifNode
+	pos      int
+}
+
+// Template — разобранный шаблон, готовый к многократному выполнению
+type Template struct {
+	nodes []node
+}
+
+// ParseError описывает синтаксическую ошибку в шаблоне
+type ParseError struct {
+	Line int
+	Tag  string
+	Msg  string
+}
+
+func (e *ParseError) Error() string {
+	if e.Tag == "" {
+		return fmt.Sprintf("строка %d: %s", e.Line, e.Msg)
+	}
+	return fmt.Sprintf("строка %d: %s: %s", e.Line, e.Tag, e.Msg)
+}
+
+// Parse разбирает шаблон и проверяет парность блоков {{#if}} и {{#each}}
+func Parse(content string) (*Template, error) {
+	content = unwrapStandalone(content)
+
+	type frame struct {
+		n      node
+		inElse bool
+	}
+	root := &frame{}
+	stack := []*frame{root}
+
+	appendNode := func(n node) {
+		top := stack[len(stack)-1]
+		if top.inElse {
+			top.n.elseBody = append(top.n.elseBody, n)
+		} else {
+			top.n.children = append(top.n.children, n)
+		}
+	}
+	errorAt := func(pos int, tag, msg string) error {
+		return &ParseError{Line: strings.Count(content[:pos], "\n") + 1, Tag: tag, Msg: msg}
+	}
+
+	last := 0
+	for _, loc := range tagRe.FindAllStringSubmatchIndex(content, -1) {
+		if loc[0] > last {
+			appendNode(node{kind: textNode, text: content[last:loc[0]]})
+		}
+		last = loc[1]
+
+		raw := content[loc[0]:loc[1]]
+		// редактор может экранировать кавычки в аргументах форматтеров: date:&quot;long&quot;
+		expr := strings.TrimSpace(html.UnescapeString(content[loc[2]:loc[3]]))
+
+		switch {
+		case strings.HasPrefix(expr, "#"):
+			keyword, name := splitKeyword(expr[1:])
+			if name == "" {
+				return nil, errorAt(loc[0], raw, "не указано имя поля")
+			}
+			switch keyword {
+			case "if":
+				stack = append(stack, &frame{n: node{kind: ifNode, name: name, pos: loc[0]}})
+			case "each":
+				stack = append(stack, &frame{n: node{kind: eachNode, name: name, pos: loc[0]}})
+			default:
+				return nil, errorAt(loc[0], raw, "неизвестный блок")
+			}
+
+		case strings.HasPrefix(expr, "/"):
+			keyword := strings.TrimSpace(expr[1:])
+			if len(stack) == 1 {
+				return nil, errorAt(loc[0], raw, "закрывающий тег без открывающего")
+			}
+			top := stack[len(stack)-1]
+			if keyword != blockKeyword(top.n.kind) {
+				return nil, errorAt(loc[0], raw, fmt.Sprintf("ожидался {{/%s}}", blockKeyword(top.n.kind)))
+			}
+			stack = stack[:len(stack)-1]
+			appendNode(top.n)
+
+		case expr == "else":
+			top := stack[len(stack)-1]
+			if top.n.kind != ifNode || top.inElse {
+				return nil, errorAt(loc[0], raw, "{{else}} допустим только внутри {{#if}}")
+			}
+			top.inElse = true
+
+		default:
+			name, filters, err := parseExpression(expr)
+			if err != nil {
+				return nil, errorAt(loc[0], raw, err.Error())
+			}
+			appendNode(node{kind: fieldNode, name: name, filters: filters, pos: loc[0]})
+		}
+	}
+
+	if len(stack) > 1 {
+		top := stack[len(stack)-1]
+		return nil, errorAt(top.n.pos, fmt.Sprintf("{{#%s %s}}", blockKeyword(top.n.kind), top.n.name), "блок не закрыт")
+	}
+	if last < len(content) {
+		appendNode(node{kind: textNode, text: content[last:]})
+	}
+
+	return &Template{nodes: root.n.children}, nil
+}
+
+// Execute выполняет шаблон. Значения экранируются как HTML;
+// отсутствующие поля заменяются пустой строкой.
+func (t *Template) Execute(values Values) string {
+	var sb strings.Builder
+	execNodes(&sb, t.nodes, []interface{}{map[string]interface{}(values)}, html.EscapeString)
+	return sb.String()
+}
+
+// ExecuteText выполняет шаблон без экранирования значений — для текста,
+// который экранирует получатель (например, XML документа Word)
+func (t *Template) ExecuteText(values Values) string {
+	var sb strings.Builder
+	execNodes(&sb, t.nodes, []interface{}{map[string]interface{}(values)}, func(s string) string { return s })
+	return sb.String()
+}
+
+// Execute разбирает content и подставляет в него values
+func Execute(content string, values Values) (string, error) {
+	t, err := Parse(content)
+	if err != nil {
+		return "", err
+	}
+	return t.Execute(values), nil
+}
+
+// ExecuteText разбирает content и подставляет в него values без HTML-экранирования
+func ExecuteText(content string, values Values) (string, error) {
+	t, err := Parse(content)
+	if err != nil {
+		return "", err
+	}
+	return t.ExecuteText(values), nil
+}
+
+// Fields возвращает имена всех полей, упомянутых в шаблоне (включая условия
+// и списки блоков), в порядке первого появления. Служебные имена пропускаются.
+func Fields(content string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, m := range tagRe.FindAllStringSubmatch(content, -1) {
+		expr := strings.TrimSpace(html.UnescapeString(m[1]))
+		var name string
+		switch {
+		case strings.HasPrefix(expr, "#"):
+			_, name = splitKeyword(expr[1:])
+		case strings.HasPrefix(expr, "/"), expr == "else":
+			continue
+		default:
+			name = strings.TrimSpace(splitOutsideQuotes(expr, '|')[0])
+		}
+		if name == "" || name == "this" || strings.HasPrefix(name, "@") || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// DecodeValue превращает строковое значение из document_data в значение для шаблона:
+// JSON-массивы и объекты разворачиваются в списки и объекты, остальное остаётся строкой
+func DecodeValue(raw string) interface{} {
+	trimmed := strings.TrimSpace(raw)
+	if strings.HasPrefix(trimmed, "[") || strings.HasPrefix(trimmed, "{") {
+		var v interface{}
+		if err := json.Unmarshal([]byte(trimmed), &v); err == nil {
+			return v
+		}
+	}
+	return raw
+}
+
+func execNodes(sb *strings.Builder, nodes []node, scopes []interface{}, escape func(string) string) {
+	for _, n := range nodes {
+		switch n.kind {
+		case textNode:
+			sb.WriteString(n.text)
+
+		case fieldNode:
+			value := toString(lookup(scopes, n.name))
+			sb.WriteString(escape(applyFilters(value, n.filters)))
+
+		case ifNode:
+			if truthy(lookup(scopes, n.name)) {
+				execNodes(sb, n.children, scopes, escape)
+			} else {
+				execNodes(sb, n.elseBody, scopes, escape)
+			}
+
+		case eachNode:
+			items, _ := lookup(scopes, n.name).([]interface{})
+			for i, item := range items {
+				meta := map[string]interface{}{
+					"this":    item,
+					"@index":  i,
+					"@number": i + 1,
+				}
+				execNodes(sb, n.children, append(scopes, meta, item), escape)
+			}
+		}
+	}
+}
+
+// lookup ищет поле от самой внутренней области видимости к внешней.
+// Поддерживается доступ к вложенным полям через точку: {{client.name}}.
+func lookup(scopes []interface{}, name string) interface{} {
+	parts := strings.Split(name, ".")
+	for i := len(scopes) - 1; i >= 0; i-- {
+		m, ok := scopes[i].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		v, ok := m[parts[0]]
+		if !ok {
+			continue
+		}
+		for _, p := range parts[1:] {
+			nested, ok := v.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+			v = nested[p]
+		}
+		return v
+	}
+	return nil
+}
+
+func truthy(v interface{}) bool {
+	switch val := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return val
+	case string:
+		s := strings.TrimSpace(strings.ToLower(val))
+		return s != "" && s != "false" && s != "0"
+	case float64:
+		return val != 0
+	case int:
+		return val != 0
+	case []interface{}:
+		return len(val) > 0
+	case map[string]interface{}:
+		return len(val) > 0
+	}
+	return true
+}
+
+func toString(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(val)
+	case bool:
+		if val {
+			return "да"
+		}
+		return "нет"
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+func splitKeyword(expr string) (keyword, name string) {
+	fields := strings.Fields(expr)
+	if len(fields) == 0 {
+		return "", ""
+	}
+	return fields[0], strings.TrimSpace(strings.TrimPrefix(expr, fields[0]))
+}
+
+func blockKeyword(kind nodeKind) string {
+	switch kind {
+	case ifNode:
+		return "if"
+	case eachNode:
+		return "each"
+	}
+	return ""
+}
+
+func unwrapStandalone(content string) string {
+	return standaloneRe.ReplaceAllStringFunc(content, func(m string) string {
+		sub := standaloneRe.FindStringSubmatch(m)
+		if sub[1] != "" {
+			return sub[1]
+		}
+		return sub[2]
+	})
+}
@@ -0,0 +1,110 @@
+package render
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExecute(t *testing.T) {
+	items := []interface{}{
+		map[string]interface{}{"name": "Стол", "qty": "2"},
+		map[string]interface{}{"name": "Стул", "qty": "6"},
+	}
+	tests := []struct {
+		name    string
+		content string
+		values  Values
+		want    string
+	}{
+		{name: "поле", content: "<p>{{ client }}</p>", values: Values{"client": "ООО Ромашка"}, want: "<p>ООО Ромашка</p>"},
+		{name: "отсутствующее поле", content: "<p>[{{client}}]</p>", want: "<p>[]</p>"},
+		{name: "экранирование", content: "{{client}}", values: Values{"client": `<b>"Лютик"</b>`}, want: "&lt;b&gt;&#34;Лютик&#34;&lt;/b&gt;"},
+		{name: "вложенное поле", content: "{{client.inn}}", values: Values{"client": map[string]interface{}{"inn": "7700000000"}}, want: "7700000000"},
+		{name: "фильтр", content: "{{sum | money}}", values: Values{"sum": "1500"}, want: "1 500,00"},
+		{name: "экранированные кавычки аргумента", content: "{{d | date:&quot;iso&quot;}}", values: Values{"d": "05.03.2026"}, want: "2026-03-05"},
+
+		{name: "if истина", content: "{{#if vip}}VIP{{/if}}", values: Values{"vip": "да"}, want: "VIP"},
+		{name: "if ложь", content: "{{#if vip}}VIP{{/if}}", values: Values{"vip": "false"}, want: ""},
+		{name: "if пустой список", content: "{{#if items}}есть{{else}}нет{{/if}}", values: Values{"items": []interface{}{}}, want: "нет"},
+		{name: "if else", content: "{{#if vip}}VIP{{else}}обычный{{/if}}", want: "обычный"},
+		{name: "if в отдельных абзацах", content: "<p>{{#if vip}}</p><p>VIP</p><p>{{/if}}</p>", values: Values{"vip": true}, want: "<p>VIP</p>"},
+
+		{name: "each", content: "{{#each items}}{{@number}}. {{name}} × {{qty}}; {{/each}}", values: Values{"items": items}, want: "1. Стол × 2; 2. Стул × 6; "},
+		{name: "each this и @index", content: "{{#each tags}}{{@index}}={{this}} {{/each}}", values: Values{"tags": []interface{}{"a", "b"}}, want: "0=a 1=b "},
+		{name: "each видит внешние поля", content: "{{#each items}}{{name}}/{{client}} {{/each}}", values: Values{"items": items, "client": "Ромашка"}, want: "Стол/Ромашка Стул/Ромашка "},
+		{name: "each не список", content: "[{{#each items}}x{{/each}}]", values: Values{"items": "строка"}, want: "[]"},
+		{name: "each строками таблицы", content: "<table><tr><td>{{#each items}}</td></tr><tr><td>{{name}}</td></tr><tr><td>{{/each}}</td></tr></table>", values: Values{"items": items}, want: "<table><tr><td>Стол</td></tr><tr><td>Стул</td></tr></table>"},
+
+		{name: "if внутри each", content: "{{#each items}}{{#if qty}}{{name}}{{else}}-{{/if}},{{/each}}", values: Values{"items": []interface{}{
+			map[string]interface{}{"name": "Стол", "qty": "1"},
+			map[string]interface{}{"name": "Стул", "qty": ""},
+		}}, want: "Стол,-,"},
+		{name: "each внутри if", content: "{{#if show}}{{#each tags}}{{this}}{{/each}}{{/if}}", values: Values{"show": true, "tags": []interface{}{"x", "y"}}, want: "xy"},
+		{name: "вложенные each", content: "{{#each groups}}{{title}}:{{#each items}}{{this}}{{/each}};{{/each}}", values: Values{"groups": []interface{}{
+			map[string]interface{}{"title": "A", "items": []interface{}{"1", "2"}},
+			map[string]interface{}{"title": "B", "items": []interface{}{"3"}},
+		}}, want: "A:12;B:3;"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Execute(tt.content, tt.values)
+			if err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Execute(%q) = %q, ожидалось %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		line    int
+		tag     string
+		msg     string
+	}{
+		{name: "незакрытый if", content: "<p>a</p>\n{{#if vip}}\nтекст", line: 2, tag: "{{#if vip}}", msg: "блок не закрыт"},
+		{name: "незакрытый each внутри if", content: "{{#if a}}\n{{#each items}}{{/if}}", line: 2, tag: "{{/if}}", msg: "ожидался {{/each}}"},
+		{name: "незакрытый вложенный each", content: "{{#if a}}{{/if}}\n\n{{#each items}}", line: 3, tag: "{{#each items}}", msg: "блок не закрыт"},
+		{name: "несоответствующий закрывающий", content: "{{#if vip}}x{{/each}}", line: 1, tag: "{{/each}}", msg: "ожидался {{/if}}"},
+		{name: "закрывающий без открывающего", content: "x\n{{/if}}", line: 2, tag: "{{/if}}", msg: "закрывающий тег без открывающего"},
+		{name: "else вне if", content: "{{#each items}}{{else}}{{/each}}", line: 1, tag: "{{else}}", msg: "{{else}} допустим только внутри {{#if}}"},
+		{name: "повторный else", content: "{{#if a}}1{{else}}2{{else}}3{{/if}}", line: 1, tag: "{{else}}", msg: "{{else}} допустим только внутри {{#if}}"},
+		{name: "блок без имени", content: "{{#if }}x{{/if}}", line: 1, msg: "не указано имя поля"},
+		{name: "неизвестный блок", content: "{{#with client}}x{{/with}}", line: 1, tag: "{{#with client}}", msg: "неизвестный блок"},
+		{name: "неизвестный форматтер", content: "\n\n{{sum | nosuch}}", line: 3, tag: "{{sum | nosuch}}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.content)
+			var pe *ParseError
+			if !errors.As(err, &pe) {
+				t.Fatalf("Parse(%q): %v, ожидалась ParseError", tt.content, err)
+			}
+			if pe.Line != tt.line {
+				t.Errorf("строка %d, ожидалась %d (%v)", pe.Line, tt.line, pe)
+			}
+			if tt.tag != "" && pe.Tag != tt.tag {
+				t.Errorf("тег %q, ожидался %q", pe.Tag, tt.tag)
+			}
+			if tt.msg != "" && !strings.Contains(pe.Msg, tt.msg) {
+				t.Errorf("сообщение %q, ожидалось %q", pe.Msg, tt.msg)
+			}
+		})
+	}
+}
+
+func TestFields(t *testing.T) {
+	content := `{{client}} {{#if vip}}{{discount | number}}{{/if}} {{#each items}}{{name}} {{this}} {{@index}}{{/each}} {{client}}`
+	want := []string{"client", "vip", "discount", "items", "name"}
+	if got := Fields(content); !reflect.DeepEqual(got, want) {
+		t.Errorf("Fields = %v, ожидалось %v", got, want)
+	}
+}
@@ -0,0 +1,181 @@
+package richtext
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// StyleRule — CSS-правило шаблона (строка template_styles)
+type StyleRule struct {
+	Selector     string
+	Scope        string // "global" или "inline"
+	Declarations map[string]string
+}
+
+// ParseDeclarations разбирает содержимое атрибута style: "color: red; font-size: 14px"
+func ParseDeclarations(style string) map[string]string {
+	decl := make(map[string]string)
+	for _, part := range strings.Split(style, ";") {
+		name, value, ok := strings.Cut(part, ":")
+		if !ok {
+			continue
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		value = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), "!important"))
+		if name != "" && value != "" {
+			decl[name] = value
+		}
+	}
+	return decl
+}
+
+var lengthRe = regexp.MustCompile(`^(-?[\d.]+)\s*(px|pt|em|rem|cm|mm|in|%)?$`)
+
+// ParseLength переводит CSS-длину в пункты. Для процентов возвращает percent=true и само число.
+func ParseLength(value string) (v float64, percent bool, ok bool) {
+	m := lengthRe.FindStringSubmatch(strings.TrimSpace(strings.ToLower(value)))
+	if m == nil {
+		return 0, false, false
+	}
+	n, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, false, false
+	}
+	switch m[2] {
+	case "", "px":
+		return n * 0.75, false, true
+	case "pt":
+		return n, false, true
+	case "em", "rem":
+		return n * 12, false, true
+	case "cm":
+		return n * 28.3465, false, true
+	case "mm":
+		return n * 2.83465, false, true
+	case "in":
+		return n * 72, false, true
+	case "%":
+		return n, true, true
+	}
+	return 0, false, false
+}
+
+var namedColors = map[string]string{
+	"black":  "000000",
+	"white":  "FFFFFF",
+	"red":    "FF0000",
+	"green":  "008000",
+	"blue":   "0000FF",
+	"yellow": "FFFF00",
+	"gray":   "808080",
+	"grey":   "808080",
+	"silver": "C0C0C0",
+	"maroon": "800000",
+	"navy":   "000080",
+	"orange": "FFA500",
+	"purple": "800080",
+}
+
+var rgbRe = regexp.MustCompile(`^rgba?\(\s*(\d+)\s*,\s*(\d+)\s*,\s*(\d+)`)
+
+// ParseColor переводит CSS-цвет в шестнадцатеричную строку вида "FF0000"
+func ParseColor(value string) (string, bool) {
+	v := strings.TrimSpace(strings.ToLower(value))
+	if hex, ok := namedColors[v]; ok {
+		return hex, true
+	}
+	if strings.HasPrefix(v, "#") {
+		h := v[1:]
+		if len(h) == 3 {
+			h = string([]byte{h[0], h[0], h[1], h[1], h[2], h[2]})
+		}
+		if len(h) == 6 {
+			if _, err := strconv.ParseUint(h, 16, 32); err == nil {
+				return strings.ToUpper(h), true
+			}
+		}
+		return "", false
+	}
+	if m := rgbRe.FindStringSubmatch(v); m != nil {
+		var rgb [3]int
+		for i := range rgb {
+			rgb[i], _ = strconv.Atoi(m[i+1])
+			if rgb[i] > 255 {
+				rgb[i] = 255
+			}
+		}
+		return fmt.Sprintf("%02X%02X%02X", rgb[0], rgb[1], rgb[2]), true
+	}
+	return "", false
+}
+
+// styleSheet сопоставляет правила template_styles с элементами HTML
+type styleSheet struct {
+	rules []StyleRule
+}
+
+// simpleSelectorRe разбирает простые селекторы: tag, .class, [attr="v"] и их сочетания
+var simpleSelectorRe = regexp.MustCompile(`^([a-z0-9*]*)((?:\.[\w-]+)*)((?:\[[\w-]+(?:=["']?[^"'\]]*["']?)?\])*)$`)
+
+var attrSelectorRe = regexp.MustCompile(`\[([\w-]+)(?:=["']?([^"'\]]*)["']?)?\]`)
+
+// match возвращает объявления всех правил указанной области, подходящих к элементу,
+// в порядке следования правил (более поздние перекрывают ранние)
+func (s *styleSheet) match(n *html.Node, scope string) map[string]string {
+	decl := make(map[string]string)
+	if s == nil {
+		return decl
+	}
+	for _, r := range s.rules {
+		if r.Scope != scope {
+			continue
+		}
+		for _, sel := range strings.Split(r.Selector, ",") {
+			if selectorMatches(strings.TrimSpace(sel), n) {
+				for k, v := range r.Declarations {
+					decl[k] = v
+				}
+				break
+			}
+		}
+	}
+	return decl
+}
+
+func selectorMatches(sel string, n *html.Node) bool {
+	m := simpleSelectorRe.FindStringSubmatch(strings.ToLower(sel))
+	if m == nil || (m[1] == "" && m[2] == "" && m[3] == "") {
+		return false
+	}
+	if m[1] != "" && m[1] != "*" && m[1] != n.Data {
+		return false
+	}
+	if m[2] != "" {
+		classes := " " + attr(n, "class") + " "
+		for _, cls := range strings.Split(m[2][1:], ".") {
+			if !strings.Contains(classes, " "+cls+" ") {
+				return false
+			}
+		}
+	}
+	for _, a := range attrSelectorRe.FindAllStringSubmatch(m[3], -1) {
+		val, exists := attrValue(n, a[1])
+		if !exists || (a[2] != "" && !strings.EqualFold(val, a[2])) {
+			return false
+		}
+	}
+	return true
+}
+
+func attrValue(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,288 @@
+// Package richtext разбирает HTML из редактора в промежуточную модель документа
+// (абзацы, заголовки, списки и форматированные фрагменты текста), которую затем
+// переводят в конкретный формат экспортёры (docx и другие).
+package richtext
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Document — разобранный документ
+type Document struct {
+	Blocks []Block
+}
+
+// Block — абзац, заголовок, элемент списка или таблица
+type Block struct {
+	Heading int       // 1–6 для <h1>–<h6>, 0 — обычный абзац
+	List    *ListInfo // не nil для элементов списка
+	Table   *Table    // не nil, если блок — таблица (тогда Runs пуст)
+	Format  ParagraphFormat
+	Runs    []Run
+}
+
+// ListInfo описывает принадлежность абзаца к списку
+type ListInfo struct {
+	ID      int  // номер списка в документе — нумерация начинается заново для каждого <ol>
+	Ordered bool // <ol> — нумерованный, <ul> — маркированный
+	Level   int  // уровень вложенности, начиная с 0
+}
+
+// Run — непрерывный фрагмент текста с одинаковым форматированием
+type Run struct {
+	Text   string
+	Break  bool // перевод строки <br>
+	Format RunFormat
+}
+
+// RunFormat — форматирование фрагмента
+type RunFormat struct {
+	Bold          bool
+	Italic        bool
+	Underline     bool
+	Strike        bool
+	FontFamily    string
+	FontSizePt    float64
+	Color         string  // шестнадцатеричный цвет текста
+	Background    string  // шестнадцатеричный цвет фона
+	LetterSpacing float64 // межбуквенный интервал в пунктах
+	StyleID       string  // data-style-id ближайшего <span>
+}
+
+var whitespaceRe = regexp.MustCompile(`\s+`)
+
+// Parse разбирает HTML-фрагмент. rules — стили шаблона из template_styles.
+func Parse(htmlStr string, rules []StyleRule) (*Document, error) {
+	root, err := html.Parse(strings.NewReader(htmlStr))
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{doc: &Document{}, sheet: &styleSheet{rules: rules}}
+	p.walk(root, RunFormat{})
+	p.closeBlock()
+	return p.doc, nil
+}
+
+type listContext struct {
+	id      int
+	ordered bool
+}
+
+type parser struct {
+	doc      *Document
+	cur      *Block
+	explicit bool // текущий блок открыт явным тегом (<p></p> сохраняется даже пустым)
+	lists    []listContext
+	listSeq  int
+	sheet    *styleSheet
+	para     ParagraphFormat // наследуемое оформление абзаца от родительских элементов
+}
+
+func (p *parser) walk(n *html.Node, f RunFormat) {
+	switch n.Type {
+	case html.TextNode:
+		p.appendText(n.Data, f)
+		return
+	case html.ElementNode:
+	default:
+		p.walkChildren(n, f)
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.Script, atom.Style, atom.Head, atom.Title:
+		return
+	case atom.B, atom.Strong:
+		f.Bold = true
+	case atom.I, atom.Em:
+		f.Italic = true
+	case atom.U, atom.Ins:
+		f.Underline = true
+	case atom.S, atom.Strike, atom.Del:
+		f.Strike = true
+	case atom.Span:
+		if id := attr(n, "data-style-id"); id != "" {
+			f.StyleID = id
+		}
+	}
+
+	// CSS шаблона и атрибут style перекрывают оформление, заданное самим тегом.
+	// Фон блочного элемента не переносится на текст — только фон строчных (<span>).
+	style := p.elementStyle(n)
+	bg := f.Background
+	f = applyRunStyle(f, style)
+	if isBlockElement(n.DataAtom) {
+		f.Background = bg
+	}
+	inherited := p.para
+	p.para = applyInheritedParagraphStyle(p.para, style)
+	defer func() { p.para = inherited }()
+
+	switch n.DataAtom {
+	case atom.Br:
+		p.ensureBlock()
+		p.cur.Runs = append(p.cur.Runs, Run{Break: true, Format: f})
+
+	case atom.P, atom.Div, atom.Blockquote, atom.Pre,
+		atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		b := Block{
+			Heading: headingLevel(n.DataAtom),
+			Format:  applyBoxStyle(p.para, style),
+		}
+		p.openBlock(b)
+		p.walkChildren(n, f)
+		p.closeBlock()
+
+	case atom.Table:
+		p.closeBlock()
+		p.doc.Blocks = append(p.doc.Blocks, Block{Table: p.parseTable(n, f)})
+
+	case atom.Ul, atom.Ol:
+		p.closeBlock()
+		p.listSeq++
+		p.lists = append(p.lists, listContext{id: p.listSeq, ordered: n.DataAtom == atom.Ol})
+		p.walkChildren(n, f)
+		p.lists = p.lists[:len(p.lists)-1]
+		p.closeBlock()
+
+	case atom.Li:
+		b := Block{Format: applyBoxStyle(p.para, style)}
+		if len(p.lists) > 0 {
+			ctx := p.lists[len(p.lists)-1]
+			b.List = &ListInfo{ID: ctx.id, Ordered: ctx.ordered, Level: len(p.lists) - 1}
+		}
+		p.closeBlock()
+		p.openBlock(b)
+		p.walkChildren(n, f)
+		p.closeBlock()
+
+	default:
+		p.walkChildren(n, f)
+	}
+}
+
+func (p *parser) walkChildren(n *html.Node, f RunFormat) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		p.walk(c, f)
+	}
+}
+
+// openBlock начинает новый блок. <p> внутри пустого <li> не создаёт отдельный абзац,
+// а дополняет абзац элемента списка; пустой внешний блок (<div><p>…</p></div>)
+// заменяется вложенным, чтобы не оставлять лишних пустых абзацев.
+func (p *parser) openBlock(b Block) {
+	if p.cur != nil && len(p.cur.Runs) == 0 && b.List == nil {
+		if p.cur.List != nil {
+			p.cur.Heading = b.Heading
+			p.cur.Format = b.Format
+			p.explicit = true
+			return
+		}
+		p.cur = nil
+	}
+	p.closeBlock()
+	p.cur = &b
+	p.explicit = true
+}
+
+// ensureBlock открывает неявный абзац для текста вне блочных элементов
+func (p *parser) ensureBlock() {
+	if p.cur == nil {
+		p.cur = &Block{Format: p.para}
+		p.explicit = false
+	}
+}
+
+func (p *parser) closeBlock() {
+	if p.cur == nil {
+		return
+	}
+	b := p.cur
+	p.cur = nil
+
+	// убираем пробелы на границах абзаца
+	for len(b.Runs) > 0 && !b.Runs[0].Break {
+		b.Runs[0].Text = strings.TrimLeft(b.Runs[0].Text, " ")
+		if b.Runs[0].Text != "" {
+			break
+		}
+		b.Runs = b.Runs[1:]
+	}
+	for len(b.Runs) > 0 && !b.Runs[len(b.Runs)-1].Break {
+		last := &b.Runs[len(b.Runs)-1]
+		last.Text = strings.TrimRight(last.Text, " ")
+		if last.Text != "" {
+			break
+		}
+		b.Runs = b.Runs[:len(b.Runs)-1]
+	}
+
+	if len(b.Runs) == 0 && !p.explicit {
+		return
+	}
+	p.doc.Blocks = append(p.doc.Blocks, *b)
+}
+
+func (p *parser) appendText(text string, f RunFormat) {
+	text = whitespaceRe.ReplaceAllString(text, " ")
+	if p.cur == nil && strings.TrimSpace(text) == "" {
+		return
+	}
+	p.ensureBlock()
+
+	// схлопываем пробелы на стыке фрагментов
+	if n := len(p.cur.Runs); n == 0 || p.cur.Runs[n-1].Break || strings.HasSuffix(p.cur.Runs[n-1].Text, " ") {
+		text = strings.TrimLeft(text, " ")
+	}
+	if text == "" {
+		return
+	}
+
+	if n := len(p.cur.Runs); n > 0 && !p.cur.Runs[n-1].Break && p.cur.Runs[n-1].Format == f {
+		p.cur.Runs[n-1].Text += text
+		return
+	}
+	p.cur.Runs = append(p.cur.Runs, Run{Text: text, Format: f})
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func isBlockElement(a atom.Atom) bool {
+	switch a {
+	case atom.Html, atom.Body, atom.P, atom.Div, atom.Blockquote, atom.Pre,
+		atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6,
+		atom.Table, atom.Ul, atom.Ol, atom.Li:
+		return true
+	}
+	return false
+}
+
+func headingLevel(a atom.Atom) int {
+	switch a {
+	case atom.H1:
+		return 1
+	case atom.H2:
+		return 2
+	case atom.H3:
+		return 3
+	case atom.H4:
+		return 4
+	case atom.H5:
+		return 5
+	case atom.H6:
+		return 6
+	}
+	return 0
+}
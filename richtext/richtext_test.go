@@ -0,0 +1,152 @@
+package richtext
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "перезаписать golden-файлы в testdata")
+
+// TestParseGolden сравнивает разобранную модель каждого testdata/*.html
+// с testdata/*.json. Обновить эталоны: go test ./richtext -update
+func TestParseGolden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "*.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) == 0 {
+		t.Fatal("нет входных файлов в testdata")
+	}
+
+	for _, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), ".html")
+		t.Run(name, func(t *testing.T) {
+			src, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			doc, err := Parse(string(src), nil)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			got, err := json.MarshalIndent(doc, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+
+			golden := filepath.Join("testdata", name+".json")
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (создать эталон: go test ./richtext -update)", err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("модель %s отличается от %s:\n%s", input, golden, got)
+			}
+		})
+	}
+}
+
+// TestParseListNumbering проверяет, что каждый <ol> получает свою нумерацию,
+// а вложенные списки — следующий уровень
+func TestParseListNumbering(t *testing.T) {
+	doc, err := Parse(`<ol><li>a<ol><li>b</li></ol></li></ol><ol><li>c</li></ol>`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Blocks) != 3 {
+		t.Fatalf("блоков %d, ожидалось 3", len(doc.Blocks))
+	}
+	a, b, c := doc.Blocks[0].List, doc.Blocks[1].List, doc.Blocks[2].List
+	if a == nil || b == nil || c == nil {
+		t.Fatal("все блоки должны быть элементами списка")
+	}
+	if a.Level != 0 || b.Level != 1 || c.Level != 0 {
+		t.Errorf("уровни %d/%d/%d, ожидалось 0/1/0", a.Level, b.Level, c.Level)
+	}
+	if a.ID == b.ID || a.ID == c.ID {
+		t.Errorf("списки должны нумероваться отдельно: ID %d, %d, %d", a.ID, b.ID, c.ID)
+	}
+}
+
+// TestParseTableSpansClamped проверяет, что colspan и rowspan не выходят
+// за реальные строки и колонки таблицы
+func TestParseTableSpansClamped(t *testing.T) {
+	tests := []struct {
+		name  string
+		html  string
+		spans [][2]int // colspan и rowspan ячеек по порядку
+		cols  int
+	}{
+		{
+			name:  "огромные значения",
+			html:  `<table><tr><td colspan="100000000" rowspan="100000000">a</td><td>b</td></tr><tr><td>c</td></tr></table>`,
+			spans: [][2]int{{2, 2}, {1, 1}, {1, 1}},
+			cols:  3,
+		},
+		{
+			name:  "colspan шире таблицы",
+			html:  `<table><tr><td colspan="5">a</td></tr><tr><td>b</td><td>c</td><td>d</td></tr></table>`,
+			spans: [][2]int{{3, 1}, {1, 1}, {1, 1}, {1, 1}},
+			cols:  3,
+		},
+		{
+			name:  "rowspan ниже последней строки",
+			html:  `<table><tr><td>a</td><td rowspan="3">b</td></tr><tr><td>c</td></tr></table>`,
+			spans: [][2]int{{1, 1}, {1, 2}, {1, 1}},
+			cols:  2,
+		},
+		{
+			name:  "ширины из col",
+			html:  `<table><colgroup><col span="4"></colgroup><tr><td colspan="9">a</td></tr></table>`,
+			spans: [][2]int{{4, 1}},
+			cols:  4,
+		},
+		{
+			name:  "некорректные значения",
+			html:  `<table><tr><td colspan="0" rowspan="-2">a</td><td colspan="x">b</td></tr></table>`,
+			spans: [][2]int{{1, 1}, {1, 1}},
+			cols:  2,
+		},
+		{
+			name:  "допустимые объединения не меняются",
+			html:  `<table><tr><td rowspan="2">a</td><td colspan="2">b</td></tr><tr><td>c</td><td>d</td></tr></table>`,
+			spans: [][2]int{{1, 2}, {2, 1}, {1, 1}, {1, 1}},
+			cols:  3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := Parse(tt.html, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(doc.Blocks) != 1 || doc.Blocks[0].Table == nil {
+				t.Fatalf("ожидалась одна таблица, получено %+v", doc.Blocks)
+			}
+			cells, cols := doc.Blocks[0].Table.Grid()
+			if cols != tt.cols {
+				t.Errorf("колонок %d, ожидалось %d", cols, tt.cols)
+			}
+			if len(cells) != len(tt.spans) {
+				t.Fatalf("ячеек %d, ожидалось %d", len(cells), len(tt.spans))
+			}
+			for i, pc := range cells {
+				if got := [2]int{pc.Cell.ColSpan, pc.Cell.RowSpan}; got != tt.spans[i] {
+					t.Errorf("ячейка %d: colspan/rowspan %v, ожидалось %v", i, got, tt.spans[i])
+				}
+			}
+		})
+	}
+}
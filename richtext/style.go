@@ -0,0 +1,179 @@
+package richtext
+
+import (
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// ParagraphFormat — оформление абзаца из CSS (все расстояния в пунктах)
+type ParagraphFormat struct {
+	Align        string  // left, center, right, justify
+	LineHeight   float64 // множитель межстрочного интервала (line-height: 1.5), 0 — по умолчанию
+	LineHeightPt float64 // точный межстрочный интервал (line-height: 18pt)
+	SpaceBefore  float64 // margin-top
+	SpaceAfter   float64 // margin-bottom
+	IndentLeft   float64 // margin-left
+	IndentRight  float64 // margin-right
+	FirstIndent  float64 // text-indent
+}
+
+// elementStyle собирает стиль элемента: глобальные правила шаблона, затем правила
+// по data-style-id (scope=inline), затем атрибут style — каждое следующее перекрывает предыдущее
+func (p *parser) elementStyle(n *html.Node) map[string]string {
+	decl := p.sheet.match(n, "global")
+	for k, v := range p.sheet.match(n, "inline") {
+		decl[k] = v
+	}
+	for k, v := range ParseDeclarations(attr(n, "style")) {
+		decl[k] = v
+	}
+	return decl
+}
+
+// applyRunStyle переносит в формат фрагмента свойства шрифта из CSS.
+// Свойства наследуются: формат родителя передаётся в дочерние элементы.
+func applyRunStyle(f RunFormat, style map[string]string) RunFormat {
+	if v, ok := style["font-family"]; ok {
+		f.FontFamily = firstFontFamily(v)
+	}
+	if v, pct, ok := ParseLength(style["font-size"]); ok {
+		if pct {
+			if f.FontSizePt > 0 {
+				f.FontSizePt = f.FontSizePt * v / 100
+			}
+		} else {
+			f.FontSizePt = v
+		}
+	}
+	if hex, ok := ParseColor(style["color"]); ok {
+		f.Color = hex
+	}
+	if v, ok := style["font-weight"]; ok {
+		f.Bold = isBoldWeight(v)
+	}
+	if v, ok := style["font-style"]; ok {
+		v = strings.ToLower(v)
+		f.Italic = v == "italic" || v == "oblique"
+	}
+	if v, ok := style["text-decoration"]; ok {
+		v = strings.ToLower(v)
+		f.Underline = strings.Contains(v, "underline")
+		f.Strike = strings.Contains(v, "line-through")
+	}
+	if v, ok := style["letter-spacing"]; ok {
+		if pt, pct, ok := ParseLength(v); ok && !pct {
+			f.LetterSpacing = pt
+		} else if strings.EqualFold(v, "normal") {
+			f.LetterSpacing = 0
+		}
+	}
+
+	bg := style["background-color"]
+	if bg == "" {
+		bg = style["background"]
+	}
+	if hex, ok := ParseColor(bg); ok {
+		f.Background = hex
+	}
+	return f
+}
+
+// applyInheritedParagraphStyle переносит наследуемые свойства абзаца (text-align,
+// line-height, text-indent) — они действуют и на вложенные блоки
+func applyInheritedParagraphStyle(pf ParagraphFormat, style map[string]string) ParagraphFormat {
+	if v, ok := style["text-align"]; ok {
+		pf.Align = strings.ToLower(v)
+	}
+	if v, ok := style["line-height"]; ok {
+		pf.LineHeight, pf.LineHeightPt = parseLineHeight(v)
+	}
+	if v, pct, ok := ParseLength(style["text-indent"]); ok && !pct {
+		pf.FirstIndent = v
+	}
+	return pf
+}
+
+// applyBoxStyle переносит отступы блока (margin) — они не наследуются
+func applyBoxStyle(pf ParagraphFormat, style map[string]string) ParagraphFormat {
+	top, right, bottom, left := parseMargin(style["margin"])
+	if v, ok := marginValue(style["margin-top"]); ok {
+		top = v
+	}
+	if v, ok := marginValue(style["margin-right"]); ok {
+		right = v
+	}
+	if v, ok := marginValue(style["margin-bottom"]); ok {
+		bottom = v
+	}
+	if v, ok := marginValue(style["margin-left"]); ok {
+		left = v
+	}
+	pf.SpaceBefore, pf.IndentRight, pf.SpaceAfter, pf.IndentLeft = top, right, bottom, left
+	return pf
+}
+
+// parseMargin разбирает сокращённую запись margin из 1–4 значений
+func parseMargin(value string) (top, right, bottom, left float64) {
+	var v []float64
+	for _, part := range strings.Fields(value) {
+		pt, _ := marginValue(part)
+		v = append(v, pt)
+	}
+	switch len(v) {
+	case 1:
+		return v[0], v[0], v[0], v[0]
+	case 2:
+		return v[0], v[1], v[0], v[1]
+	case 3:
+		return v[0], v[1], v[2], v[1]
+	case 4:
+		return v[0], v[1], v[2], v[3]
+	}
+	return 0, 0, 0, 0
+}
+
+func marginValue(value string) (float64, bool) {
+	v, pct, ok := ParseLength(value)
+	if !ok || pct {
+		return 0, false
+	}
+	return v, true
+}
+
+// parseLineHeight: число или проценты — множитель, длина — точное значение в пунктах
+func parseLineHeight(value string) (multiplier, pt float64) {
+	value = strings.TrimSpace(strings.ToLower(value))
+	if value == "normal" {
+		return 0, 0
+	}
+	if n, err := strconv.ParseFloat(value, 64); err == nil {
+		return n, 0
+	}
+	if v, pct, ok := ParseLength(value); ok {
+		if pct {
+			return v / 100, 0
+		}
+		return 0, v
+	}
+	return 0, 0
+}
+
+func isBoldWeight(v string) bool {
+	v = strings.ToLower(strings.TrimSpace(v))
+	switch v {
+	case "bold", "bolder":
+		return true
+	case "normal", "lighter":
+		return false
+	}
+	n, err := strconv.Atoi(v)
+	return err == nil && n >= 600
+}
+
+// firstFontFamily берёт первый шрифт из списка: "Times New Roman", serif → Times New Roman
+func firstFontFamily(v string) string {
+	first, _, _ := strings.Cut(v, ",")
+	return strings.Trim(strings.TrimSpace(first), `"'`)
+}
@@ -0,0 +1,347 @@
+package richtext
+
+import (
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Table — таблица из <table>
+type Table struct {
+	Rows         []TableRow
+	Border       float64   // толщина рамки в пунктах, 0 — без рамки
+	BorderColor  string    // шестнадцатеричный цвет рамки, пусто — авто
+	WidthPt      float64   // ширина в пунктах
+	WidthPercent float64   // ширина в процентах от ширины страницы
+	ColumnWidths []float64 // ширины колонок из <col> в пунктах (0 — не задана)
+}
+
+// TableRow — строка таблицы
+type TableRow struct {
+	Cells []TableCell
+}
+
+// TableCell — ячейка <td>/<th>
+type TableCell struct {
+	Blocks       []Block
+	Header       bool // <th>
+	ColSpan      int
+	RowSpan      int
+	Align        string // left, center, right, justify
+	VAlign       string // top, middle, bottom
+	WidthPt      float64
+	WidthPercent float64
+	Background   string // шестнадцатеричный цвет заливки
+}
+
+// CellPosition — ячейка таблицы на сетке с учётом объединений
+type CellPosition struct {
+	Cell     *TableCell
+	Row, Col int // строка и первая занятая колонка
+}
+
+// Grid раскладывает ячейки по сетке с учётом colspan и rowspan и возвращает
+// их позиции в порядке следования вместе с числом колонок
+func (t *Table) Grid() ([]CellPosition, int) {
+	var cells []CellPosition
+	busy := make(map[[2]int]bool) // позиции, занятые объединёнными ячейками (строка, колонка)
+	cols := 0
+	for r := range t.Rows {
+		col := 0
+		for i := range t.Rows[r].Cells {
+			c := &t.Rows[r].Cells[i]
+			for busy[[2]int{r, col}] {
+				col++
+			}
+			for dr := 0; dr < min(c.RowSpan, len(t.Rows)-r); dr++ {
+				for dc := 0; dc < c.ColSpan; dc++ {
+					busy[[2]int{r + dr, col + dc}] = true
+				}
+			}
+			cells = append(cells, CellPosition{Cell: c, Row: r, Col: col})
+			col += c.ColSpan
+			cols = max(cols, col)
+		}
+	}
+	return cells, cols
+}
+
+// ResolveColumnWidths возвращает ширины колонок в пунктах для таблицы шириной tableW:
+// ширины из <col> и ячеек без colspan, остаток делится поровну между остальными колонками.
+// Если заданные ширины не совпадают с шириной таблицы, они масштабируются пропорционально.
+func (t *Table) ResolveColumnWidths(tableW float64) []float64 {
+	cells, cols := t.Grid()
+	colW := make([]float64, cols)
+	copy(colW, t.ColumnWidths)
+	for _, pc := range cells {
+		if pc.Cell.ColSpan != 1 || colW[pc.Col] > 0 {
+			continue
+		}
+		switch {
+		case pc.Cell.WidthPercent > 0:
+			colW[pc.Col] = tableW * pc.Cell.WidthPercent / 100
+		case pc.Cell.WidthPt > 0:
+			colW[pc.Col] = pc.Cell.WidthPt
+		}
+	}
+
+	var fixed float64
+	free := 0
+	for _, v := range colW {
+		fixed += v
+		if v == 0 {
+			free++
+		}
+	}
+	if free > 0 && fixed < tableW {
+		for i := range colW {
+			if colW[i] == 0 {
+				colW[i] = (tableW - fixed) / float64(free)
+			}
+		}
+		return colW
+	}
+	if fixed > 0 {
+		for i := range colW {
+			colW[i] = colW[i] * tableW / fixed
+		}
+	}
+	return colW
+}
+
+func (p *parser) parseTable(n *html.Node, f RunFormat) *Table {
+	t := &Table{}
+	style := p.elementStyle(n)
+
+	if b := attr(n, "border"); b != "" && b != "0" {
+		if w, err := strconv.ParseFloat(b, 64); err == nil {
+			t.Border = w * 0.75
+		}
+	}
+	applyBorder(style, &t.Border, &t.BorderColor)
+
+	width := style["width"]
+	if width == "" {
+		width = attr(n, "width")
+	}
+	if v, pct, ok := ParseLength(width); ok {
+		if pct {
+			t.WidthPercent = v
+		} else {
+			t.WidthPt = v
+		}
+	}
+
+	var walkRows func(n *html.Node)
+	walkRows = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			switch c.DataAtom {
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				walkRows(c)
+			case atom.Colgroup:
+				walkRows(c)
+			case atom.Col:
+				w, _, _ := ParseLength(p.elementStyle(c)["width"])
+				if w == 0 {
+					w, _, _ = ParseLength(attr(c, "width"))
+				}
+				span := atoiSpan(attr(c, "span"), maxColSpan)
+				for i := 0; i < span; i++ {
+					t.ColumnWidths = append(t.ColumnWidths, w)
+				}
+			case atom.Tr:
+				t.Rows = append(t.Rows, p.parseRow(c, f, t))
+			}
+		}
+	}
+	walkRows(n)
+	t.clampSpans()
+	return t
+}
+
+// clampSpans ограничивает объединения реальными размерами таблицы: rowspan —
+// оставшимися строками, colspan — колонками, в которых начинается хотя бы одна
+// ячейка или задан <col>. Иначе colspan="1000" добавляет пустые колонки,
+// а огромный rowspan раздувает сетку в Grid.
+func (t *Table) clampSpans() {
+	limit := len(t.ColumnWidths)
+	for r := range t.Rows {
+		cells := t.Rows[r].Cells
+		limit = max(limit, len(cells))
+		for i := range cells {
+			cells[i].RowSpan = min(cells[i].RowSpan, len(t.Rows)-r)
+		}
+	}
+	for r := range t.Rows {
+		for i := range t.Rows[r].Cells {
+			t.Rows[r].Cells[i].ColSpan = min(t.Rows[r].Cells[i].ColSpan, limit)
+		}
+	}
+
+	// колонки правее последней начатой ячейки пусты — обрезаем выступающие за них объединения
+	cells, _ := t.Grid()
+	cols := len(t.ColumnWidths)
+	for _, pc := range cells {
+		cols = max(cols, pc.Col+1)
+	}
+	for _, pc := range cells {
+		pc.Cell.ColSpan = min(pc.Cell.ColSpan, cols-pc.Col)
+	}
+}
+
+func (p *parser) parseRow(tr *html.Node, f RunFormat, t *Table) TableRow {
+	var row TableRow
+	rowStyle := p.elementStyle(tr)
+
+	for c := tr.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || (c.DataAtom != atom.Td && c.DataAtom != atom.Th) {
+			continue
+		}
+
+		cell := TableCell{
+			Header:  c.DataAtom == atom.Th,
+			ColSpan: atoiSpan(attr(c, "colspan"), maxColSpan),
+			RowSpan: atoiSpan(attr(c, "rowspan"), maxRowSpan),
+		}
+
+		style := p.elementStyle(c)
+		for k, v := range rowStyle {
+			if _, ok := style[k]; !ok && (k == "background-color" || k == "background" || k == "text-align" || k == "vertical-align") {
+				style[k] = v
+			}
+		}
+
+		// рамки задаются на уровне таблицы: border у ячеек (частый вариант в редакторе)
+		// включает рамку всей таблицы, если она не задана явно
+		if t.Border == 0 {
+			applyBorder(style, &t.Border, &t.BorderColor)
+		}
+
+		cell.Align = strings.ToLower(style["text-align"])
+		if cell.Align == "" {
+			cell.Align = strings.ToLower(attr(c, "align"))
+		}
+		if cell.Align == "" && cell.Header {
+			cell.Align = "center"
+		}
+		cell.VAlign = strings.ToLower(style["vertical-align"])
+		if cell.VAlign == "" {
+			cell.VAlign = strings.ToLower(attr(c, "valign"))
+		}
+
+		bg := style["background-color"]
+		if bg == "" {
+			bg = style["background"]
+		}
+		if bg == "" {
+			bg = attr(c, "bgcolor")
+		}
+		if hex, ok := ParseColor(bg); ok {
+			cell.Background = hex
+		}
+
+		width := style["width"]
+		if width == "" {
+			width = attr(c, "width")
+		}
+		if v, pct, ok := ParseLength(width); ok {
+			if pct {
+				cell.WidthPercent = v
+			} else {
+				cell.WidthPt = v
+			}
+		}
+
+		// шрифт строки и ячейки наследуется текстом ячейки; фон уже задан заливкой ячейки
+		cf := f
+		if cell.Header {
+			cf.Bold = true
+		}
+		textStyle := make(map[string]string, len(style))
+		for _, decl := range []map[string]string{rowStyle, style} {
+			for k, v := range decl {
+				if k != "background" && k != "background-color" {
+					textStyle[k] = v
+				}
+			}
+		}
+		cf = applyRunStyle(cf, textStyle)
+		pf := applyInheritedParagraphStyle(p.para, textStyle)
+		pf.Align = cell.Align
+
+		cell.Blocks = p.parseCellContent(c, cf, pf)
+
+		row.Cells = append(row.Cells, cell)
+	}
+	return row
+}
+
+// parseCellContent разбирает содержимое ячейки отдельным парсером с теми же стилями
+func (p *parser) parseCellContent(n *html.Node, f RunFormat, pf ParagraphFormat) []Block {
+	sub := &parser{doc: &Document{}, sheet: p.sheet, listSeq: p.listSeq, para: pf}
+	sub.walkChildren(n, f)
+	sub.closeBlock()
+	p.listSeq = sub.listSeq
+
+	// вложенные таблицы в ячейках не поддерживаются — разворачиваем их в абзацы
+	var blocks []Block
+	for _, b := range sub.doc.Blocks {
+		if b.Table == nil {
+			blocks = append(blocks, b)
+			continue
+		}
+		for _, row := range b.Table.Rows {
+			for _, cell := range row.Cells {
+				blocks = append(blocks, cell.Blocks...)
+			}
+		}
+	}
+	return blocks
+}
+
+// applyBorder разбирает border: 1px solid #000 (и border-width/border-color)
+func applyBorder(style map[string]string, width *float64, color *string) {
+	if b, ok := style["border"]; ok {
+		if strings.Contains(b, "none") {
+			*width = 0
+			return
+		}
+		for _, part := range strings.Fields(b) {
+			if v, pct, ok := ParseLength(part); ok && !pct {
+				*width = v
+			} else if hex, ok := ParseColor(part); ok {
+				*color = hex
+			}
+		}
+		if *width == 0 {
+			*width = 0.75
+		}
+	}
+	if v, pct, ok := ParseLength(style["border-width"]); ok && !pct {
+		*width = v
+	}
+	if hex, ok := ParseColor(style["border-color"]); ok {
+		*color = hex
+	}
+}
+
+// Предельные значения span, colspan и rowspan по спецификации HTML
+const (
+	maxColSpan = 1000
+	maxRowSpan = 65534
+)
+
+// atoiSpan разбирает значение span/colspan/rowspan: некорректные и меньшие
+// единицы значения дают 1, большие ограничиваются limit
+func atoiSpan(s string, limit int) int {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || n < 1 {
+		return 1
+	}
+	return min(n, limit)
+}
@@ -0,0 +1,186 @@
+package richtext
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PlainText возвращает текст документа без форматирования: абзацы разделяются
+// переводом строки, пункты списков получают номера или маркеры, ячейки таблиц — табуляцию
+func PlainText(d *Document) string {
+	var sb strings.Builder
+	numbers := newListNumbers()
+	for i, b := range d.Blocks {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		if b.Table != nil {
+			for _, row := range b.Table.Rows {
+				cells := make([]string, len(row.Cells))
+				for j, cell := range row.Cells {
+					cells[j] = blocksText(cell.Blocks, " ")
+				}
+				sb.WriteString(strings.Join(cells, "\t"))
+				sb.WriteString("\n")
+			}
+			continue
+		}
+		if b.List != nil {
+			sb.WriteString(strings.Repeat("    ", b.List.Level))
+			if b.List.Ordered {
+				fmt.Fprintf(&sb, "%d. ", numbers.next(b.List))
+			} else {
+				sb.WriteString("• ")
+			}
+		}
+		sb.WriteString(runsText(b.Runs))
+	}
+	return sb.String()
+}
+
+// Markdown переводит документ в Markdown (GitHub Flavored: таблицы и ~~зачёркивание~~)
+func Markdown(d *Document) string {
+	var sb strings.Builder
+	numbers := newListNumbers()
+	for i, b := range d.Blocks {
+		if i > 0 {
+			// пункты одного списка идут подряд, остальные блоки разделяются пустой строкой
+			if prev := d.Blocks[i-1]; b.List == nil || prev.List == nil {
+				sb.WriteString("\n")
+			}
+			sb.WriteString("\n")
+		}
+		switch {
+		case b.Table != nil:
+			writeMarkdownTable(&sb, b.Table)
+		case b.Heading > 0:
+			sb.WriteString(strings.Repeat("#", b.Heading) + " " + runsMarkdown(b.Runs))
+		case b.List != nil:
+			sb.WriteString(strings.Repeat("   ", b.List.Level))
+			if b.List.Ordered {
+				fmt.Fprintf(&sb, "%d. ", numbers.next(b.List))
+			} else {
+				sb.WriteString("- ")
+			}
+			sb.WriteString(runsMarkdown(b.Runs))
+		default:
+			sb.WriteString(runsMarkdown(b.Runs))
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n") + "\n"
+}
+
+func writeMarkdownTable(sb *strings.Builder, t *Table) {
+	// ячейки с colspan дополняются пустыми, чтобы число колонок в строках совпадало
+	var rows [][]string
+	cols := 0
+	for _, row := range t.Rows {
+		var cells []string
+		for _, cell := range row.Cells {
+			text := strings.ReplaceAll(blocksMarkdown(cell.Blocks), "|", `\|`)
+			cells = append(cells, text)
+			for k := 1; k < cell.ColSpan; k++ {
+				cells = append(cells, "")
+			}
+		}
+		rows = append(rows, cells)
+		cols = max(cols, len(cells))
+	}
+	if cols == 0 {
+		return
+	}
+
+	lines := make([]string, 0, len(rows)+1)
+	for i, cells := range rows {
+		for len(cells) < cols {
+			cells = append(cells, "")
+		}
+		lines = append(lines, "| "+strings.Join(cells, " | ")+" |")
+		if i == 0 {
+			lines = append(lines, "|"+strings.Repeat(" --- |", cols))
+		}
+	}
+	sb.WriteString(strings.Join(lines, "\n"))
+}
+
+func runsText(runs []Run) string {
+	var sb strings.Builder
+	for _, r := range runs {
+		if r.Break {
+			sb.WriteString("\n")
+			continue
+		}
+		sb.WriteString(r.Text)
+	}
+	return sb.String()
+}
+
+func blocksText(blocks []Block, sep string) string {
+	parts := make([]string, 0, len(blocks))
+	for _, b := range blocks {
+		parts = append(parts, runsText(b.Runs))
+	}
+	return strings.Join(parts, sep)
+}
+
+func blocksMarkdown(blocks []Block) string {
+	parts := make([]string, 0, len(blocks))
+	for _, b := range blocks {
+		parts = append(parts, runsMarkdown(b.Runs))
+	}
+	return strings.Join(parts, "<br>")
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`, "#", `\#`, "~", `\~`,
+)
+
+func runsMarkdown(runs []Run) string {
+	var sb strings.Builder
+	for _, r := range runs {
+		if r.Break {
+			sb.WriteString("  \n")
+			continue
+		}
+
+		// маркеры ставятся вплотную к тексту, пробелы по краям выносятся наружу
+		text := markdownEscaper.Replace(r.Text)
+		core := strings.TrimSpace(text)
+		if core == "" {
+			sb.WriteString(text)
+			continue
+		}
+		lead := text[:strings.Index(text, core)]
+		trail := text[len(lead)+len(core):]
+
+		var open, closing string
+		if r.Format.Bold {
+			open, closing = open+"**", "**"+closing
+		}
+		if r.Format.Italic {
+			open, closing = open+"*", "*"+closing
+		}
+		if r.Format.Strike {
+			open, closing = open+"~~", "~~"+closing
+		}
+		sb.WriteString(lead + open + core + closing + trail)
+	}
+	return sb.String()
+}
+
+// listNumbers считает номера пунктов нумерованных списков по уровням
+type listNumbers map[int][]int
+
+func newListNumbers() listNumbers {
+	return make(listNumbers)
+}
+
+func (n listNumbers) next(list *ListInfo) int {
+	counters := n[list.ID]
+	for len(counters) <= list.Level {
+		counters = append(counters, 0)
+	}
+	counters[list.Level]++
+	n[list.ID] = counters[:list.Level+1]
+	return counters[list.Level]
+}
@@ -0,0 +1,119 @@
+package templates
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// ApprovalStep — согласующий в маршруте. Этапы (Step) проходятся по порядку,
+// согласующие одного этапа согласуют параллельно.
+type ApprovalStep struct {
+	Step   int    `json:"step"`
+	UserID int    `json:"user_id"`
+	Name   string `json:"name,omitempty"`
+}
+
+var ErrUnknownApprover = errors.New("согласующий не найден в организации")
+
+// ApprovalSteps разворачивает этапы вида [[юрист], [бухгалтер, директор]] в список
+// согласующих с номерами этапов. Пустые этапы пропускаются; один пользователь
+// может встречаться в маршруте только один раз.
+func ApprovalSteps(stages [][]int) ([]ApprovalStep, error) {
+	seen := make(map[int]bool)
+	steps := []ApprovalStep{}
+	step := 0
+	for _, userIDs := range stages {
+		if len(userIDs) == 0 {
+			continue
+		}
+		step++
+		for _, id := range userIDs {
+			if id <= 0 {
+				return nil, fmt.Errorf("некорректный ID согласующего: %d", id)
+			}
+			if seen[id] {
+				return nil, fmt.Errorf("пользователь %d указан в маршруте дважды", id)
+			}
+			seen[id] = true
+			steps = append(steps, ApprovalStep{Step: step, UserID: id})
+		}
+	}
+	return steps, nil
+}
+
+// GetApprovalRoute возвращает маршрут согласования шаблона по этапам
+func GetApprovalRoute(orgID, templateID int) ([]ApprovalStep, error) {
+	rows, err := db.Query(`
+		SELECT s.step, s.user_id, u.first_name || ' ' || u.last_name
+		FROM template_approval_steps s
+		JOIN templates t ON t.id = s.template_id
+		JOIN users u ON u.id = s.user_id
+		WHERE s.template_id = $1 AND t.organization_id = $2
+		ORDER BY s.step, u.last_name, u.first_name
+	`, templateID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	steps := []ApprovalStep{}
+	for rows.Next() {
+		var s ApprovalStep
+		if err := rows.Scan(&s.Step, &s.UserID, &s.Name); err != nil {
+			return nil, err
+		}
+		steps = append(steps, s)
+	}
+	return steps, rows.Err()
+}
+
+// SetApprovalRoute заменяет маршрут согласования шаблона. Согласующие должны
+// состоять в организации шаблона. Уже созданные документы сохраняют маршрут,
+// с которым были созданы.
+func SetApprovalRoute(orgID, templateID int, steps []ApprovalStep) error {
+	userIDs := make([]int, len(steps))
+	for i, s := range steps {
+		userIDs[i] = s.UserID
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM templates WHERE id = $1 AND organization_id = $2)
+	`, templateID, orgID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return sql.ErrNoRows
+	}
+
+	var found int
+	if err := tx.QueryRow(`
+		SELECT COUNT(*) FROM users WHERE id = ANY($1) AND organization_id = $2
+	`, pq.Array(userIDs), orgID).Scan(&found); err != nil {
+		return err
+	}
+	if found != len(userIDs) {
+		return ErrUnknownApprover
+	}
+
+	if _, err := tx.Exec(`DELETE FROM template_approval_steps WHERE template_id = $1`, templateID); err != nil {
+		return err
+	}
+	for _, s := range steps {
+		if _, err := tx.Exec(`
+			INSERT INTO template_approval_steps (template_id, step, user_id) VALUES ($1, $2, $3)
+		`, templateID, s.Step, s.UserID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,628 @@
+package templates
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"baliance.com/gooxml/document"
+	"baliance.com/gooxml/schema/soo/wml"
+	"github.com/google/uuid"
+
+	"doc-generation/render"
+)
+
+// ImportedTemplate — результат разбора .docx: HTML для templates.content,
+// inline-стили для template_styles и имена найденных тегов
+type ImportedTemplate struct {
+	HTML   string
+	Styles []ImportedStyle
+	Tags   []string
+}
+
+// ImportedStyle — оформление фрагментов текста, на которое ссылается <span data-style-id>
+type ImportedStyle struct {
+	StyleID string
+	Styles  map[string]interface{}
+}
+
+// Selector возвращает селектор, под которым стиль хранится в template_styles
+func (s ImportedStyle) Selector() string {
+	return fmt.Sprintf(`span[data-style-id="%s"]`, s.StyleID)
+}
+
+// ImportDocx разбирает документ Word в HTML шаблона. Форматирование прогонов
+// переносится в inline-стили, поля слияния MERGEFIELD становятся тегами {{имя}}.
+func ImportDocx(r io.ReaderAt, size int64) (*ImportedTemplate, error) {
+	doc, err := document.Read(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	im := &docxImporter{
+		styleNames: make(map[string]string),
+		numFormats: make(map[int64]map[int64]wml.ST_NumberFormat),
+		styleIDs:   make(map[string]string),
+	}
+	im.loadStyles(doc)
+	im.loadNumbering(doc)
+
+	if body := doc.X().Body; body != nil {
+		im.writeBlocks(body.EG_BlockLevelElts)
+	}
+
+	content := im.sb.String()
+	return &ImportedTemplate{HTML: content, Styles: im.styles, Tags: render.Fields(content)}, nil
+}
+
+// docxImporter собирает HTML из дерева wml
+type docxImporter struct {
+	sb         strings.Builder
+	styleNames map[string]string                       // styleId → имя стиля Word
+	numFormats map[int64]map[int64]wml.ST_NumberFormat // numId → уровень → формат нумерации
+	styleIDs   map[string]string                       // ключ оформления → data-style-id
+	styles     []ImportedStyle
+	lists      []*importList // открытые <ul>/<ol>
+}
+
+type importList struct {
+	ordered  bool
+	itemOpen bool
+}
+
+// segment — кусок текста абзаца с одинаковым оформлением
+type segment struct {
+	text string
+	br   bool
+	css  map[string]interface{}
+	key  string
+}
+
+// fieldState — поле Word (fldChar begin … separate … end)
+type fieldState struct {
+	instr     strings.Builder
+	separated bool
+	merge     string
+}
+
+var headingStyleRe = regexp.MustCompile(`^(?:heading|заголовок)\s*([1-6])$`)
+
+func (im *docxImporter) loadStyles(doc *document.Document) {
+	styles := doc.Styles.X()
+	if styles == nil {
+		return
+	}
+	for _, s := range styles.Style {
+		if s.StyleIdAttr == nil || s.Name == nil {
+			continue
+		}
+		im.styleNames[*s.StyleIdAttr] = s.Name.ValAttr
+	}
+}
+
+func (im *docxImporter) loadNumbering(doc *document.Document) {
+	numbering := doc.Numbering.X()
+	if numbering == nil {
+		return
+	}
+	abstract := make(map[int64]map[int64]wml.ST_NumberFormat)
+	for _, an := range numbering.AbstractNum {
+		levels := make(map[int64]wml.ST_NumberFormat)
+		for _, lvl := range an.Lvl {
+			if lvl.NumFmt != nil {
+				levels[lvl.IlvlAttr] = lvl.NumFmt.ValAttr
+			}
+		}
+		abstract[an.AbstractNumIdAttr] = levels
+	}
+	for _, num := range numbering.Num {
+		if num.AbstractNumId != nil {
+			im.numFormats[num.NumIdAttr] = abstract[num.AbstractNumId.ValAttr]
+		}
+	}
+}
+
+// writeBlocks выводит абзацы и таблицы в порядке документа. Списки не выходят
+// за пределы контейнера (тело документа или ячейка таблицы).
+func (im *docxImporter) writeBlocks(elts []*wml.EG_BlockLevelElts) {
+	saved := im.lists
+	im.lists = nil
+	for _, elt := range elts {
+		for _, block := range elt.EG_ContentBlockContent {
+			for _, p := range block.P {
+				im.writeParagraph(p)
+			}
+			for _, tbl := range block.Tbl {
+				im.closeLists(0)
+				im.writeTable(tbl)
+			}
+		}
+	}
+	im.closeLists(0)
+	im.lists = saved
+}
+
+func (im *docxImporter) writeParagraph(p *wml.CT_P) {
+	content := im.paragraphContent(p)
+
+	if level, ordered, ok := im.listItem(p); ok {
+		im.openListItem(level, ordered)
+		im.sb.WriteString(content)
+		return
+	}
+	im.closeLists(0)
+
+	if level := im.headingLevel(p.PPr); level > 0 {
+		fmt.Fprintf(&im.sb, "<h%d%s>%s</h%d>\n", level, alignAttr(p.PPr), content, level)
+		return
+	}
+	if content == "" {
+		content = "<br>"
+	}
+	fmt.Fprintf(&im.sb, "<p%s>%s</p>\n", alignAttr(p.PPr), content)
+}
+
+func (im *docxImporter) headingLevel(ppr *wml.CT_PPr) int {
+	if ppr == nil {
+		return 0
+	}
+	if ppr.OutlineLvl != nil && ppr.OutlineLvl.ValAttr < 6 {
+		return int(ppr.OutlineLvl.ValAttr) + 1
+	}
+	if ppr.PStyle == nil {
+		return 0
+	}
+	for _, name := range []string{im.styleNames[ppr.PStyle.ValAttr], ppr.PStyle.ValAttr} {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "title" || name == "название" {
+			return 1
+		}
+		if m := headingStyleRe.FindStringSubmatch(name); m != nil {
+			level, _ := strconv.Atoi(m[1])
+			return level
+		}
+	}
+	return 0
+}
+
+var alignments = map[wml.ST_Jc]string{
+	wml.ST_JcCenter: "center",
+	wml.ST_JcRight:  "right",
+	wml.ST_JcEnd:    "right",
+	wml.ST_JcBoth:   "justify",
+}
+
+func alignAttr(ppr *wml.CT_PPr) string {
+	if ppr == nil || ppr.Jc == nil {
+		return ""
+	}
+	if align, ok := alignments[ppr.Jc.ValAttr]; ok {
+		return fmt.Sprintf(` style="text-align: %s"`, align)
+	}
+	return ""
+}
+
+// ---------- списки ----------
+
+func (im *docxImporter) listItem(p *wml.CT_P) (level int, ordered bool, ok bool) {
+	if p.PPr == nil || p.PPr.NumPr == nil || p.PPr.NumPr.NumId == nil || p.PPr.NumPr.NumId.ValAttr == 0 {
+		return 0, false, false
+	}
+	numID := p.PPr.NumPr.NumId.ValAttr
+	var ilvl int64
+	if p.PPr.NumPr.Ilvl != nil {
+		ilvl = p.PPr.NumPr.Ilvl.ValAttr
+	}
+	format := im.numFormats[numID][ilvl]
+	ordered = format != wml.ST_NumberFormatUnset && format != wml.ST_NumberFormatBullet
+	return int(ilvl), ordered, true
+}
+
+// openListItem открывает <li> на нужном уровне, при необходимости вкладывая
+// новый список в текущий пункт
+func (im *docxImporter) openListItem(level int, ordered bool) {
+	im.closeLists(level + 1)
+	if n := len(im.lists); n == level+1 {
+		top := im.lists[n-1]
+		if top.ordered != ordered {
+			im.closeLists(level)
+		} else if top.itemOpen {
+			im.sb.WriteString("</li>\n")
+			top.itemOpen = false
+		}
+	}
+	for len(im.lists) < level+1 {
+		list := &importList{ordered: ordered}
+		im.lists = append(im.lists, list)
+		im.sb.WriteString(listTag(list, false))
+	}
+	im.sb.WriteString("<li>")
+	im.lists[len(im.lists)-1].itemOpen = true
+}
+
+// closeLists закрывает вложенные списки, пока их не останется depth
+func (im *docxImporter) closeLists(depth int) {
+	for len(im.lists) > depth {
+		top := im.lists[len(im.lists)-1]
+		if top.itemOpen {
+			im.sb.WriteString("</li>")
+		}
+		im.sb.WriteString(listTag(top, true))
+		im.lists = im.lists[:len(im.lists)-1]
+	}
+}
+
+func listTag(l *importList, closing bool) string {
+	tag := "ul"
+	if l.ordered {
+		tag = "ol"
+	}
+	if closing {
+		return "</" + tag + ">\n"
+	}
+	return "<" + tag + ">\n"
+}
+
+// ---------- таблицы ----------
+
+type importCell struct {
+	tc        *wml.CT_Tc
+	col       int
+	colSpan   int
+	rowSpan   int
+	continued bool
+}
+
+func (im *docxImporter) writeTable(tbl *wml.CT_Tbl) {
+	var rows [][]*importCell
+	for _, rc := range tbl.EG_ContentRowContent {
+		for _, tr := range rc.Tr {
+			var cells []*importCell
+			col := 0
+			for _, cc := range tr.EG_ContentCellContent {
+				for _, tc := range cc.Tc {
+					cell := &importCell{tc: tc, col: col, colSpan: 1, rowSpan: 1}
+					if tc.TcPr != nil {
+						if tc.TcPr.GridSpan != nil && tc.TcPr.GridSpan.ValAttr > 1 {
+							cell.colSpan = int(tc.TcPr.GridSpan.ValAttr)
+						}
+						// <w:vMerge/> без значения продолжает объединение сверху
+						if tc.TcPr.VMerge != nil && tc.TcPr.VMerge.ValAttr != wml.ST_MergeRestart {
+							cell.continued = true
+						}
+					}
+					cells = append(cells, cell)
+					col += cell.colSpan
+				}
+			}
+			rows = append(rows, cells)
+		}
+	}
+
+	// rowspan — число строк ниже, продолжающих объединение в той же колонке
+	for i, cells := range rows {
+		for _, cell := range cells {
+			if cell.continued {
+				continue
+			}
+			for _, below := range rows[i+1:] {
+				next := cellAt(below, cell.col)
+				if next == nil || !next.continued {
+					break
+				}
+				cell.rowSpan++
+			}
+		}
+	}
+
+	border := ""
+	if tbl.TblPr != nil && (tbl.TblPr.TblBorders != nil || tbl.TblPr.TblStyle != nil) {
+		border = ` border="1"`
+	}
+	fmt.Fprintf(&im.sb, "<table%s>\n<tbody>\n", border)
+	for _, cells := range rows {
+		im.sb.WriteString("<tr>")
+		for _, cell := range cells {
+			if cell.continued {
+				continue
+			}
+			im.sb.WriteString("<td")
+			if cell.colSpan > 1 {
+				fmt.Fprintf(&im.sb, ` colspan="%d"`, cell.colSpan)
+			}
+			if cell.rowSpan > 1 {
+				fmt.Fprintf(&im.sb, ` rowspan="%d"`, cell.rowSpan)
+			}
+			im.sb.WriteString(">")
+			im.writeBlocks(cell.tc.EG_BlockLevelElts)
+			im.sb.WriteString("</td>")
+		}
+		im.sb.WriteString("</tr>\n")
+	}
+	im.sb.WriteString("</tbody>\n</table>\n")
+}
+
+func cellAt(cells []*importCell, col int) *importCell {
+	for _, cell := range cells {
+		if cell.col == col {
+			return cell
+		}
+	}
+	return nil
+}
+
+// ---------- прогоны и поля ----------
+
+// paragraphContent собирает HTML содержимого абзаца
+func (im *docxImporter) paragraphContent(p *wml.CT_P) string {
+	var segs []segment
+	var fields []*fieldState
+	im.collectContent(p.EG_PContent, &segs, &fields)
+	segs = joinSplitTags(segs)
+
+	var sb strings.Builder
+	for i := 0; i < len(segs); {
+		if segs[i].br {
+			sb.WriteString("<br>")
+			i++
+			continue
+		}
+		// соседние прогоны с одинаковым оформлением склеиваются
+		text := segs[i].text
+		j := i + 1
+		for ; j < len(segs) && !segs[j].br && segs[j].key == segs[i].key; j++ {
+			text += segs[j].text
+		}
+		escaped := html.EscapeString(text)
+		if segs[i].key == "" {
+			sb.WriteString(escaped)
+		} else {
+			fmt.Fprintf(&sb, `<span data-style-id="%s">%s</span>`, im.styleID(segs[i]), escaped)
+		}
+		i = j
+	}
+	return sb.String()
+}
+
+func (im *docxImporter) collectContent(content []*wml.EG_PContent, segs *[]segment, fields *[]*fieldState) {
+	for _, pc := range content {
+		for _, rc := range pc.EG_ContentRunContent {
+			if rc.R != nil {
+				im.collectRun(rc.R, segs, fields)
+			}
+		}
+		for _, fs := range pc.FldSimple {
+			if name := mergeFieldName(fs.InstrAttr); name != "" {
+				*segs = append(*segs, newSegment("{{"+name+"}}", firstRunProps(fs.EG_PContent)))
+				continue
+			}
+			// прочие поля (PAGE, DATE…) оставляем отображаемым текстом
+			im.collectContent(fs.EG_PContent, segs, fields)
+		}
+		if pc.Hyperlink != nil {
+			im.collectContent(pc.Hyperlink.EG_PContent, segs, fields)
+		}
+	}
+}
+
+func (im *docxImporter) collectRun(r *wml.CT_R, segs *[]segment, fields *[]*fieldState) {
+	for _, ic := range r.EG_RunInnerContent {
+		switch {
+		case ic.FldChar != nil:
+			switch ic.FldChar.FldCharTypeAttr {
+			case wml.ST_FldCharTypeBegin:
+				*fields = append(*fields, &fieldState{})
+			case wml.ST_FldCharTypeSeparate:
+				if n := len(*fields); n > 0 {
+					f := (*fields)[n-1]
+					f.separated = true
+					f.merge = mergeFieldName(f.instr.String())
+					if f.merge != "" {
+						*segs = append(*segs, newSegment("{{"+f.merge+"}}", r.RPr))
+					}
+				}
+			case wml.ST_FldCharTypeEnd:
+				if n := len(*fields); n > 0 {
+					f := (*fields)[n-1]
+					// поле без отображаемого результата
+					if !f.separated {
+						if name := mergeFieldName(f.instr.String()); name != "" {
+							*segs = append(*segs, newSegment("{{"+name+"}}", r.RPr))
+						}
+					}
+					*fields = (*fields)[:n-1]
+				}
+			}
+		case ic.InstrText != nil:
+			if n := len(*fields); n > 0 && !(*fields)[n-1].separated {
+				(*fields)[n-1].instr.WriteString(ic.InstrText.Content)
+			}
+		case hiddenByField(*fields):
+			// код поля или подставленное Word значение «Имя» уже заменены тегом
+		case ic.T != nil:
+			*segs = append(*segs, newSegment(ic.T.Content, r.RPr))
+		case ic.Tab != nil:
+			*segs = append(*segs, newSegment("\t", r.RPr))
+		case ic.Br != nil:
+			*segs = append(*segs, segment{br: true})
+		}
+	}
+}
+
+// hiddenByField — текст внутри кода поля или в результате поля слияния не выводится
+func hiddenByField(fields []*fieldState) bool {
+	for _, f := range fields {
+		if !f.separated || f.merge != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeFieldName извлекает имя поля из инструкции вида MERGEFIELD "Имя клиента" \* MERGEFORMAT
+func mergeFieldName(instr string) string {
+	instr = strings.TrimSpace(instr)
+	parts := strings.Fields(instr)
+	if len(parts) < 2 || !strings.EqualFold(parts[0], "MERGEFIELD") {
+		return ""
+	}
+	name := strings.TrimSpace(instr[len(parts[0]):])
+	if i := strings.Index(name, `\`); i >= 0 {
+		name = name[:i]
+	}
+	name = strings.Trim(strings.TrimSpace(name), `"«»`)
+	return strings.Join(strings.Fields(name), "_")
+}
+
+func firstRunProps(content []*wml.EG_PContent) *wml.CT_RPr {
+	for _, pc := range content {
+		for _, rc := range pc.EG_ContentRunContent {
+			if rc.R != nil {
+				return rc.R.RPr
+			}
+		}
+	}
+	return nil
+}
+
+// joinSplitTags склеивает {{тег}}, который Word разбил на несколько прогонов:
+// тег получает оформление прогона, в котором начинается
+func joinSplitTags(segs []segment) []segment {
+	for i := 0; i < len(segs); i++ {
+		for i+1 < len(segs) && !segs[i].br && !segs[i+1].br && openTag(segs[i].text, segs[i+1].text) {
+			segs[i].text += segs[i+1].text
+			segs = append(segs[:i+1], segs[i+2:]...)
+		}
+	}
+	return segs
+}
+
+func openTag(text, next string) bool {
+	if strings.LastIndex(text, "{{") > strings.LastIndex(text, "}}") {
+		return true
+	}
+	return strings.HasSuffix(text, "{") && strings.HasPrefix(next, "{")
+}
+
+// ---------- оформление ----------
+
+func newSegment(text string, rpr *wml.CT_RPr) segment {
+	css := runCSS(rpr)
+	return segment{text: text, css: css, key: styleKey(css)}
+}
+
+// styleID возвращает data-style-id для оформления; одинаковое оформление
+// внутри документа получает один и тот же стиль
+func (im *docxImporter) styleID(s segment) string {
+	if id, ok := im.styleIDs[s.key]; ok {
+		return id
+	}
+	id := uuid.New().String()
+	im.styleIDs[s.key] = id
+	im.styles = append(im.styles, ImportedStyle{StyleID: id, Styles: s.css})
+	return id
+}
+
+func styleKey(css map[string]interface{}) string {
+	names := make([]string, 0, len(css))
+	for name := range css {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var sb strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&sb, "%s:%v;", name, css[name])
+	}
+	return sb.String()
+}
+
+// runCSS переводит свойства прогона Word в CSS-декларации
+func runCSS(rpr *wml.CT_RPr) map[string]interface{} {
+	css := make(map[string]interface{})
+	if rpr == nil {
+		return css
+	}
+	if onOff(rpr.B) {
+		css["font-weight"] = "bold"
+	}
+	if onOff(rpr.I) {
+		css["font-style"] = "italic"
+	}
+
+	var decorations []string
+	if rpr.U != nil && rpr.U.ValAttr != wml.ST_UnderlineUnset && rpr.U.ValAttr != wml.ST_UnderlineNone {
+		decorations = append(decorations, "underline")
+	}
+	if onOff(rpr.Strike) {
+		decorations = append(decorations, "line-through")
+	}
+	if len(decorations) > 0 {
+		css["text-decoration"] = strings.Join(decorations, " ")
+	}
+
+	if rpr.Sz != nil && rpr.Sz.ValAttr.ST_UnsignedDecimalNumber != nil {
+		css["font-size"] = fontSize(float64(*rpr.Sz.ValAttr.ST_UnsignedDecimalNumber) / 2)
+	}
+	if rpr.RFonts != nil {
+		family := rpr.RFonts.AsciiAttr
+		if family == nil {
+			family = rpr.RFonts.HAnsiAttr
+		}
+		if family != nil && *family != "" {
+			name := *family
+			if strings.Contains(name, " ") {
+				name = "'" + name + "'"
+			}
+			css["font-family"] = name
+		}
+	}
+	if rpr.Color != nil && rpr.Color.ValAttr.ST_HexColorRGB != nil {
+		css["color"] = "#" + strings.ToLower(*rpr.Color.ValAttr.ST_HexColorRGB)
+	}
+	if rpr.Highlight != nil {
+		if bg, ok := highlightColors[rpr.Highlight.ValAttr.String()]; ok {
+			css["background-color"] = bg
+		}
+	}
+	return css
+}
+
+// fontSize записывает размер в px, как в редакторе шаблонов, если он целый, иначе в pt
+func fontSize(pt float64) string {
+	px := pt * 4 / 3
+	if px == float64(int(px)) {
+		return fmt.Sprintf("%dpx", int(px))
+	}
+	return strconv.FormatFloat(pt, 'f', -1, 64) + "pt"
+}
+
+func onOff(v *wml.CT_OnOff) bool {
+	if v == nil {
+		return false
+	}
+	return v.ValAttr == nil || v.ValAttr.Bool == nil || *v.ValAttr.Bool
+}
+
+// highlightColors — цвета выделения Word
+var highlightColors = map[string]string{
+	"black":       "#000000",
+	"blue":        "#0000ff",
+	"cyan":        "#00ffff",
+	"green":       "#00ff00",
+	"magenta":     "#ff00ff",
+	"red":         "#ff0000",
+	"yellow":      "#ffff00",
+	"white":       "#ffffff",
+	"darkBlue":    "#000080",
+	"darkCyan":    "#008080",
+	"darkGreen":   "#008000",
+	"darkMagenta": "#800080",
+	"darkRed":     "#800000",
+	"darkYellow":  "#808000",
+	"darkGray":    "#808080",
+	"lightGray":   "#c0c0c0",
+}
@@ -0,0 +1,164 @@
+package templates
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+// importedContract — разобранный .docx: оформленный тег client уже есть в организации,
+// тег date нужно создать и назначить ему стиль
+var importedContract = &ImportedTemplate{
+	HTML:   `<p><span data-style-id="s1">{{client}}</span> от {{date}}</p>`,
+	Styles: []ImportedStyle{{StyleID: "s1", Styles: map[string]interface{}{"font-weight": "bold"}}},
+	Tags:   []string{"client", "date"},
+}
+
+var errDB = errors.New("ошибка базы данных")
+
+func tagRow(id int, name string, styleID interface{}) *sqlmock.Rows {
+	return sqlmock.NewRows(tagRowColumns).
+		AddRow(id, name, name, "", TagTypeString, time.Now(), styleID, "", false, []byte("[]"))
+}
+
+// expectImport описывает запросы импорта importedContract до шага fail включительно;
+// шаг с ошибкой возвращает errDB, после него ожидается откат
+func expectImport(mock sqlmock.Sqlmock, fail string) {
+	errOn := func(step string) error {
+		if step == fail {
+			return errDB
+		}
+		return nil
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO templates \(organization_id, user_id, name, content, kind, source_docx\)`).
+		WithArgs(ownOrg, 10, "Договор", importedContract.HTML, TemplateKindHTML, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+
+	style := mock.ExpectExec(`INSERT INTO template_styles`).
+		WithArgs(7, `span[data-style-id="s1"]`, sqlmock.AnyArg(), "inline", sqlmock.AnyArg(), ownOrg)
+	if err := errOn("style"); err != nil {
+		style.WillReturnError(err)
+		mock.ExpectRollback()
+		return
+	}
+	style.WillReturnResult(sqlmock.NewResult(0, 1))
+
+	mock.ExpectQuery(`FROM tags WHERE organization_id = \$1 AND name = ANY\(\$2\)`).
+		WithArgs(ownOrg, sqlmock.AnyArg()).
+		WillReturnRows(tagRow(1, "client", "a5f0c1e2-0000-4000-8000-000000000001"))
+	tag := mock.ExpectQuery(`INSERT INTO tags`).
+		WithArgs(ownOrg, "date", "date", "", TagTypeString, "", false, sqlmock.AnyArg())
+	if err := errOn("tag"); err != nil {
+		tag.WillReturnError(err)
+		mock.ExpectRollback()
+		return
+	}
+	tag.WillReturnRows(tagRow(2, "date", nil))
+
+	mock.ExpectQuery(`SELECT style_id FROM tags WHERE organization_id = \$1 AND name = \$2`).
+		WithArgs(ownOrg, "client").
+		WillReturnRows(sqlmock.NewRows([]string{"style_id"}).AddRow("a5f0c1e2-0000-4000-8000-000000000001"))
+	mock.ExpectQuery(`SELECT style_id FROM tags WHERE organization_id = \$1 AND name = \$2`).
+		WithArgs(ownOrg, "date").
+		WillReturnRows(sqlmock.NewRows([]string{"style_id"}).AddRow(nil))
+	mock.ExpectExec(`UPDATE tags SET style_id = \$1 WHERE organization_id = \$2 AND name = \$3`).
+		WithArgs(sqlmock.AnyArg(), ownOrg, "date").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	defaultStyle := mock.ExpectExec(`INSERT INTO template_styles`).
+		WithArgs(7, sqlmock.AnyArg(), sqlmock.AnyArg(), "inline", 14, ownOrg)
+	if err := errOn("default style"); err != nil {
+		defaultStyle.WillReturnError(err)
+		mock.ExpectRollback()
+		return
+	}
+	defaultStyle.WillReturnResult(sqlmock.NewResult(0, 1))
+
+	mock.ExpectCommit()
+}
+
+func TestSaveImportedTemplate(t *testing.T) {
+	mock := mockDB(t)
+	expectImport(mock, "")
+
+	id, created, err := SaveImportedTemplate(ownOrg, 10, "Договор", TemplateKindHTML, importedContract, []byte("PK"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 7 {
+		t.Errorf("ID шаблона %d, ожидалось 7", id)
+	}
+	if len(created) != 1 || created[0] != "date" {
+		t.Errorf("созданы теги %v, ожидалось [date]", created)
+	}
+}
+
+// Ошибка на любом шаге откатывает весь импорт: не остаётся ни шаблона без стилей,
+// ни тегов без шаблона
+func TestSaveImportedTemplatePartialFailure(t *testing.T) {
+	for _, step := range []string{"style", "tag", "default style"} {
+		t.Run(step, func(t *testing.T) {
+			mock := mockDB(t)
+			expectImport(mock, step)
+
+			id, created, err := SaveImportedTemplate(ownOrg, 10, "Договор", TemplateKindHTML, importedContract, nil)
+			if !errors.Is(err, errDB) {
+				t.Fatalf("SaveImportedTemplate: %v, ожидалась ошибка базы данных", err)
+			}
+			if id != 0 || created != nil {
+				t.Errorf("при ошибке вернулись ID %d и теги %v", id, created)
+			}
+		})
+	}
+}
+
+// Файл, который не удалось разобрать, отклоняется до обращения к базе
+// (sqlmock без ожиданий отклонил бы любой запрос)
+func TestImportTemplateHandlerMalformed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name     string
+		filename string
+		data     []byte
+	}{
+		{name: "не zip", filename: "договор.docx", data: []byte("это не документ Word")},
+		{name: "пустой файл", filename: "договор.docx", data: nil},
+		{name: "не .docx", filename: "договор.doc", data: []byte{0xd0, 0xcf, 0x11, 0xe0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB(t)
+
+			var body bytes.Buffer
+			mw := multipart.NewWriter(&body)
+			fw, err := mw.CreateFormFile("file", tt.filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fw.Write(tt.data)
+			mw.Close()
+
+			req := httptest.NewRequest(http.MethodPost, "/templates/import", &body)
+			req.Header.Set("Content-Type", mw.FormDataContentType())
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = req
+			c.Set("organization_id", ownOrg)
+			c.Set("user_id", 10)
+
+			importTemplateHandler(c)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("код ответа %d, ожидалось 400: %s", w.Code, w.Body)
+			}
+		})
+	}
+}
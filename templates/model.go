@@ -3,8 +3,14 @@ package templates
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"time"
+
+	"github.com/lib/pq"
+
+	"doc-generation/etag"
 )
 
 var db *sql.DB
@@ -13,22 +19,35 @@ func InitTemplates(database *sql.DB) {
 	db = database
 }
 
+// Шаблоны, стили и теги принадлежат организации: функции этого файла принимают
+// orgID пользователя и не видят данных других организаций — чужой шаблон или тег
+// для них не существует (sql.ErrNoRows).
+
+// Виды шаблонов
+const (
+	TemplateKindHTML = "html"
+	TemplateKindDocx = "docx"
+)
+
 type Template struct {
-	ID        int       `json:"id"`
-	UserID    int       `json:"user_id"`
-	Name      string    `json:"name"`
-	Content   string    `json:"content"`
-	CreatedAt time.Time `json:"created_at"`
+	ID             int       `json:"id"`
+	OrganizationID int       `json:"organization_id"`
+	UserID         int       `json:"user_id"`
+	Name           string    `json:"name"`
+	Content        string    `json:"content"`
+	Kind           string    `json:"kind"`
+	Version        int       `json:"version"`
+	CreatedAt      time.Time `json:"created_at"`
 }
 
 // Create
-func CreateTemplate(userID int, name, content string) (int, error) {
+func CreateTemplate(orgID, userID int, name, content string) (int, error) {
 	var newID int
 	err := db.QueryRow(`
-		INSERT INTO templates (user_id, name, content)
-		VALUES ($1, $2, $3)
+		INSERT INTO templates (organization_id, user_id, name, content)
+		VALUES ($1, $2, $3, $4)
 		RETURNING id
-	`, userID, name, content).Scan(&newID)
+	`, orgID, userID, name, content).Scan(&newID)
 
 	if err != nil {
 		return 0, err
@@ -37,35 +56,101 @@ func CreateTemplate(userID int, name, content string) (int, error) {
 }
 
 // Get by ID
-func GetTemplateByID(id int) (*Template, error) {
-	row := db.QueryRow(`SELECT id, user_id, name, content, created_at FROM templates WHERE id = $1`, id)
+func GetTemplateByID(orgID, id int) (*Template, error) {
+	row := db.QueryRow(`
+		SELECT id, organization_id, user_id, name, content, kind, version, created_at
+		FROM templates WHERE id = $1 AND organization_id = $2
+	`, id, orgID)
 
 	var t Template
-	err := row.Scan(&t.ID, &t.UserID, &t.Name, &t.Content, &t.CreatedAt)
+	err := row.Scan(&t.ID, &t.OrganizationID, &t.UserID, &t.Name, &t.Content, &t.Kind, &t.Version, &t.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
+	if t.OrganizationID != orgID {
+		return nil, sql.ErrNoRows
+	}
 	return &t, nil
 }
 
-// Update
-func UpdateTemplate(id int, name, content string) error {
-	_, err := db.Exec(`UPDATE templates SET name = $1, content = $2 WHERE id = $3`, name, content, id)
-	return err
+// GetTemplateSource возвращает исходный .docx шаблона вида docx
+func GetTemplateSource(orgID, id int) ([]byte, error) {
+	var source []byte
+	err := db.QueryRow(`
+		SELECT source_docx FROM templates WHERE id = $1 AND organization_id = $2 AND kind = $3
+	`, id, orgID, TemplateKindDocx).Scan(&source)
+	if err == nil && len(source) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return source, err
+}
+
+// ErrVersionConflict — шаблон успели изменить после того, как клиент получил его версию
+var ErrVersionConflict = errors.New("шаблон изменён другим пользователем")
+
+// execScoped выполняет изменение и возвращает sql.ErrNoRows, если не затронута ни одна
+// строка — запись не найдена или принадлежит другой организации
+func execScoped(query string, args ...interface{}) error {
+	return execScopedOn(db, query, args...)
+}
+
+// execScopedOn — execScoped внутри транзакции
+func execScopedOn(q dbtx, query string, args ...interface{}) error {
+	res, err := q.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// updateVersioned выполняет UPDATE … RETURNING version с проверкой версии и
+// различает конфликт версий и отсутствие шаблона в организации
+func updateVersioned(orgID, id int, query string, args ...interface{}) (int, error) {
+	var version int
+	err := db.QueryRow(query, args...).Scan(&version)
+	if err == sql.ErrNoRows {
+		var exists bool
+		if err := db.QueryRow(`
+			SELECT EXISTS (SELECT 1 FROM templates WHERE id = $1 AND organization_id = $2)
+		`, id, orgID).Scan(&exists); err != nil {
+			return 0, err
+		}
+		if exists {
+			return 0, ErrVersionConflict
+		}
+	}
+	return version, err
+}
+
+// Update обновляет название и контент, если версия шаблона есть среди versions
+// (etag.AnyVersion — без проверки). Возвращает новую версию.
+func UpdateTemplate(orgID, id int, name, content string, versions []int) (int, error) {
+	return updateVersioned(orgID, id, `
+		UPDATE templates SET name = $1, content = $2, version = version + 1
+		WHERE id = $3 AND organization_id = $6 AND ($5::int = ANY($4::int[]) OR version = ANY($4::int[]))
+		RETURNING version
+	`, name, content, id, pq.Array(versions), etag.Any, orgID)
 }
 
 // Delete
-func DeleteTemplate(id int) error {
-	_, err := db.Exec(`DELETE FROM templates WHERE id = $1`, id)
-	return err
+func DeleteTemplate(orgID, id int) error {
+	return execScoped(`DELETE FROM templates WHERE id = $1 AND organization_id = $2`, id, orgID)
 }
 
-func GetAllTemplates() ([]TemplateWithCreator, error) {
+func GetAllTemplates(orgID int) ([]TemplateWithCreator, error) {
 	rows, err := db.Query(`
 		SELECT t.id, t.name, t.created_at, u.first_name, u.last_name
 		FROM templates t
 		JOIN users u ON t.user_id = u.id
-	`)
+		WHERE t.organization_id = $1
+	`, orgID)
 	if err != nil {
 		return nil, err
 	}
@@ -93,65 +178,115 @@ type TemplateWithCreator struct {
 	} `json:"creator"`
 }
 
-func RenameTemplate(id int, name string) error {
-	_, err := db.Exec("UPDATE templates SET name = $1 WHERE id = $2", name, id)
-	return err
+func RenameTemplate(orgID, id int, name string) error {
+	return execScoped(`
+		UPDATE templates SET name = $1, version = version + 1 WHERE id = $2 AND organization_id = $3
+	`, name, id, orgID)
 }
 
-func UpdateTemplateContent(id int, content string) error {
+// UpdateTemplateContent обновляет контент, если версия шаблона есть среди versions
+// (etag.AnyVersion — без проверки). Возвращает новую версию.
+func UpdateTemplateContent(orgID, id int, content string, versions []int) (int, error) {
 	log.Printf("🔁 Обновление контента шаблона ID=%d, длина контента=%d", id, len(content))
-	query := `UPDATE templates SET content = $1 WHERE id = $2`
-	result, err := db.Exec(query, content, id)
-	if err != nil {
+	newVersion, err := updateVersioned(orgID, id, `
+		UPDATE templates SET content = $1, version = version + 1
+		WHERE id = $2 AND organization_id = $5 AND ($4::int = ANY($3::int[]) OR version = ANY($3::int[]))
+		RETURNING version
+	`, content, id, pq.Array(versions), etag.Any, orgID)
+	switch {
+	case err == sql.ErrNoRows:
+		log.Printf("⚠️ Шаблон с ID=%d не найден, обновление не выполнено", id)
+	case err == ErrVersionConflict:
+		log.Printf("⚠️ Конфликт версий шаблона ID=%d: ожидалась версия из %v", id, versions)
+	case err != nil:
 		log.Printf("❌ SQL-ошибка при обновлении шаблона ID=%d: %v", id, err)
-		return err
+	default:
+		log.Printf("✅ Контент шаблона ID=%d успешно обновлён, версия %d", id, newVersion)
 	}
+	return newVersion, err
+}
+
+type Tag struct {
+	ID           int       `json:"id" db:"id"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	Name         string    `json:"name" db:"name"`
+	Label        string    `json:"label" db:"label"`
+	Description  string    `json:"description" db:"description"`
+	Type         string    `json:"type" db:"type"` // 👈 новое поле
+	StyleID      *string   `json:"style_id"`
+	DefaultValue string    `json:"default_value" db:"default_value"`
+	Required     bool      `json:"required" db:"required"`
+	Options      []string  `json:"options" db:"options"` // допустимые значения для типа enum
+}
+
+// tagColumns — порядок колонок, который ожидает scanTag
+const tagColumns = `id, name, label, description, type, created_at, style_id, default_value, required, options`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
 
-	rowsAffected, err := result.RowsAffected()
+// dbtx — общее у *sql.DB и *sql.Tx
+type dbtx interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func scanTag(row rowScanner) (*Tag, error) {
+	var t Tag
+	var options []byte
+	err := row.Scan(
+		&t.ID,
+		&t.Name,
+		&t.Label,
+		&t.Description,
+		&t.Type, // 👈 добавлено считывание типа
+		&t.CreatedAt,
+		&t.StyleID,
+		&t.DefaultValue,
+		&t.Required,
+		&options,
+	)
 	if err != nil {
-		log.Printf("❓ Не удалось получить число затронутых строк: %v", err)
-		return err
+		return nil, err
 	}
-
-	if rowsAffected == 0 {
-		log.Printf("⚠️ Шаблон с ID=%d не найден, обновление не выполнено", id)
-	} else {
-		log.Printf("✅ Контент шаблона ID=%d успешно обновлён, строк изменено: %d", id, rowsAffected)
+	if len(options) > 0 {
+		if err := json.Unmarshal(options, &t.Options); err != nil {
+			return nil, err
+		}
 	}
-
-	return nil
+	return &t, nil
 }
 
-type Tag struct {
-	ID          int       `json:"id" db:"id"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
-	Name        string    `json:"name" db:"name"`
-	Label       string    `json:"label" db:"label"`
-	Description string    `json:"description" db:"description"`
-	Type        string    `json:"type" db:"type"` // 👈 новое поле
-	StyleID     *string   `json:"style_id"`
+func marshalOptions(options []string) ([]byte, error) {
+	if options == nil {
+		options = []string{}
+	}
+	return json.Marshal(options)
 }
 
 // CreateTag создает новый тег в таблице tags
-func CreateTag(name, label, description, tagType string) (*Tag, error) {
-	var tag Tag
-	query := `
-		INSERT INTO tags (name, label, description, type, created_at)
-		VALUES ($1, $2, $3, $4, NOW())
-		RETURNING id, name, label, description, type, created_at
-	`
-	err := db.QueryRow(query, name, label, description, tagType).Scan(
-		&tag.ID, &tag.Name, &tag.Label, &tag.Description, &tag.Type, &tag.CreatedAt,
-	)
+func CreateTag(orgID int, req CreateTagRequest) (*Tag, error) {
+	return createTag(db, orgID, req)
+}
+
+func createTag(q dbtx, orgID int, req CreateTagRequest) (*Tag, error) {
+	options, err := marshalOptions(req.Options)
 	if err != nil {
 		return nil, err
 	}
-	return &tag, nil
+
+	query := `
+		INSERT INTO tags (organization_id, name, label, description, type, default_value, required, options, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())
+		RETURNING ` + tagColumns
+	return scanTag(q.QueryRow(query, orgID, req.Name, req.Label, req.Description, req.Type, req.DefaultValue, req.Required, options))
 }
 
-// GetAllTags возвращает все теги из таблицы tags
-func GetAllTags() ([]Tag, error) {
-	rows, err := db.Query(`SELECT id, name, label, description, type, created_at, style_id FROM tags ORDER BY name`)
+// GetAllTags возвращает все теги организации
+func GetAllTags(orgID int) ([]Tag, error) {
+	rows, err := db.Query(`SELECT `+tagColumns+` FROM tags WHERE organization_id = $1 ORDER BY name`, orgID)
 	if err != nil {
 		return nil, err
 	}
@@ -159,46 +294,91 @@ func GetAllTags() ([]Tag, error) {
 
 	var tags []Tag
 	for rows.Next() {
-		var t Tag
-		err := rows.Scan(
-			&t.ID,
-			&t.Name,
-			&t.Label,
-			&t.Description,
-			&t.Type, // 👈 добавлено считывание типа
-			&t.CreatedAt,
-			&t.StyleID,
-		)
+		t, err := scanTag(rows)
 		if err != nil {
 			return nil, err
 		}
-		tags = append(tags, t)
+		tags = append(tags, *t)
 	}
 	return tags, nil
 }
 
+// GetTagByName возвращает тег по имени (sql.ErrNoRows, если такого тега нет)
+func GetTagByName(orgID int, name string) (*Tag, error) {
+	return scanTag(db.QueryRow(`SELECT `+tagColumns+` FROM tags WHERE organization_id = $1 AND name = $2`, orgID, name))
+}
+
+// GetTagsByNames возвращает теги с указанными именами в виде карты name → Tag
+func GetTagsByNames(orgID int, names []string) (map[string]Tag, error) {
+	return getTagsByNames(db, orgID, names)
+}
+
+func getTagsByNames(q dbtx, orgID int, names []string) (map[string]Tag, error) {
+	result := make(map[string]Tag)
+	if len(names) == 0 {
+		return result, nil
+	}
+
+	rows, err := q.Query(`
+		SELECT `+tagColumns+` FROM tags WHERE organization_id = $1 AND name = ANY($2)
+	`, orgID, pq.Array(names))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		t, err := scanTag(rows)
+		if err != nil {
+			return nil, err
+		}
+		result[t.Name] = *t
+	}
+	return result, nil
+}
+
 type UpdateTagRequest struct {
-	Name        string `json:"name"`
-	Label       string `json:"label"`
-	Description string `json:"description"`
-	Type        string `json:"type"` // 👈 новое поле
+	Name         string   `json:"name"`
+	Label        string   `json:"label"`
+	Description  string   `json:"description"`
+	Type         string   `json:"type"` // 👈 новое поле
+	DefaultValue string   `json:"default_value"`
+	Required     bool     `json:"required"`
+	Options      []string `json:"options"`
 }
 
-func UpdateTag(id string, name, label, description, tagType string) (*Tag, error) {
-	var tag Tag
+func UpdateTag(orgID int, id string, req UpdateTagRequest) (*Tag, error) {
+	options, err := marshalOptions(req.Options)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
 		UPDATE tags
-		SET name = $1, label = $2, description = $3, type = $4
-		WHERE id = $5
-		RETURNING id, name, label, description, type, created_at
-	`
-	err := db.QueryRow(query, name, label, description, tagType, id).Scan(
-		&tag.ID, &tag.Name, &tag.Label, &tag.Description, &tag.Type, &tag.CreatedAt,
-	)
+		SET name = $1, label = $2, description = $3, type = $4,
+		    default_value = $5, required = $6, options = $7
+		WHERE id = $8 AND organization_id = $9
+		RETURNING ` + tagColumns
+	return scanTag(db.QueryRow(query, req.Name, req.Label, req.Description, req.Type, req.DefaultValue, req.Required, options, id, orgID))
+}
+
+// GetTagDefaults возвращает значения по умолчанию всех тегов, у которых они заданы
+func GetTagDefaults(orgID int) (map[string]string, error) {
+	rows, err := db.Query(`SELECT name, default_value FROM tags WHERE organization_id = $1 AND default_value <> ''`, orgID)
 	if err != nil {
 		return nil, err
 	}
-	return &tag, nil
+	defer rows.Close()
+
+	defaults := make(map[string]string)
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		defaults[name] = value
+	}
+	return defaults, nil
 }
 
 type TemplateStyle struct {
@@ -210,35 +390,13 @@ type TemplateStyle struct {
 	CreatedAt  time.Time              `json:"created_at"`
 }
 
-// CreateTemplateStyle добавляет стиль к шаблону
-func CreateTemplateStyle(templateID int, selector string, styles map[string]interface{}) error {
-	stylesJSON, err := json.Marshal(styles)
-	if err != nil {
-		log.Println("❌ Marshal error:", err)
-		return err
-	}
-
-	_, err = db.Exec(`
-		INSERT INTO template_styles (template_id, selector, styles)
-		VALUES ($1, $2, $3)
-		ON CONFLICT (template_id, selector) DO UPDATE
-		SET styles = EXCLUDED.styles
-	`, templateID, selector, stylesJSON)
-
-	if err != nil {
-		log.Println("❌ SQL Exec error:", err)
-	}
-
-	return err
-}
-
 // GetStylesByTemplateID возвращает все стили по шаблону
-func GetStylesByTemplateID(templateID int) ([]TemplateStyle, error) {
+func GetStylesByTemplateID(orgID, templateID int) ([]TemplateStyle, error) {
 	rows, err := db.Query(`
 		SELECT id, template_id, selector, styles, scope, created_at
 		FROM template_styles
-		WHERE template_id = $1
-	`, templateID)
+		WHERE template_id = $1 AND organization_id = $2
+	`, templateID, orgID)
 	if err != nil {
 		return nil, err
 	}
@@ -261,14 +419,16 @@ func GetStylesByTemplateID(templateID int) ([]TemplateStyle, error) {
 	return result, nil
 }
 
-func AutoAssignStyleIDs() error {
+func AutoAssignStyleIDs(orgID int) error {
 	query := `
 		WITH matches AS (
 			SELECT
 				t.id AS tag_id,
 				REGEXP_MATCHES(tmp.content, 'data-style-id="([a-f0-9\\-]{36})">[^<]*{{' || t.name || '}}', 'g') AS style_match
 			FROM tags t
-			JOIN templates tmp ON tmp.content ILIKE '%' || '{{' || t.name || '}}' || '%'
+			JOIN templates tmp ON tmp.organization_id = t.organization_id
+			                  AND tmp.content ILIKE '%' || '{{' || t.name || '}}' || '%'
+			WHERE t.organization_id = $1
 		)
 		UPDATE tags
 		SET style_id = style_match[1]::uuid
@@ -276,6 +436,71 @@ func AutoAssignStyleIDs() error {
 		WHERE tags.id = matches.tag_id
 		  AND style_match IS NOT NULL
 	`
-	_, err := db.Exec(query)
+	_, err := db.Exec(query, orgID)
 	return err
 }
+
+// ensureTags создаёт недостающие теги с типом string и возвращает имена созданных
+func ensureTags(q dbtx, orgID int, names []string) ([]string, error) {
+	existing, err := getTagsByNames(q, orgID, names)
+	if err != nil {
+		return nil, err
+	}
+
+	var created []string
+	for _, name := range names {
+		if _, ok := existing[name]; ok {
+			continue
+		}
+		if _, err := createTag(q, orgID, CreateTagRequest{Name: name, Label: name, Type: TagTypeString}); err != nil {
+			return created, err
+		}
+		created = append(created, name)
+	}
+	return created, nil
+}
+
+// SaveImportedTemplate сохраняет импортированный из .docx шаблон одной транзакцией:
+// шаблон (для вида docx — вместе с исходным файлом), его стили и недостающие теги.
+// При любой ошибке ничего не сохраняется. Возвращает ID шаблона и имена созданных тегов.
+func SaveImportedTemplate(orgID, userID int, name, kind string, imported *ImportedTemplate, source []byte) (int, []string, error) {
+	if kind != TemplateKindDocx {
+		source = nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback()
+
+	var newID int
+	err = tx.QueryRow(`
+		INSERT INTO templates (organization_id, user_id, name, content, kind, source_docx)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, orgID, userID, name, imported.HTML, kind, source).Scan(&newID)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	for _, style := range imported.Styles {
+		if err := createTemplateStyle(tx, orgID, newID, style.Selector(), style.Styles, "inline"); err != nil {
+			return 0, nil, fmt.Errorf("ошибка сохранения стиля %s: %w", style.StyleID, err)
+		}
+	}
+
+	created, err := ensureTags(tx, orgID, imported.Tags)
+	if err != nil {
+		return 0, nil, fmt.Errorf("ошибка создания тегов: %w", err)
+	}
+
+	if err := autoAssignTemplateStyleIDs(tx, orgID, newID, imported.HTML); err != nil {
+		return 0, nil, fmt.Errorf("ошибка назначения style_id: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, nil, err
+	}
+	return newID, created, nil
+}
@@ -0,0 +1,138 @@
+package templates
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"doc-generation/etag"
+)
+
+// Шаблон 7 и тег 3 принадлежат ownOrg; запросы в тестах приходят от пользователя otherOrg
+const (
+	ownOrg   = 1
+	otherOrg = 2
+)
+
+var (
+	templateColumns = []string{"id", "organization_id", "user_id", "name", "content", "kind", "version", "created_at"}
+	tagRowColumns   = []string{"id", "name", "label", "description", "type", "created_at", "style_id", "default_value", "required", "options"}
+)
+
+func mockDB(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		database.Close()
+	})
+	InitTemplates(database)
+	return mock
+}
+
+func ownTemplateRow() *sqlmock.Rows {
+	return sqlmock.NewRows(templateColumns).
+		AddRow(7, ownOrg, 10, "Договор поставки", "<p>{{client}}</p>", TemplateKindHTML, 3, time.Now())
+}
+
+func TestGetTemplateByIDOwnOrganization(t *testing.T) {
+	mock := mockDB(t)
+	mock.ExpectQuery(`FROM templates WHERE id = \$1 AND organization_id = \$2`).
+		WithArgs(7, ownOrg).
+		WillReturnRows(ownTemplateRow())
+
+	tmpl, err := GetTemplateByID(ownOrg, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmpl.ID != 7 || tmpl.OrganizationID != ownOrg {
+		t.Errorf("получен шаблон %+v", tmpl)
+	}
+}
+
+// Даже если запрос вернёт строку чужой организации, шаблон не отдаётся
+func TestGetTemplateByIDForeignRow(t *testing.T) {
+	mock := mockDB(t)
+	mock.ExpectQuery(`FROM templates WHERE id = \$1 AND organization_id = \$2`).
+		WithArgs(7, otherOrg).
+		WillReturnRows(ownTemplateRow())
+
+	tmpl, err := GetTemplateByID(otherOrg, 7)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("GetTemplateByID(otherOrg) = %+v, %v; ожидалось sql.ErrNoRows", tmpl, err)
+	}
+}
+
+// Изменение чужого шаблона не выполняется и не выдаёт, что шаблон существует:
+// ответ — «не найден», а не конфликт версий
+func TestUpdateTemplateOtherOrganization(t *testing.T) {
+	mock := mockDB(t)
+	mock.ExpectQuery(`UPDATE templates SET name = \$1, content = \$2`).
+		WithArgs("Новое имя", "<p></p>", 7, sqlmock.AnyArg(), etag.Any, otherOrg).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}))
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM templates WHERE id = \$1 AND organization_id = \$2\)`).
+		WithArgs(7, otherOrg).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	_, err := UpdateTemplate(otherOrg, 7, "Новое имя", "<p></p>", etag.AnyVersion())
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("UpdateTemplate(otherOrg): %v; ожидалось sql.ErrNoRows", err)
+	}
+}
+
+func TestDeleteTemplateOtherOrganization(t *testing.T) {
+	mock := mockDB(t)
+	mock.ExpectExec(`DELETE FROM templates WHERE id = \$1 AND organization_id = \$2`).
+		WithArgs(7, otherOrg).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := DeleteTemplate(otherOrg, 7); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("DeleteTemplate(otherOrg): %v; ожидалось sql.ErrNoRows", err)
+	}
+}
+
+func TestGetAllTagsFiltersByOrganization(t *testing.T) {
+	mock := mockDB(t)
+	mock.ExpectQuery(`FROM tags WHERE organization_id = \$1 ORDER BY name`).
+		WithArgs(otherOrg).
+		WillReturnRows(sqlmock.NewRows(tagRowColumns))
+
+	tags, err := GetAllTags(otherOrg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 0 {
+		t.Errorf("GetAllTags(otherOrg) вернул %d тегов", len(tags))
+	}
+}
+
+func TestGetTagByNameFiltersByOrganization(t *testing.T) {
+	mock := mockDB(t)
+	mock.ExpectQuery(`FROM tags WHERE organization_id = \$1 AND name = \$2`).
+		WithArgs(otherOrg, "client").
+		WillReturnRows(sqlmock.NewRows(tagRowColumns))
+
+	if _, err := GetTagByName(otherOrg, "client"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("GetTagByName(otherOrg): %v; ожидалось sql.ErrNoRows", err)
+	}
+}
+
+func TestUpdateTagOtherOrganization(t *testing.T) {
+	mock := mockDB(t)
+	mock.ExpectQuery(`UPDATE tags .* WHERE id = \$8 AND organization_id = \$9`).
+		WithArgs("client", "Клиент", "", "text", "", false, sqlmock.AnyArg(), "3", otherOrg).
+		WillReturnRows(sqlmock.NewRows(tagRowColumns))
+
+	_, err := UpdateTag(otherOrg, "3", UpdateTagRequest{Name: "client", Label: "Клиент", Type: "text"})
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("UpdateTag(otherOrg): %v; ожидалось sql.ErrNoRows", err)
+	}
+}
@@ -1,39 +1,56 @@
 package templates
 
 import (
+	"bytes"
 	"database/sql"
 	"fmt"
 	"github.com/goccy/go-json"
+	"io"
 
 	"log"
 	"net/http"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+
+	"doc-generation/auth"
+	"doc-generation/etag"
+	"doc-generation/render"
 )
 
+// RegisterTemplateRoutes подключает маршруты шаблонов и тегов. Организация берётся
+// из токена пользователя (AuthMiddleware), данные других организаций недоступны.
 func RegisterTemplateRoutes(r *gin.Engine) {
-	r.POST("/templates/create", createTemplateHandler)
-	r.GET("/templates/get", getTemplateHandler)
-	r.PUT("/templates/update", updateTemplateHandler)
-	r.DELETE("/templates/delete", deleteTemplateHandler)
-	r.GET("/templates/all", getAllTemplatesHandler)
-	r.GET("/templates/:id", getTemplateByIDHandler)
-	r.POST("/templates/rename", renameTemplateHandler)
-	r.PUT("/templates/update-content", updateTemplateContentHandler)
-	r.POST("/tags/create", createTagHandler)
-	r.GET("/tags/all", getAllTagsHandler)
-	r.PUT("/tags/:id", updateTagHandler)
-	r.POST("/templates/styles", createTemplateStyleHandler)
-	r.GET("/templates/:id/styles", getTemplateStylesHandler)
-	r.POST("/templates/style", createTemplateStyleHandler)
-	r.POST("/templates/:id/auto-assign-style-ids", autoAssignStyleIDsHandler)
-
-	r.POST("/tags/auto-assign-style-ids", func(c *gin.Context) {
-		if err := AutoAssignStyleIDs(); err != nil {
+	g := r.Group("/")
+	g.Use(auth.AuthMiddleware())
+	g.POST("/templates/create", createTemplateHandler)
+	g.POST("/templates/import", importTemplateHandler)
+	g.GET("/templates/:id/source", getTemplateSourceHandler)
+	g.GET("/templates/:id/approval-route", getApprovalRouteHandler)
+	g.PUT("/templates/:id/approval-route", setApprovalRouteHandler)
+	g.GET("/templates/get", getTemplateHandler)
+	g.PUT("/templates/update", updateTemplateHandler)
+	g.DELETE("/templates/delete", deleteTemplateHandler)
+	g.GET("/templates/all", getAllTemplatesHandler)
+	g.GET("/templates/:id", getTemplateByIDHandler)
+	g.POST("/templates/rename", renameTemplateHandler)
+	g.PUT("/templates/update-content", updateTemplateContentHandler)
+	g.POST("/tags/create", auth.RequirePermission(auth.PermTagsEdit), createTagHandler)
+	g.GET("/tags/all", getAllTagsHandler)
+	// теги общие для всех шаблонов организации
+	g.PUT("/tags/:id", auth.RequirePermission(auth.PermTagsEdit), updateTagHandler)
+	g.POST("/templates/styles", createTemplateStyleHandler)
+	g.GET("/templates/:id/styles", getTemplateStylesHandler)
+	g.GET("/templates/:id/schema", getTemplateSchemaHandler)
+	g.POST("/templates/style", createTemplateStyleHandler)
+	g.POST("/templates/:id/auto-assign-style-ids", autoAssignStyleIDsHandler)
+
+	g.POST("/tags/auto-assign-style-ids", auth.RequirePermission(auth.PermTagsEdit), func(c *gin.Context) {
+		if err := AutoAssignStyleIDs(c.GetInt("organization_id")); err != nil {
 			log.Println("❌ Ошибка автоназначения style_id:", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при обновлении style_id"})
 			return
@@ -43,10 +60,43 @@ func RegisterTemplateRoutes(r *gin.Engine) {
 
 }
 
+// ----------------- Права -----------------
+
+// canEditTemplate проверяет, что шаблон есть в организации и пользователь может
+// его менять: автор шаблона или обладатель права templates.edit. Иначе отвечает 404 или 403.
+func canEditTemplate(c *gin.Context, id int) bool {
+	t, err := GetTemplateByID(c.GetInt("organization_id"), id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Шаблон не найден"})
+		return false
+	}
+	if err != nil {
+		log.Printf("❌ Ошибка получения шаблона ID=%d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении шаблона"})
+		return false
+	}
+	userID := c.GetInt("user_id")
+	if t.UserID == userID {
+		return true
+	}
+	allowed, err := auth.HasPermission(userID, auth.PermTemplatesEdit)
+	if err != nil {
+		log.Printf("❌ Ошибка проверки прав пользователя ID=%d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка проверки прав"})
+		return false
+	}
+	if !allowed {
+		log.Printf("⛔ Нет прав на шаблон ID=%d: user_id=%d", id, userID)
+		c.JSON(http.StatusForbidden, gin.H{"error": "Изменять шаблон может только его автор"})
+		return false
+	}
+	return true
+}
+
 // ----------------- Create -----------------
 
+// CreateRequest — новый шаблон; автор — пользователь из токена
 type CreateRequest struct {
-	UserID  int    `json:"user_id"`
 	Name    string `json:"name"`
 	Content string `json:"content"`
 }
@@ -61,7 +111,11 @@ func createTemplateHandler(c *gin.Context) {
 
 	log.Printf("Получен шаблон: %+v\n", req)
 
-	newID, err := CreateTemplate(req.UserID, req.Name, req.Content)
+	if !validateTemplateSyntax(c, req.Content) {
+		return
+	}
+
+	newID, err := CreateTemplate(c.GetInt("organization_id"), c.GetInt("user_id"), req.Name, req.Content)
 	if err != nil {
 		log.Println("Ошибка при создании шаблона:", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании шаблона"})
@@ -71,6 +125,176 @@ func createTemplateHandler(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{"id": newID})
 }
 
+// ----------------- Import -----------------
+
+// maxImportSize — предельный размер загружаемого .docx
+const maxImportSize = 20 << 20
+
+// importTemplateHandler создаёт шаблон из загруженного .docx (multipart: file, name, kind).
+// При kind=docx исходный файл сохраняется, и документы заполняются прямо в нём
+// (экспорт таких документов — только в .docx); HTML остаётся для предпросмотра и схемы полей.
+func importTemplateHandler(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Файл не передан"})
+		return
+	}
+	ext := filepath.Ext(file.Filename)
+	if !strings.EqualFold(ext, ".docx") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Поддерживаются только файлы .docx"})
+		return
+	}
+	if file.Size > maxImportSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Файл слишком большой"})
+		return
+	}
+
+	kind := c.DefaultPostForm("kind", TemplateKindHTML)
+	if kind != TemplateKindHTML && kind != TemplateKindDocx {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неизвестный вид шаблона"})
+		return
+	}
+	name := strings.TrimSpace(c.PostForm("name"))
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(file.Filename), ext)
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Не удалось прочитать файл"})
+		return
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Не удалось прочитать файл"})
+		return
+	}
+
+	imported, err := ImportDocx(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		log.Printf("❌ Ошибка разбора .docx %q: %v", file.Filename, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Не удалось разобрать документ Word"})
+		return
+	}
+
+	if !validateTemplateSyntax(c, imported.HTML) {
+		return
+	}
+
+	newID, createdTags, err := SaveImportedTemplate(c.GetInt("organization_id"), c.GetInt("user_id"), name, kind, imported, data)
+	if err != nil {
+		log.Printf("❌ Ошибка при сохранении шаблона из %q: %v", file.Filename, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании шаблона"})
+		return
+	}
+
+	log.Printf("✅ Шаблон ID=%d импортирован из %q: стилей %d, тегов %d", newID, file.Filename, len(imported.Styles), len(imported.Tags))
+	c.JSON(http.StatusCreated, gin.H{
+		"id":           newID,
+		"kind":         kind,
+		"tags":         imported.Tags,
+		"created_tags": createdTags,
+		"styles":       len(imported.Styles),
+	})
+}
+
+// getTemplateSourceHandler отдаёт исходный .docx шаблона вида docx
+func getTemplateSourceHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID"})
+		return
+	}
+
+	source, err := GetTemplateSource(c.GetInt("organization_id"), id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "У шаблона нет исходного .docx"})
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Ошибка получения исходного .docx шаблона ID=%d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении файла шаблона"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="template_%d.docx"`, id))
+	c.Data(http.StatusOK, "application/vnd.openxmlformats-officedocument.wordprocessingml.document", source)
+}
+
+// ----------------- Маршрут согласования -----------------
+
+func getApprovalRouteHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID"})
+		return
+	}
+
+	steps, err := GetApprovalRoute(c.GetInt("organization_id"), id)
+	if err != nil {
+		log.Printf("❌ Ошибка получения маршрута согласования шаблона ID=%d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении маршрута согласования"})
+		return
+	}
+	c.JSON(http.StatusOK, steps)
+}
+
+// setApprovalRouteRequest — этапы маршрута по порядку; в каждом этапе —
+// согласующие, которые согласуют параллельно: {"steps": [[3], [5, 8]]}
+type setApprovalRouteRequest struct {
+	Steps [][]int `json:"steps"`
+}
+
+// setApprovalRouteHandler задаёт маршрут согласования. Доступно тем, кто может
+// менять шаблон (canEditTemplate).
+func setApprovalRouteHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID"})
+		return
+	}
+
+	var req setApprovalRouteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный JSON"})
+		return
+	}
+	steps, err := ApprovalSteps(req.Steps)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !canEditTemplate(c, id) {
+		return
+	}
+
+	err = SetApprovalRoute(c.GetInt("organization_id"), id, steps)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Шаблон не найден"})
+		return
+	}
+	if err == ErrUnknownApprover {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Согласующими могут быть только пользователи вашей организации"})
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Ошибка сохранения маршрута согласования шаблона ID=%d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при сохранении маршрута согласования"})
+		return
+	}
+
+	log.Printf("✅ Маршрут согласования шаблона ID=%d: согласующих %d", id, len(steps))
+	steps, err = GetApprovalRoute(c.GetInt("organization_id"), id)
+	if err != nil {
+		log.Printf("❌ Ошибка получения маршрута согласования шаблона ID=%d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении маршрута согласования"})
+		return
+	}
+	c.JSON(http.StatusOK, steps)
+}
+
 // ----------------- Get -----------------
 
 func getTemplateHandler(c *gin.Context) {
@@ -81,12 +305,13 @@ func getTemplateHandler(c *gin.Context) {
 		return
 	}
 
-	t, err := GetTemplateByID(id)
+	t, err := GetTemplateByID(c.GetInt("organization_id"), id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Шаблон не найден"})
 		return
 	}
 
+	etag.Set(c, t.Version)
 	c.JSON(http.StatusOK, t)
 }
 
@@ -105,12 +330,44 @@ func updateTemplateHandler(c *gin.Context) {
 		return
 	}
 
-	if err := UpdateTemplate(req.ID, req.Name, req.Content); err != nil {
+	versions, ok := etag.IfMatch(c)
+	if !ok || !canEditTemplate(c, req.ID) || !validateTemplateSyntax(c, req.Content) {
+		return
+	}
+
+	version, err := UpdateTemplate(c.GetInt("organization_id"), req.ID, req.Name, req.Content, versions)
+	if err == ErrVersionConflict {
+		templateConflict(c, req.ID)
+		return
+	}
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Шаблон не найден"})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при обновлении шаблона"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Шаблон обновлён"})
+	etag.Set(c, version)
+	c.JSON(http.StatusOK, gin.H{"message": "Шаблон обновлён", "version": version})
+}
+
+// templateConflict отвечает 409 с текущей версией шаблона, чтобы клиент мог объединить правки
+func templateConflict(c *gin.Context, id int) {
+	t, err := GetTemplateByID(c.GetInt("organization_id"), id)
+	if err != nil {
+		log.Printf("❌ Ошибка получения текущей версии шаблона ID=%d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении текущей версии шаблона"})
+		return
+	}
+	etag.Set(c, t.Version)
+	c.JSON(http.StatusConflict, gin.H{
+		"error":   "Шаблон изменён другим пользователем",
+		"version": t.Version,
+		"name":    t.Name,
+		"content": t.Content,
+	})
 }
 
 // ----------------- Delete -----------------
@@ -123,7 +380,16 @@ func deleteTemplateHandler(c *gin.Context) {
 		return
 	}
 
-	if err := DeleteTemplate(id); err != nil {
+	if !canEditTemplate(c, id) {
+		return
+	}
+
+	err = DeleteTemplate(c.GetInt("organization_id"), id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Шаблон не найден"})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при удалении шаблона"})
 		return
 	}
@@ -132,7 +398,7 @@ func deleteTemplateHandler(c *gin.Context) {
 }
 
 func getAllTemplatesHandler(c *gin.Context) {
-	templates, err := GetAllTemplates() // Эта функция должна получать все шаблоны из БД
+	templates, err := GetAllTemplates(c.GetInt("organization_id"))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении шаблонов"})
 		return
@@ -149,12 +415,13 @@ func getTemplateByIDHandler(c *gin.Context) {
 		return
 	}
 
-	t, err := GetTemplateByID(id)
+	t, err := GetTemplateByID(c.GetInt("organization_id"), id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Шаблон не найден"})
 		return
 	}
 
+	etag.Set(c, t.Version)
 	c.JSON(http.StatusOK, t)
 }
 
@@ -175,7 +442,16 @@ func renameTemplateHandler(c *gin.Context) {
 		return
 	}
 
-	if err := RenameTemplate(req.ID, req.Name); err != nil {
+	if !canEditTemplate(c, req.ID) {
+		return
+	}
+
+	err := RenameTemplate(c.GetInt("organization_id"), req.ID, req.Name)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Шаблон не найден"})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при переименовании шаблона"})
 		return
 	}
@@ -198,28 +474,57 @@ func updateTemplateContentHandler(c *gin.Context) {
 
 	log.Printf("🔁 Обновление контента шаблона ID=%d, длина контента=%d\n", req.ID, len(req.Content))
 
+	// 0. Проверяем версию из If-Match, права на шаблон и синтаксис {{#if}}/{{#each}} до сохранения
+	versions, ok := etag.IfMatch(c)
+	if !ok || !canEditTemplate(c, req.ID) || !validateTemplateSyntax(c, req.Content) {
+		return
+	}
+
 	// 1. Обновляем сам шаблон в базе
-	if err := UpdateTemplateContent(req.ID, req.Content); err != nil {
+	version, err := UpdateTemplateContent(c.GetInt("organization_id"), req.ID, req.Content, versions)
+	if err == ErrVersionConflict {
+		templateConflict(c, req.ID)
+		return
+	}
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Шаблон не найден"})
+		return
+	}
+	if err != nil {
 		log.Printf("❌ Ошибка обновления контента шаблона ID=%d: %v\n", req.ID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при обновлении контента"})
 		return
 	}
 
 	// 2. Назначаем style_id всем {{тегам}}, у которых он отсутствует
-	if err := AutoAssignStyleIDsToTemplate(req.ID, req.Content); err != nil {
+	if err := AutoAssignStyleIDsToTemplate(c.GetInt("organization_id"), req.ID, req.Content); err != nil {
 		log.Printf("⚠️ Ошибка автоназначения style_id для шаблона ID=%d: %v\n", req.ID, err)
 		// Не прерываем выполнение — шаблон уже обновлён, можно дать soft-warning
 	}
 
 	log.Printf("✅ Контент шаблона ID=%d успешно обновлён\n", req.ID)
-	c.JSON(http.StatusOK, gin.H{"message": "Контент обновлён"})
+	etag.Set(c, version)
+	c.JSON(http.StatusOK, gin.H{"message": "Контент обновлён", "version": version})
+}
+
+// validateTemplateSyntax проверяет парность блоков шаблона и при ошибке отвечает 400
+func validateTemplateSyntax(c *gin.Context, content string) bool {
+	if _, err := render.Parse(content); err != nil {
+		log.Printf("⚠️ Ошибка синтаксиса шаблона: %v\n", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Ошибка в синтаксисе шаблона", "details": err.Error()})
+		return false
+	}
+	return true
 }
 
 type CreateTagRequest struct {
-	Name        string `json:"name"`
-	Label       string `json:"label"`
-	Description string `json:"description"`
-	Type        string `json:"type"` // 👈 новое поле
+	Name         string   `json:"name"`
+	Label        string   `json:"label"`
+	Description  string   `json:"description"`
+	Type         string   `json:"type"` // 👈 новое поле
+	DefaultValue string   `json:"default_value"`
+	Required     bool     `json:"required"`
+	Options      []string `json:"options"`
 }
 
 func createTagHandler(c *gin.Context) {
@@ -234,7 +539,12 @@ func createTagHandler(c *gin.Context) {
 		return
 	}
 
-	tag, err := CreateTag(req.Name, req.Label, req.Description, req.Type) // 👈 передаем type
+	if req.Type == TagTypeEnum && len(req.Options) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Для типа enum нужно указать допустимые значения"})
+		return
+	}
+
+	tag, err := CreateTag(c.GetInt("organization_id"), req)
 	if err != nil {
 		log.Printf("❌ Ошибка при создании тега: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании тега"})
@@ -245,7 +555,7 @@ func createTagHandler(c *gin.Context) {
 }
 
 func getAllTagsHandler(c *gin.Context) {
-	tags, err := GetAllTags()
+	tags, err := GetAllTags(c.GetInt("organization_id"))
 	if err != nil {
 		log.Printf("❌ Ошибка при получении тегов: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении тегов"})
@@ -272,7 +582,16 @@ func updateTagHandler(c *gin.Context) {
 		return
 	}
 
-	tag, err := UpdateTag(tagID, req.Name, req.Label, req.Description, req.Type) // 👈 передаем type
+	if req.Type == TagTypeEnum && len(req.Options) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Для типа enum нужно указать допустимые значения"})
+		return
+	}
+
+	tag, err := UpdateTag(c.GetInt("organization_id"), tagID, req)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Тег не найден"})
+		return
+	}
 	if err != nil {
 		log.Printf("❌ Ошибка при обновлении тега: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при обновлении тега"})
@@ -301,6 +620,10 @@ func createTemplateStyleHandler(c *gin.Context) {
 		return
 	}
 
+	if !canEditTemplate(c, req.TemplateID) {
+		return
+	}
+
 	if req.Scope == "" {
 		req.Scope = "global" // по умолчанию
 	}
@@ -316,12 +639,13 @@ func createTemplateStyleHandler(c *gin.Context) {
 	}
 
 	// Проверяем, существует ли уже такой стиль
+	orgID := c.GetInt("organization_id")
 	var existingSelector string
 	err = db.QueryRow(`
 		SELECT selector FROM template_styles
-		WHERE template_id = $1 AND scope = $2 AND styles::jsonb = $3::jsonb
+		WHERE template_id = $1 AND organization_id = $4 AND scope = $2 AND styles::jsonb = $3::jsonb
 		LIMIT 1
-	`, req.TemplateID, req.Scope, stylesJSON).Scan(&existingSelector)
+	`, req.TemplateID, req.Scope, stylesJSON, orgID).Scan(&existingSelector)
 
 	if err == nil {
 		// Стиль уже существует — возвращаем его selector
@@ -337,7 +661,11 @@ func createTemplateStyleHandler(c *gin.Context) {
 	}
 
 	// Стиль не найден — создаём
-	err = CreateTemplateStyleWithScope(req.TemplateID, req.Selector, sanitizedStyles, req.Scope)
+	err = CreateTemplateStyleWithScope(orgID, req.TemplateID, req.Selector, sanitizedStyles, req.Scope)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Шаблон не найден"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при сохранении стиля"})
 		return
@@ -357,7 +685,7 @@ func getTemplateStylesHandler(c *gin.Context) {
 		return
 	}
 
-	styles, err := GetStylesByTemplateID(templateID)
+	styles, err := GetStylesByTemplateID(c.GetInt("organization_id"), templateID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении стилей"})
 		return
@@ -366,7 +694,35 @@ func getTemplateStylesHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, styles)
 }
 
-func CreateTemplateStyleWithScope(templateID int, selector string, styles map[string]interface{}, scope string) error {
+func getTemplateSchemaHandler(c *gin.Context) {
+	idStr := c.Param("id")
+	templateID, err := strconv.Atoi(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID"})
+		return
+	}
+
+	schema, err := GetTemplateSchema(c.GetInt("organization_id"), templateID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Шаблон не найден"})
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Ошибка построения схемы шаблона ID=%d: %v", templateID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении схемы шаблона"})
+		return
+	}
+
+	c.JSON(http.StatusOK, schema)
+}
+
+// CreateTemplateStyleWithScope добавляет или обновляет стиль шаблона организации
+// (sql.ErrNoRows, если шаблона в организации нет)
+func CreateTemplateStyleWithScope(orgID, templateID int, selector string, styles map[string]interface{}, scope string) error {
+	return createTemplateStyle(db, orgID, templateID, selector, styles, scope)
+}
+
+func createTemplateStyle(q dbtx, orgID, templateID int, selector string, styles map[string]interface{}, scope string) error {
 	stylesJSON, err := json.Marshal(styles)
 	if err != nil {
 		log.Println("❌ Marshal error:", err)
@@ -384,15 +740,16 @@ func CreateTemplateStyleWithScope(templateID int, selector string, styles map[st
 		}
 	}
 
-	_, err = db.Exec(`
-		INSERT INTO template_styles (template_id, selector, styles, scope, font_size_pt)
-		VALUES ($1, $2, $3, $4, $5)
+	err = execScopedOn(q, `
+		INSERT INTO template_styles (organization_id, template_id, selector, styles, scope, font_size_pt)
+		SELECT t.organization_id, t.id, $2, $3, $4, $5
+		FROM templates t WHERE t.id = $1 AND t.organization_id = $6
 		ON CONFLICT (template_id, selector, scope) DO UPDATE
 		SET styles = EXCLUDED.styles,
 		    font_size_pt = EXCLUDED.font_size_pt
-	`, templateID, selector, stylesJSON, scope, fontSizePt)
+	`, templateID, selector, stylesJSON, scope, fontSizePt, orgID)
 
-	if err != nil {
+	if err != nil && err != sql.ErrNoRows {
 		log.Println("❌ SQL Exec error:", err)
 	}
 
@@ -409,23 +766,30 @@ func toKebabCaseStyle(input map[string]interface{}) map[string]interface{} {
 	return result
 }
 
-func AutoAssignStyleIDsToTemplate(templateID int, html string) error {
-	re := regexp.MustCompile(`{{(.*?)}}`)
-	matches := re.FindAllStringSubmatch(html, -1)
+func AutoAssignStyleIDsToTemplate(orgID, templateID int, html string) error {
+	return autoAssignTemplateStyleIDs(db, orgID, templateID, html)
+}
 
-	for _, m := range matches {
-		tagName := m[1]
+func autoAssignTemplateStyleIDs(q dbtx, orgID, templateID int, html string) error {
+	for _, tagName := range render.Fields(html) {
 
 		var styleID sql.NullString
-		err := db.QueryRow(`SELECT style_id FROM tags WHERE name = $1`, tagName).Scan(&styleID)
-		if err != nil {
+		err := q.QueryRow(`
+			SELECT style_id FROM tags WHERE organization_id = $1 AND name = $2
+		`, orgID, tagName).Scan(&styleID)
+		if err == sql.ErrNoRows {
 			continue
 		}
+		if err != nil {
+			return err
+		}
 
 		if !styleID.Valid {
 			newID := uuid.New().String()
 
-			_, err = db.Exec(`UPDATE tags SET style_id = $1 WHERE name = $2`, newID, tagName)
+			_, err = q.Exec(`
+				UPDATE tags SET style_id = $1 WHERE organization_id = $2 AND name = $3
+			`, newID, orgID, tagName)
 			if err != nil {
 				return err
 			}
@@ -433,7 +797,7 @@ func AutoAssignStyleIDsToTemplate(templateID int, html string) error {
 			selector := fmt.Sprintf(`span[data-style-id="%s"]`, newID)
 			defaultStyles := map[string]interface{}{"font-size": "14px"}
 
-			if err := CreateTemplateStyleWithScope(templateID, selector, defaultStyles, "inline"); err != nil {
+			if err := createTemplateStyle(q, orgID, templateID, selector, defaultStyles, "inline"); err != nil {
 				return err
 			}
 		}
@@ -458,7 +822,11 @@ func autoAssignStyleIDsHandler(c *gin.Context) {
 		return
 	}
 
-	if err := AutoAssignStyleIDsToTemplate(templateID, req.HTML); err != nil {
+	if !canEditTemplate(c, templateID) {
+		return
+	}
+
+	if err := AutoAssignStyleIDsToTemplate(c.GetInt("organization_id"), templateID, req.HTML); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка автоназначения style_id"})
 		return
 	}
@@ -0,0 +1,53 @@
+package templates
+
+import "doc-generation/render"
+
+// FieldSchema описывает одно поле шаблона для автоматического построения формы
+type FieldSchema struct {
+	Name         string   `json:"name"`
+	Label        string   `json:"label"`
+	Description  string   `json:"description"`
+	Type         string   `json:"type"`
+	Required     bool     `json:"required"`
+	Options      []string `json:"options"`
+	DefaultValue string   `json:"default_value"`
+	HasTag       bool     `json:"has_tag"` // false — плейсхолдер без описания в таблице tags
+}
+
+// BuildSchema строит схему полей по контенту шаблона: порядок полей совпадает
+// с порядком первого упоминания в тексте, описание берётся из таблицы tags
+func BuildSchema(orgID int, content string) ([]FieldSchema, error) {
+	names := render.Fields(content)
+
+	tags, err := GetTagsByNames(orgID, names)
+	if err != nil {
+		return nil, err
+	}
+
+	schema := make([]FieldSchema, 0, len(names))
+	for _, name := range names {
+		field := FieldSchema{Name: name, Label: name, Type: TagTypeString, Options: []string{}}
+		if tag, ok := tags[name]; ok {
+			field.Label = tag.Label
+			field.Description = tag.Description
+			field.Type = tag.Type
+			field.Required = tag.Required
+			field.DefaultValue = tag.DefaultValue
+			field.HasTag = true
+			if tag.Options != nil {
+				field.Options = tag.Options
+			}
+		}
+		schema = append(schema, field)
+	}
+	return schema, nil
+}
+
+// GetTemplateSchema возвращает схему полей шаблона по его ID
+func GetTemplateSchema(orgID, templateID int) ([]FieldSchema, error) {
+	t, err := GetTemplateByID(orgID, templateID)
+	if err != nil {
+		return nil, err
+	}
+	return BuildSchema(orgID, t.Content)
+}
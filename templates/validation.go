@@ -0,0 +1,151 @@
+package templates
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"doc-generation/render"
+)
+
+// Поддерживаемые типы тегов. Неизвестные типы проверяются как string.
+const (
+	TagTypeString = "string"
+	TagTypeText   = "text"
+	TagTypeNumber = "number"
+	TagTypeDate   = "date"
+	TagTypeMoney  = "money"
+	TagTypeEmail  = "email"
+	TagTypeINN    = "inn"
+	TagTypePhone  = "phone"
+	TagTypeEnum   = "enum"
+	TagTypeList   = "list"
+)
+
+var (
+	moneyRe = regexp.MustCompile(`^-?\d+([.,]\d{1,2})?$`)
+	phoneRe = regexp.MustCompile(`^(\+7|7|8)?\d{10}$`)
+	digitRe = regexp.MustCompile(`^\d+$`)
+)
+
+// FieldError — ошибка валидации конкретного поля
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidateTagValue проверяет значение поля по типу тега. Возвращает nil, если значение корректно.
+func ValidateTagValue(tag Tag, value string) *FieldError {
+	fail := func(msg string) *FieldError {
+		return &FieldError{Field: tag.Name, Message: msg}
+	}
+
+	trimmed := strings.TrimSpace(value)
+	if trimmed == "" {
+		if tag.Required {
+			return fail("Поле обязательно для заполнения")
+		}
+		return nil
+	}
+
+	compact := strings.NewReplacer(" ", "", "\u00a0", "").Replace(trimmed)
+
+	switch tag.Type {
+	case TagTypeNumber:
+		if _, err := strconv.ParseFloat(strings.Replace(compact, ",", ".", 1), 64); err != nil {
+			return fail("Ожидается число")
+		}
+
+	case TagTypeMoney:
+		if !moneyRe.MatchString(compact) {
+			return fail("Ожидается сумма, например 1500 или 1500,50")
+		}
+
+	case TagTypeDate:
+		if _, err := render.ParseDate(trimmed); err != nil {
+			return fail("Ожидается дата в формате ДД.ММ.ГГГГ или ГГГГ-ММ-ДД")
+		}
+
+	case TagTypeEmail:
+		addr, err := mail.ParseAddress(trimmed)
+		if err != nil || addr.Address != trimmed {
+			return fail("Некорректный email")
+		}
+
+	case TagTypeINN:
+		if !validINN(trimmed) {
+			return fail("Некорректный ИНН")
+		}
+
+	case TagTypePhone:
+		phone := strings.NewReplacer(" ", "", "-", "", "(", "", ")", "", "\u00a0", "").Replace(trimmed)
+		if !phoneRe.MatchString(phone) {
+			return fail("Некорректный номер телефона")
+		}
+
+	case TagTypeEnum:
+		for _, option := range tag.Options {
+			if option == trimmed {
+				return nil
+			}
+		}
+		return fail(fmt.Sprintf("Допустимые значения: %s", strings.Join(tag.Options, ", ")))
+
+	case TagTypeList:
+		var items []interface{}
+		if err := json.Unmarshal([]byte(trimmed), &items); err != nil {
+			return fail("Ожидается список (JSON-массив)")
+		}
+		if tag.Required && len(items) == 0 {
+			return fail("Список не может быть пустым")
+		}
+	}
+
+	return nil
+}
+
+// ValidateValues проверяет значения полей по тегам. Поля без тега не проверяются;
+// обязательные теги без значения считаются ошибкой.
+func ValidateValues(tags map[string]Tag, values map[string]string) []FieldError {
+	var errs []FieldError
+	for name, tag := range tags {
+		if fe := ValidateTagValue(tag, values[name]); fe != nil {
+			errs = append(errs, *fe)
+		}
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+	return errs
+}
+
+// validINN проверяет длину и контрольные цифры ИНН юрлица (10 цифр) или физлица (12 цифр)
+func validINN(inn string) bool {
+	if !digitRe.MatchString(inn) {
+		return false
+	}
+
+	digits := make([]int, len(inn))
+	for i, r := range inn {
+		digits[i] = int(r - '0')
+	}
+
+	checksum := func(weights []int) int {
+		sum := 0
+		for i, w := range weights {
+			sum += w * digits[i]
+		}
+		return sum % 11 % 10
+	}
+
+	switch len(digits) {
+	case 10:
+		return checksum([]int{2, 4, 10, 3, 5, 9, 4, 6, 8}) == digits[9]
+	case 12:
+		return checksum([]int{7, 2, 4, 10, 3, 5, 9, 4, 6, 8}) == digits[10] &&
+			checksum([]int{3, 7, 2, 4, 10, 3, 5, 9, 4, 6, 8}) == digits[11]
+	}
+	return false
+}